@@ -0,0 +1,881 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/opcode"
+	"github.com/spf13/cobra"
+)
+
+var adviseCmd = &cobra.Command{
+	Use:   "advise [sql-statement]",
+	Short: "Check SQL against the schema's indexes and suggest new ones",
+	Long: `Check the WHERE, JOIN ON, ORDER BY and GROUP BY columns of each
+statement against the indexes of the --schema tables.
+
+An index serves the conditions on its leftmost columns: equality
+conditions (=, <=>, IN, IS NULL) on a run of leading columns, then at
+most one range (<, >, BETWEEN, LIKE 'prefix%', IS NOT NULL). ORDER BY
+or GROUP BY avoids a filesort when its columns follow the equality
+columns. For each table a statement reads, advise reports the index
+that serves it best, the conditions that index leaves out, and the
+conditions no index can serve, such as functions around columns.
+
+Where the indexes fall short it suggests a composite index: the
+equality columns, then the range column, then the sort columns (which
+only help when there's no range, or the range is on the first of them).
+Join columns count as equality conditions of both tables, since either
+table may be read second.
+
+--corpus merges the suggestions of all statements instead, folding an
+index into a longer one it leads, and lists the statements each serves.
+
+Examples:
+  dbsqlx advise --schema schema.sql "SELECT * FROM orders WHERE user_id = 7 ORDER BY created_at"
+  dbsqlx advise --schema ./schema -f queries.sql
+  dbsqlx advise --schema ./schema -f queries.sql --corpus
+  dbsqlx advise --schema ./schema -f queries.sql --format json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAdvise,
+}
+
+var (
+	adviseSchemas []string
+	adviseFormat  string
+	adviseCorpus  bool
+)
+
+func init() {
+	rootCmd.AddCommand(adviseCmd)
+
+	adviseCmd.Flags().StringArrayVar(&adviseSchemas, "schema", nil, "Schema file, directory or quoted glob with the tables and indexes (repeatable, required)")
+	adviseCmd.Flags().StringVar(&adviseFormat, "format", "text", "Output format: text or json")
+	adviseCmd.Flags().BoolVar(&adviseCorpus, "corpus", false, "Suggest indexes for all statements together")
+}
+
+// AdviseReport is the result of an advise run
+type AdviseReport struct {
+	File       string `json:"file,omitempty"`
+	Statements int    `json:"statements"`
+	// Advice covers the statements with conditions or a sort on schema
+	// tables
+	Advice []StatementAdvice `json:"advice"`
+	// Suggestions merges the suggestions of all statements
+	Suggestions  []IndexSuggestion `json:"suggestions"`
+	SyntaxErrors []*SyntaxError    `json:"syntax_errors"`
+}
+
+// StatementAdvice is how one statement reads the tables of the schema
+type StatementAdvice struct {
+	Statement int           `json:"statement"`
+	Line      int           `json:"line"`
+	Tables    []TableAdvice `json:"tables"`
+}
+
+// TableAdvice is how a statement reads one table: the index that serves
+// it best and what that index leaves out
+type TableAdvice struct {
+	Table string `json:"table"`
+	Alias string `json:"alias,omitempty"`
+	// Index is the best existing index, "" when none serves the statement
+	Index string `json:"index,omitempty"`
+	// Served and Unserved are the conditions Index serves and leaves out,
+	// e.g. "status (equality)"
+	Served   []string `json:"served"`
+	Unserved []string `json:"unserved"`
+	// Unique is set when Index finds at most one row, so the conditions
+	// it leaves out cost nothing
+	Unique bool `json:"unique"`
+	// Sort is the ORDER BY or GROUP BY the table's rows could be read in,
+	// and Sorted whether Index reads them in that order
+	Sort   string `json:"sort,omitempty"`
+	Sorted bool   `json:"sorted"`
+	// Unindexable are the conditions no index can serve
+	Unindexable []UnindexableCondition `json:"unindexable"`
+	Suggestion  *IndexSuggestion       `json:"suggestion,omitempty"`
+}
+
+// UnindexableCondition is a condition no index can serve. Line and Column
+// are 1-based and count from the start of the input.
+type UnindexableCondition struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// describe renders the condition for a statement starting on line, with
+// the column alone when the condition is on that line too
+func (u UnindexableCondition) describe(line int) string {
+	if u.Line == line {
+		return fmt.Sprintf("column %d: %s", u.Column, u.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", u.Line, u.Column, u.Message)
+}
+
+// IndexSuggestion is a composite index and the statements it serves
+type IndexSuggestion struct {
+	Table      string   `json:"table"`
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	Statements []int    `json:"statements"`
+}
+
+// SQL renders the CREATE INDEX statement of a suggestion
+func (s IndexSuggestion) SQL() string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s);", s.Name, s.Table, strings.Join(s.Columns, ", "))
+}
+
+// complete reports whether the existing indexes serve everything the
+// table access asks for
+func (t TableAdvice) complete() bool {
+	return (len(t.Unserved) == 0 || t.Unique) && (t.Sort == "" || t.Sorted) && len(t.Unindexable) == 0
+}
+
+// complete reports whether the existing indexes serve the whole statement
+func (a StatementAdvice) complete() bool {
+	for _, t := range a.Tables {
+		if !t.complete() {
+			return false
+		}
+	}
+	return true
+}
+
+// AdviseSQL checks every statement of sql that parses against the indexes
+// of catalog. DDL in sql, such as CREATE INDEX, updates the catalog as it
+// goes.
+func AdviseSQL(sql, file string, catalog *schemaCatalog) AdviseReport {
+	stmts, errs := parseStatements(sql, file)
+	report := AdviseReport{
+		File:         file,
+		Statements:   len(stmts) + len(errs),
+		Advice:       []StatementAdvice{},
+		Suggestions:  []IndexSuggestion{},
+		SyntaxErrors: errs,
+	}
+	for _, stmt := range stmts {
+		advice := StatementAdvice{Statement: stmt.Index, Tables: []TableAdvice{}}
+		advice.Line, _ = lineColumn(sql, stmt.Offset+leadingComments(stmt.Text))
+		for _, node := range stmt.Nodes {
+			a := &indexAdvisor{catalog: catalog, refs: resolveColumns(node, catalog), accesses: make(map[int]*tableAccess)}
+			a.statement(node)
+			for _, id := range a.order {
+				t := a.accesses[id].advise(stmt.Index)
+				for _, v := range a.accesses[id].unindexable {
+					line, col := lineColumn(sql, stmt.Offset+v.Offset)
+					t.Unindexable = append(t.Unindexable, UnindexableCondition{Line: line, Column: col, Message: v.Message})
+				}
+				advice.Tables = append(advice.Tables, t)
+			}
+			catalog.apply(node)
+		}
+		if len(advice.Tables) > 0 {
+			report.Advice = append(report.Advice, advice)
+		}
+	}
+	report.Suggestions = mergeSuggestions(report.Advice)
+	return report
+}
+
+// tableAccess collects what the query blocks of a statement ask of one
+// table source
+type tableAccess struct {
+	table *catalogTable
+	alias string
+	// equality and ranges are the columns of the conditions an index can
+	// serve, in order of appearance. lists are the equalities with several
+	// values, which an index serves but can't return in sort order.
+	equality []string
+	lists    []string
+	ranges   []string
+	// sort are the ORDER BY or GROUP BY columns of clause
+	sort   []string
+	clause string
+	// unindexable are conditions no index can serve
+	unindexable []Violation
+}
+
+// condKind is how an index can serve a condition
+type condKind int
+
+const (
+	// condEquality is one value: =, <=>, IS NULL or a single IN value
+	condEquality condKind = iota
+	// condList is several values: IN or ORed equalities
+	condList
+	condRange
+)
+
+// indexAdvisor gathers the table accesses of a statement
+type indexAdvisor struct {
+	catalog  *schemaCatalog
+	refs     columnRefs
+	accesses map[int]*tableAccess
+	// order lists the sources of accesses as they appear
+	order []int
+}
+
+// statement gathers the conditions and sorts of every query block
+func (a *indexAdvisor) statement(stmt ast.StmtNode) {
+	walk(stmt, func(n ast.Node) bool {
+		from, where := queryClauses(n)
+		if from == nil {
+			return false
+		}
+		for _, cond := range joinConditions(from, where) {
+			a.conjunct(cond)
+		}
+		switch s := n.(type) {
+		case *ast.SelectStmt:
+			if s.OrderBy != nil {
+				a.sort(s.OrderBy.Items, "ORDER BY", from)
+			} else if s.GroupBy != nil {
+				a.sort(s.GroupBy.Items, "GROUP BY", from)
+			}
+		case *ast.UpdateStmt:
+			if s.Order != nil {
+				a.sort(s.Order.Items, "ORDER BY", from)
+			}
+		case *ast.DeleteStmt:
+			if s.Order != nil {
+				a.sort(s.Order.Items, "ORDER BY", from)
+			}
+		}
+		return false
+	})
+}
+
+func (a *indexAdvisor) access(ref columnRef) *tableAccess {
+	acc := a.accesses[ref.Source]
+	if acc == nil {
+		acc = &tableAccess{table: ref.table, alias: ref.Alias}
+		a.accesses[ref.Source] = acc
+		a.order = append(a.order, ref.Source)
+	}
+	return acc
+}
+
+// ref returns the reference of a bare column of a schema table
+func (a *indexAdvisor) ref(e ast.ExprNode) (*ast.ColumnNameExpr, columnRef, bool) {
+	cn, ok := e.(*ast.ColumnNameExpr)
+	if !ok {
+		return nil, columnRef{}, false
+	}
+	ref, ok := a.refs[cn]
+	return cn, ref, ok && a.indexed(ref)
+}
+
+// indexed reports whether a reference is to a table of the catalog, as
+// opposed to a view, derived table or common table expression
+func (a *indexAdvisor) indexed(ref columnRef) bool {
+	return ref.table != nil && !ref.table.View && a.catalog.tables[strings.ToLower(ref.table.Name)] == ref.table
+}
+
+// columns lists the column references to schema tables in e, outside
+// its subqueries
+func (a *indexAdvisor) columns(e ast.ExprNode) []*ast.ColumnNameExpr {
+	var found []*ast.ColumnNameExpr
+	walk(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SubqueryExpr:
+			return true
+		case *ast.ColumnNameExpr:
+			if ref, ok := a.refs[n]; ok && a.indexed(ref) {
+				found = append(found, n)
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// touches reports whether e refers to a column of source
+func (a *indexAdvisor) touches(e ast.ExprNode, source int) bool {
+	return slices.ContainsFunc(a.columns(e), func(cn *ast.ColumnNameExpr) bool {
+		return a.refs[cn].Source == source
+	})
+}
+
+// conjunct records a condition ANDed to the others of a WHERE or ON
+func (a *indexAdvisor) conjunct(e ast.ExprNode) {
+	switch c := e.(type) {
+	case *ast.ParenthesesExpr:
+		a.conjunct(c.Expr)
+	case *ast.BinaryOperationExpr:
+		switch c.Op {
+		case opcode.LogicAnd:
+			a.conjunct(c.L)
+			a.conjunct(c.R)
+		case opcode.EQ, opcode.NullEQ:
+			a.compare(c.L, c.R, condEquality)
+		case opcode.LT, opcode.LE, opcode.GT, opcode.GE:
+			a.compare(c.L, c.R, condRange)
+		case opcode.NE:
+			a.negation(c, "<>")
+		case opcode.LogicOr:
+			a.or(c)
+		}
+	case *ast.PatternInExpr:
+		if c.Not {
+			a.negation(c.Expr, "NOT IN")
+			return
+		}
+		kind := condList
+		if len(c.List) == 1 {
+			kind = condEquality
+		}
+		a.operand(c.Expr, c.List, kind)
+	case *ast.BetweenExpr:
+		if c.Not {
+			a.negation(c.Expr, "NOT BETWEEN")
+			return
+		}
+		a.operand(c.Expr, []ast.ExprNode{c.Left, c.Right}, condRange)
+	case *ast.PatternLikeOrIlikeExpr:
+		if c.Not {
+			a.negation(c.Expr, "NOT LIKE")
+			return
+		}
+		if v, ok := c.Pattern.(ast.ValueExpr); ok {
+			if pattern, ok := v.GetValue().(string); ok && (strings.HasPrefix(pattern, "%") || strings.HasPrefix(pattern, "_")) {
+				if cn, ref, ok := a.ref(c.Expr); ok {
+					a.unindexable(cn, ref, "LIKE %s on %s starts with a wildcard and can't use an index", describeSQL(c.Pattern), ref.Column.Name)
+				}
+				return
+			}
+		}
+		a.operand(c.Expr, []ast.ExprNode{c.Pattern}, condRange)
+	case *ast.IsNullExpr:
+		// IS NOT NULL reads every non-null entry of the index
+		kind := condEquality
+		if c.Not {
+			kind = condRange
+		}
+		a.operand(c.Expr, nil, kind)
+	}
+}
+
+// compare records a comparison, which serves an index on either side
+func (a *indexAdvisor) compare(l, r ast.ExprNode, kind condKind) {
+	lcn, lref, lok := a.ref(l)
+	_, rref, rok := a.ref(r)
+	if lok && rok && lref.Source == rref.Source {
+		a.unindexable(lcn, lref, "comparing %s to %s of the same table can't use an index", lref.Column.Name, rref.Column.Name)
+		return
+	}
+	a.operand(l, []ast.ExprNode{r}, kind)
+	a.operand(r, []ast.ExprNode{l}, kind)
+}
+
+// operand records a condition on e that compares it to values. A column
+// compared to values of its own table, or inside an expression, can't
+// use an index.
+func (a *indexAdvisor) operand(e ast.ExprNode, values []ast.ExprNode, kind condKind) {
+	cn, ref, ok := a.ref(e)
+	if !ok {
+		a.wrapped(e)
+		return
+	}
+	for _, v := range values {
+		if a.touches(v, ref.Source) {
+			a.unindexable(cn, ref, "%s is compared to its own table's columns and can't use an index", ref.Column.Name)
+			return
+		}
+	}
+	acc := a.access(ref)
+	name := ref.Column.Name
+	other := func(col string) bool { return strings.EqualFold(col, name) }
+	switch {
+	case kind == condRange:
+		if !containsFold(acc.equality, name) && !containsFold(acc.ranges, name) {
+			acc.ranges = append(acc.ranges, name)
+		}
+		return
+	case !containsFold(acc.equality, name):
+		acc.equality = append(acc.equality, name)
+		acc.ranges = slices.DeleteFunc(acc.ranges, other)
+		if kind == condList {
+			acc.lists = append(acc.lists, name)
+		}
+	case kind == condEquality:
+		// One value narrows a list of them
+		acc.lists = slices.DeleteFunc(acc.lists, other)
+	}
+}
+
+// pinned reports whether an equality gives col a single value
+func (acc *tableAccess) pinned(col string) bool {
+	return containsFold(acc.equality, col) && !containsFold(acc.lists, col)
+}
+
+// wrapped reports the columns of a compared expression, such as DATE(d)
+func (a *indexAdvisor) wrapped(e ast.ExprNode) {
+	cols := a.columns(e)
+	if len(cols) == 0 {
+		return
+	}
+	ref := a.refs[cols[0]]
+	around := "an expression"
+	switch f := e.(type) {
+	case *ast.FuncCallExpr:
+		around = strings.ToUpper(f.FnName.O) + "()"
+	case *ast.FuncCastExpr:
+		around = "CAST()"
+	}
+	a.unindexable(cols[0], ref, "%s around %s can't use an index", around, ref.Column.Name)
+}
+
+// negation reports a negated condition, which matches most of an index
+func (a *indexAdvisor) negation(e ast.ExprNode, op string) {
+	if cols := a.columns(e); len(cols) > 0 {
+		ref := a.refs[cols[0]]
+		a.unindexable(cols[0], ref, "%s on %s can't use an index", op, ref.Column.Name)
+	}
+}
+
+// or records an OR of conditions on one column, such as a = 1 OR a = 2,
+// which an index serves like an IN list or several ranges. ORs across
+// columns need an index merge at best.
+func (a *indexAdvisor) or(e *ast.BinaryOperationExpr) {
+	var column *ast.ColumnNameExpr
+	kind := condList
+	for _, d := range disjuncts(e) {
+		cn, k, ok := a.single(d)
+		if !ok || (column != nil && (a.refs[column].Source != a.refs[cn].Source || !strings.EqualFold(a.refs[column].Column.Name, a.refs[cn].Column.Name))) {
+			column = nil
+			break
+		}
+		column = cn
+		if k == condRange {
+			kind = condRange
+		}
+	}
+	if column != nil {
+		a.operand(column, nil, kind)
+		return
+	}
+
+	cols := a.columns(e)
+	if len(cols) == 0 {
+		return
+	}
+	var names []string
+	for _, cn := range cols {
+		names = appendUnique(names, a.refs[cn].Column.Name)
+	}
+	a.unindexable(cols[0], a.refs[cols[0]], "OR of %s can't use a single index", strings.Join(names, ", "))
+}
+
+// single returns the column of a condition an index can serve on its own
+func (a *indexAdvisor) single(e ast.ExprNode) (*ast.ColumnNameExpr, condKind, bool) {
+	var operand ast.ExprNode
+	var values []ast.ExprNode
+	kind := condRange
+	switch c := e.(type) {
+	case *ast.ParenthesesExpr:
+		return a.single(c.Expr)
+	case *ast.BinaryOperationExpr:
+		switch c.Op {
+		case opcode.EQ, opcode.NullEQ:
+			kind = condList
+		case opcode.LT, opcode.LE, opcode.GT, opcode.GE:
+		default:
+			return nil, 0, false
+		}
+		operand, values = c.L, []ast.ExprNode{c.R}
+		if _, _, ok := a.ref(operand); !ok {
+			operand, values = c.R, []ast.ExprNode{c.L}
+		}
+	case *ast.PatternInExpr:
+		if c.Not {
+			return nil, 0, false
+		}
+		operand, values, kind = c.Expr, c.List, condList
+	case *ast.BetweenExpr:
+		if c.Not {
+			return nil, 0, false
+		}
+		operand, values = c.Expr, []ast.ExprNode{c.Left, c.Right}
+	default:
+		return nil, 0, false
+	}
+	cn, ref, ok := a.ref(operand)
+	if !ok || slices.ContainsFunc(values, func(v ast.ExprNode) bool { return a.touches(v, ref.Source) }) {
+		return nil, 0, false
+	}
+	return cn, kind, true
+}
+
+// disjuncts flattens the operands of nested ORs
+func disjuncts(e ast.ExprNode) []ast.ExprNode {
+	switch d := e.(type) {
+	case *ast.ParenthesesExpr:
+		return disjuncts(d.Expr)
+	case *ast.BinaryOperationExpr:
+		if d.Op == opcode.LogicOr {
+			return append(disjuncts(d.L), disjuncts(d.R)...)
+		}
+	}
+	return []ast.ExprNode{e}
+}
+
+func (a *indexAdvisor) unindexable(cn *ast.ColumnNameExpr, ref columnRef, format string, args ...any) {
+	acc := a.access(ref)
+	acc.unindexable = append(acc.unindexable, Violation{Offset: cn.OriginTextPosition(), Message: fmt.Sprintf(format, args...)})
+}
+
+// sort records an ORDER BY or GROUP BY an index can serve: bare columns
+// of the first table of the FROM clause, in one direction
+func (a *indexAdvisor) sort(items []*ast.ByItem, clause string, from *ast.Join) {
+	names := sourceNames(from)
+	if len(items) == 0 || len(names) == 0 {
+		return
+	}
+	var acc *tableAccess
+	var cols []string
+	for _, item := range items {
+		_, ref, ok := a.ref(item.Expr)
+		if !ok || !strings.EqualFold(ref.Alias, names[0]) || item.Desc != items[0].Desc {
+			return
+		}
+		acc = a.access(ref)
+		cols = appendUnique(cols, ref.Column.Name)
+	}
+	acc.sort, acc.clause = cols, clause
+}
+
+// advise matches the access against the table's indexes and suggests an
+// index when none serves it fully
+func (acc *tableAccess) advise(statement int) TableAdvice {
+	t := TableAdvice{Table: acc.table.Name, Served: []string{}, Unserved: []string{}, Unindexable: []UnindexableCondition{}}
+	if !strings.EqualFold(acc.alias, acc.table.Name) {
+		t.Alias = acc.alias
+	}
+
+	// Sort columns pinned to one value don't need to be in order
+	var sort []string
+	for _, col := range acc.sort {
+		if !acc.pinned(col) {
+			sort = append(sort, col)
+		}
+	}
+	if len(sort) > 0 {
+		t.Sort = acc.clause + " " + strings.Join(acc.sort, ", ")
+	}
+
+	var best *indexMatch
+	for i := range acc.table.Indexes {
+		m := acc.match(&acc.table.Indexes[i], sort)
+		if len(m.served) == 0 && !m.sorted {
+			continue
+		}
+		if best == nil || better(m, *best) {
+			best = &m
+		}
+	}
+	if best != nil {
+		t.Index, t.Unique, t.Sorted = best.index.Name, best.unique, best.sorted || best.unique
+	}
+	for _, col := range acc.equality {
+		kind := "equality"
+		if !acc.pinned(col) {
+			kind = "IN"
+		}
+		t.classify(best, col, kind)
+	}
+	for _, col := range acc.ranges {
+		t.classify(best, col, "range")
+	}
+
+	// Suggest an index only when it would serve more than the best one
+	if cols := acc.suggest(sort); cols != nil && !t.complete() {
+		m := acc.match(&catalogIndex{Columns: cols}, sort)
+		if best == nil || better(m, *best) {
+			t.Suggestion = &IndexSuggestion{Table: acc.table.Name, Name: indexName(acc.table.Name, cols), Columns: cols, Statements: []int{statement}}
+		}
+	}
+	return t
+}
+
+func (t *TableAdvice) classify(best *indexMatch, col, kind string) {
+	if best != nil && containsFold(best.served, col) {
+		t.Served = append(t.Served, col+" ("+kind+")")
+	} else {
+		t.Unserved = append(t.Unserved, col+" ("+kind+")")
+	}
+}
+
+// indexMatch is what one index serves of a table access
+type indexMatch struct {
+	index  *catalogIndex
+	served []string
+	// pinned counts the leading columns with a single value
+	pinned int
+	sorted bool
+	// unique is set when the index finds at most one row
+	unique bool
+}
+
+// better ranks a unique lookup first, then the index serving the most
+// conditions, the most of them single values, then one that also serves
+// the sort
+func better(m, best indexMatch) bool {
+	switch {
+	case m.unique != best.unique:
+		return m.unique
+	case len(m.served) != len(best.served):
+		return len(m.served) > len(best.served)
+	case m.pinned != best.pinned:
+		return m.pinned > best.pinned
+	}
+	return m.sorted && !best.sorted
+}
+
+// match applies the leftmost prefix rule: equalities on the leading
+// columns, then one range. The rows come in sort order when the sort
+// columns follow columns pinned to one value.
+func (acc *tableAccess) match(idx *catalogIndex, sort []string) indexMatch {
+	m := indexMatch{index: idx}
+	for i, col := range idx.Columns {
+		if containsFold(acc.equality, col) {
+			m.served = append(m.served, col)
+			if m.pinned == i && acc.pinned(col) {
+				m.pinned++
+			}
+			continue
+		}
+		if containsFold(acc.ranges, col) {
+			m.served = append(m.served, col)
+		}
+		break
+	}
+	m.sorted = len(sort) > 0 && hasPrefix(idx.Columns[m.pinned:], sort)
+	m.unique = idx.Unique && m.pinned == len(idx.Columns)
+	return m
+}
+
+// suggest orders the columns of a composite index for the access: the
+// equalities (single values first), then a range, then the sort columns
+// when the IN lists and range don't keep the index from serving them
+func (acc *tableAccess) suggest(sort []string) []string {
+	var cols []string
+	for _, col := range acc.equality {
+		if acc.pinned(col) {
+			cols = append(cols, col)
+		}
+	}
+	cols = append(cols, acc.lists...)
+	if len(acc.lists) > 0 {
+		sort = nil
+	}
+	if len(acc.ranges) > 0 {
+		r := acc.ranges[0]
+		if len(sort) > 0 && containsFold(acc.ranges, sort[0]) {
+			r = sort[0]
+		}
+		cols = append(cols, r)
+		if len(sort) == 0 || !strings.EqualFold(r, sort[0]) {
+			sort = nil
+		}
+	}
+	for _, col := range sort {
+		if !containsFold(cols, col) {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// indexName names a suggested index after its table and columns, within
+// MySQL's 64 character limit
+func indexName(table string, cols []string) string {
+	name := "idx_" + table + "_" + strings.Join(cols, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, s) })
+}
+
+// mergeSuggestions combines the suggestions of all statements. An index
+// folds into a longer one of the same table it leads, which serves its
+// statements as well.
+func mergeSuggestions(advice []StatementAdvice) []IndexSuggestion {
+	var all []IndexSuggestion
+	for _, a := range advice {
+		for _, t := range a.Tables {
+			if t.Suggestion != nil {
+				all = append(all, *t.Suggestion)
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return len(all[i].Columns) > len(all[j].Columns) })
+
+	merged := []IndexSuggestion{}
+	for _, s := range all {
+		i := slices.IndexFunc(merged, func(m IndexSuggestion) bool {
+			return strings.EqualFold(m.Table, s.Table) && hasPrefix(m.Columns, s.Columns)
+		})
+		if i < 0 {
+			s.Statements = slices.Clone(s.Statements)
+			merged = append(merged, s)
+			continue
+		}
+		for _, n := range s.Statements {
+			merged[i].Statements = appendUnique(merged[i].Statements, n)
+		}
+	}
+	for i := range merged {
+		slices.Sort(merged[i].Statements)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Statements[0] < merged[j].Statements[0] })
+	return merged
+}
+
+func runAdvise(cmd *cobra.Command, args []string) error {
+	switch adviseFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown format %q (valid: text, json)", adviseFormat)
+	}
+	if len(adviseSchemas) == 0 {
+		return fmt.Errorf("--schema is required: advise checks statements against the indexes of the schema's tables")
+	}
+
+	sql, err := getSQLInput(args)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	catalog, err := loadSchemaCatalog(adviseSchemas)
+	if err != nil {
+		return err
+	}
+
+	report := AdviseSQL(sql, fileInput, catalog)
+	if err := writeAdviseReport(os.Stdout, report, adviseFormat, adviseCorpus); err != nil {
+		return err
+	}
+	if len(report.SyntaxErrors) > 0 {
+		return fmt.Errorf("%s", checkSummary(checkReport{Statements: report.Statements, Failed: len(report.SyntaxErrors)}))
+	}
+	if adviseFormat == "text" {
+		fmt.Println(adviseSummary(report))
+	}
+	return nil
+}
+
+// writeAdviseReport prints the statements the indexes don't fully serve,
+// with a suggestion each, or the merged suggestions of the corpus
+func writeAdviseReport(w io.Writer, report AdviseReport, format string, corpus bool) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	for _, e := range report.SyntaxErrors {
+		fmt.Fprintln(w, formatSyntaxError(e))
+	}
+	if corpus {
+		if len(report.Suggestions) > 0 {
+			fmt.Fprintln(w, "Suggested indexes:")
+		}
+		for _, s := range report.Suggestions {
+			fmt.Fprintf(w, "  %s  -- %s %s\n", s.SQL(), plural(len(s.Statements), "statement", "statements"), joinInts(s.Statements))
+		}
+		return nil
+	}
+
+	for _, a := range report.Advice {
+		if a.complete() {
+			continue
+		}
+		location := fmt.Sprintf("line %d", a.Line)
+		if report.File != "" {
+			location = fmt.Sprintf("%s:%d", report.File, a.Line)
+		}
+		fmt.Fprintf(w, "%s: statement %d\n", location, a.Statement)
+		var suggested []string
+		for _, t := range a.Tables {
+			label := t.Table
+			if t.Alias != "" {
+				label += " " + t.Alias
+			}
+			if line := t.describe(); line != "" {
+				fmt.Fprintf(w, "  %s: %s\n", label, line)
+			}
+			for _, u := range t.Unindexable {
+				fmt.Fprintf(w, "  %s: %s\n", label, u.describe(a.Line))
+			}
+			if t.Suggestion != nil && !slices.Contains(suggested, t.Suggestion.SQL()) {
+				suggested = append(suggested, t.Suggestion.SQL())
+			}
+		}
+		for _, s := range suggested {
+			fmt.Fprintf(w, "  suggest: %s\n", s)
+		}
+	}
+	return nil
+}
+
+// describe renders what the best index of a table access serves and what
+// it leaves out, e.g. "idx_user serves user_id (equality); not served:
+// status (equality)"
+func (t TableAdvice) describe() string {
+	served := slices.Clone(t.Served)
+	if t.Sorted {
+		served = append(served, t.Sort)
+	}
+	var parts []string
+	switch {
+	case t.Unique:
+		parts = append(parts, t.Index+" finds at most one row by "+strings.Join(t.Served, ", "))
+	case t.Index != "":
+		parts = append(parts, t.Index+" serves "+strings.Join(served, ", "))
+		if len(t.Unserved) > 0 {
+			parts = append(parts, "not served: "+strings.Join(t.Unserved, ", "))
+		}
+	case len(t.Unserved) > 0:
+		parts = append(parts, "no index serves "+strings.Join(t.Unserved, ", ")+", so every row is read")
+	}
+	if t.Sort != "" && !t.Sorted {
+		parts = append(parts, t.Sort+" needs a filesort")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// plural picks the singular or plural noun for n, without the count
+func plural(n int, one, several string) string {
+	if n == 1 {
+		return one
+	}
+	return several
+}
+
+// adviseSummary counts the statements the indexes don't fully serve, e.g.
+// "2 of 5 statements aren't fully served by indexes; 2 indexes suggested"
+func adviseSummary(report AdviseReport) string {
+	n := 0
+	for _, a := range report.Advice {
+		if !a.complete() {
+			n++
+		}
+	}
+	if n == 0 {
+		return fmt.Sprintf("✓ Existing indexes serve %s", counted(report.Statements, "statement", "statements"))
+	}
+	return fmt.Sprintf("%d of %s %s fully served by indexes; %s suggested",
+		n, counted(report.Statements, "statement", "statements"), plural(n, "isn't", "aren't"), counted(len(report.Suggestions), "index", "indexes"))
+}
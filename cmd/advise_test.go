@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const adviseSchema = `
+CREATE TABLE users (
+  id INT PRIMARY KEY,
+  email VARCHAR(255) UNIQUE,
+  status VARCHAR(16),
+  created_at DATETIME
+);
+CREATE TABLE orders (
+  id BIGINT PRIMARY KEY,
+  user_id INT,
+  status VARCHAR(16),
+  created_at DATETIME,
+  KEY idx_user (user_id),
+  KEY idx_status_created (status, created_at)
+);
+CREATE VIEW recent AS SELECT id, user_id FROM orders;
+`
+
+func TestAdviseSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		// want lists each table access as described, then its
+		// unindexable conditions and suggestion
+		want []string
+	}{
+		{"primary key lookup", "SELECT * FROM users WHERE id = 7 AND status = 'x'", []string{
+			"users: PRIMARY finds at most one row by id (equality)",
+		}},
+		{"unserved equality and sort", "SELECT * FROM orders WHERE user_id = 7 AND status = 'paid' ORDER BY created_at", []string{
+			"orders: idx_status_created serves status (equality), ORDER BY created_at; not served: user_id (equality)",
+			"suggest: CREATE INDEX idx_orders_user_id_status_created_at ON orders (user_id, status, created_at);",
+		}},
+		{"served by a composite", "SELECT * FROM orders WHERE status = 'paid' AND created_at >= '2024-01-01' ORDER BY created_at", []string{
+			"orders: idx_status_created serves status (equality), created_at (range), ORDER BY created_at",
+		}},
+		{"range ahead of the sort", "SELECT * FROM orders WHERE user_id = 7 AND id > 100 ORDER BY created_at DESC", []string{
+			"orders: idx_user serves user_id (equality); not served: id (range); ORDER BY created_at needs a filesort",
+			"suggest: CREATE INDEX idx_orders_user_id_id ON orders (user_id, id);",
+		}},
+		{"no index", "UPDATE users SET status = 'x' WHERE created_at < '2020-01-01'", []string{
+			"users: no index serves created_at (range), so every row is read",
+			"suggest: CREATE INDEX idx_users_created_at ON users (created_at);",
+		}},
+		{"in list keeps the sort out", "SELECT status FROM orders WHERE user_id IN (1, 2) OR user_id = 3 GROUP BY status", []string{
+			"orders: idx_user serves user_id (IN); GROUP BY status needs a filesort",
+		}},
+		{"pinned sort column", "SELECT * FROM orders WHERE status = 'x' ORDER BY status", []string{
+			"orders: idx_status_created serves status (equality)",
+		}},
+		{"join columns", "SELECT o.id FROM orders o JOIN users u ON u.email = o.status WHERE o.created_at > NOW()", []string{
+			"orders o: idx_status_created serves status (equality), created_at (range)",
+			"users u: email finds at most one row by email (equality)",
+		}},
+		{"unindexable", "SELECT * FROM users WHERE DATE(created_at) = '2024-01-01' AND status <> 'x' AND email LIKE '%@x.com' AND id + 1 = 5", []string{
+			"users: column 32: DATE() around created_at can't use an index",
+			"users: column 63: <> on status can't use an index",
+			"users: column 81: LIKE '%@x.com' on email starts with a wildcard and can't use an index",
+			"users: column 106: an expression around id can't use an index",
+		}},
+		{"condition on a later line", "SELECT * FROM users\nWHERE DATE(created_at) = '2024-01-01'", []string{
+			"users: line 2, column 12: DATE() around created_at can't use an index",
+		}},
+		{"or across columns", "DELETE FROM users WHERE email = 'a' OR status = 'b'", []string{
+			"users: column 25: OR of email, status can't use a single index",
+		}},
+		{"same table columns", "SELECT * FROM orders WHERE user_id = id", []string{
+			"orders: column 28: comparing user_id to id of the same table can't use an index",
+		}},
+		{"views and derived tables", "SELECT * FROM recent r JOIN (SELECT id FROM users) d ON d.id = r.id", nil},
+		{"subquery", "SELECT * FROM users WHERE id IN (SELECT user_id FROM orders WHERE created_at > NOW())", []string{
+			"users: PRIMARY serves id (IN)",
+			"orders: no index serves created_at (range), so every row is read",
+			"suggest: CREATE INDEX idx_orders_created_at ON orders (created_at);",
+		}},
+		{"existing prefix", "SELECT * FROM orders WHERE status = 'x' AND user_id > 1 AND created_at > NOW()", []string{
+			"orders: idx_status_created serves status (equality), created_at (range); not served: user_id (range)",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := AdviseSQL(tt.sql, "", testCatalog(t, adviseSchema))
+			var got []string
+			for _, a := range report.Advice {
+				for _, ta := range a.Tables {
+					label := ta.Table
+					if ta.Alias != "" {
+						label += " " + ta.Alias
+					}
+					if line := ta.describe(); line != "" {
+						got = append(got, label+": "+line)
+					}
+					for _, u := range ta.Unindexable {
+						got = append(got, label+": "+u.describe(a.Line))
+					}
+					if ta.Suggestion != nil {
+						got = append(got, "suggest: "+ta.Suggestion.SQL())
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AdviseSQL() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestAdviseSQLAppliesDDL(t *testing.T) {
+	sql := "SELECT * FROM orders WHERE created_at > NOW();\n" +
+		"CREATE INDEX idx_created ON orders (created_at);\n" +
+		"SELECT * FROM orders WHERE created_at > NOW()"
+
+	report := AdviseSQL(sql, "q.sql", testCatalog(t, adviseSchema))
+	if len(report.Advice) != 2 {
+		t.Fatalf("Advice = %+v, want 2 statements", report.Advice)
+	}
+	if a := report.Advice[0]; a.complete() || a.Line != 1 {
+		t.Errorf("statement 1 = %+v, want unserved on line 1", a)
+	}
+	if a := report.Advice[1]; !a.complete() || a.Statement != 3 || a.Line != 3 || a.Tables[0].Index != "idx_created" {
+		t.Errorf("statement 3 = %+v, want served by idx_created on line 3", a)
+	}
+}
+
+func TestMergeSuggestions(t *testing.T) {
+	sql := "SELECT * FROM users WHERE status = 'a';\n" +
+		"SELECT * FROM users WHERE status = 'a' AND created_at > NOW();\n" +
+		"SELECT * FROM orders WHERE user_id = 1 AND created_at > NOW();\n" +
+		"SELECT * FROM users WHERE created_at > NOW();\n" +
+		"SELECT * FROM users WHERE status = 'b' ORDER BY created_at"
+
+	report := AdviseSQL(sql, "", testCatalog(t, adviseSchema))
+	var got []string
+	for _, s := range report.Suggestions {
+		got = append(got, s.SQL()+" "+joinInts(s.Statements))
+	}
+	want := []string{
+		"CREATE INDEX idx_users_status_created_at ON users (status, created_at); 1, 2, 5",
+		"CREATE INDEX idx_orders_user_id_created_at ON orders (user_id, created_at); 3",
+		"CREATE INDEX idx_users_created_at ON users (created_at); 4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggestions =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	if got := indexName("orders", []string{"user_id", "status"}); got != "idx_orders_user_id_status" {
+		t.Errorf("indexName() = %q", got)
+	}
+	long := indexName("a_table_with_a_long_name", []string{"first_long_column", "second_long_column", "third"})
+	if len(long) != 64 || !strings.HasPrefix(long, "idx_a_table_with_a_long_name_first_long_column_") {
+		t.Errorf("indexName() = %q (%d), want 64 characters", long, len(long))
+	}
+}
+
+func TestWriteAdviseReport(t *testing.T) {
+	sql := "SELECT * FROM users WHERE id = 1;\n" +
+		"SELECT * FROM orders WHERE user_id = 7 ORDER BY created_at;\n" +
+		"SELECT * FROM orders o WHERE o.user_id = 8 AND DATE(o.created_at) = '2024-01-01'"
+	report := AdviseSQL(sql, "q.sql", testCatalog(t, adviseSchema))
+
+	tests := []struct {
+		format string
+		corpus bool
+		want   string
+	}{
+		{"text", false, "q.sql:2: statement 2\n" +
+			"  orders: idx_user serves user_id (equality); ORDER BY created_at needs a filesort\n" +
+			"  suggest: CREATE INDEX idx_orders_user_id_created_at ON orders (user_id, created_at);\n" +
+			"q.sql:3: statement 3\n" +
+			"  orders o: idx_user serves user_id (equality)\n" +
+			"  orders o: column 53: DATE() around created_at can't use an index\n"},
+		{"text", true, "Suggested indexes:\n" +
+			"  CREATE INDEX idx_orders_user_id_created_at ON orders (user_id, created_at);  -- statement 2\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeAdviseReport(&buf, report, tt.format, tt.corpus); err != nil {
+			t.Fatalf("writeAdviseReport() error = %v", err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("writeAdviseReport(%s, corpus=%v) =\n%s\nwant\n%s", tt.format, tt.corpus, buf.String(), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeAdviseReport(&buf, report, "json", false); err != nil {
+		t.Fatalf("writeAdviseReport() error = %v", err)
+	}
+	for _, want := range []string{`"statements": 3`, `"index": "PRIMARY"`, `"unique": true`, `"sort": "ORDER BY created_at"`, `"line": 3`, `"column": 53`, `"message": "DATE() around created_at can't use an index"`, `"suggestions": [`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("json output missing %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestAdviseSummary(t *testing.T) {
+	catalog := testCatalog(t, adviseSchema)
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM users WHERE id = 1; SELECT 1", "✓ Existing indexes serve 2 statements"},
+		{"SELECT * FROM users WHERE status = 'a'; SELECT * FROM users WHERE id = 1", "1 of 2 statements isn't fully served by indexes; 1 index suggested"},
+		{"SELECT * FROM users WHERE status = 'a'; SELECT * FROM orders WHERE created_at > NOW()", "2 of 2 statements aren't fully served by indexes; 2 indexes suggested"},
+	}
+	for _, tt := range tests {
+		if got := adviseSummary(AdviseSQL(tt.sql, "", catalog)); got != tt.want {
+			t.Errorf("adviseSummary(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/spf13/cobra"
+)
+
+// maxTableNameLen is MySQL's identifier length limit
+const maxTableNameLen = 64
+
+var backupSuffix string
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [sql-statement]",
+	Short: "Generate in-database backup tables for UPDATE and DELETE",
+	Long: `Generate CREATE TABLE ... AS SELECT statements that copy the rows an
+UPDATE or DELETE is about to change into a backup table, plus the statement
+that restores them.
+
+The backup SELECT reuses the statement's own table references, joins, WHERE,
+ORDER BY and LIMIT. UPDATEs are restored with REPLACE INTO, DELETEs with
+INSERT IGNORE INTO.
+
+Examples:
+  dbsqlx backup "DELETE FROM orders WHERE status = 'cancelled'"
+  dbsqlx backup -f migration.sql --suffix 20240101`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackup,
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+
+	backupCmd.Flags().StringVar(&backupSuffix, "suffix", "", "Backup table suffix after _bak_ (default: current timestamp)")
+}
+
+// tableBackup is the backup and restore SQL for one modified table
+type tableBackup struct {
+	Table       string
+	BackupTable string
+	Create      string
+	Restore     string
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	sql, err := getSQLInput(args)
+	if err != nil {
+		return err
+	}
+
+	stmtNodes, err := ParseAll(sql)
+	if err != nil {
+		return fmt.Errorf("parse error: %v", err)
+	}
+
+	suffix := strings.TrimSpace(backupSuffix)
+	if suffix == "" {
+		suffix = time.Now().Format("20060102150405")
+	}
+
+	for idx, stmtNode := range stmtNodes {
+		if idx > 0 {
+			fmt.Println()
+		}
+
+		backups, err := buildBackups(stmtNode, suffix)
+		if err != nil {
+			return fmt.Errorf("statement %d: %v", idx+1, err)
+		}
+		if backups == nil {
+			_, _, action, _, _ := Extract(&stmtNode)
+			if action == "" {
+				action = "this"
+			}
+			fmt.Printf("-- Statement %d: skipping %s statement (backup only handles UPDATE and DELETE)\n", idx+1, action)
+			continue
+		}
+
+		for _, b := range backups {
+			fmt.Printf("-- Statement %d: back up %s\n", idx+1, b.Table)
+			fmt.Println(b.Create + ";")
+			fmt.Println("-- Restore:")
+			fmt.Println("-- " + b.Restore + ";")
+		}
+	}
+
+	return nil
+}
+
+// buildBackups returns the backups for every table an UPDATE or DELETE
+// modifies, or nil for other statements
+func buildBackups(stmtNode ast.StmtNode, suffix string) ([]tableBackup, error) {
+	var (
+		refs     *ast.TableRefsClause
+		where    ast.ExprNode
+		order    *ast.OrderByClause
+		limit    *ast.Limit
+		targets  []string
+		restorer string
+	)
+
+	switch stmt := stmtNode.(type) {
+	case *ast.UpdateStmt:
+		refs, where, order, limit = stmt.TableRefs, stmt.Where, stmt.Order, stmt.Limit
+		restorer = "REPLACE INTO"
+		for _, assignment := range stmt.List {
+			targets = append(targets, assignment.Column.Table.L)
+		}
+	case *ast.DeleteStmt:
+		refs, where, order, limit = stmt.TableRefs, stmt.Where, stmt.Order, stmt.Limit
+		restorer = "INSERT IGNORE INTO"
+		if stmt.IsMultiTable && stmt.Tables != nil {
+			for _, tn := range stmt.Tables.Tables {
+				targets = append(targets, tn.Name.L)
+			}
+		} else {
+			targets = append(targets, "")
+		}
+	default:
+		return nil, nil
+	}
+
+	if refs == nil || refs.TableRefs == nil {
+		return nil, fmt.Errorf("statement has no table references")
+	}
+	sources := collectTableSources(refs.TableRefs)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("statement has no base tables")
+	}
+
+	from := restoreSQL(refs.TableRefs)
+	tail := ""
+	if where != nil {
+		tail += " WHERE " + restoreSQL(where)
+	}
+	if order != nil {
+		tail += " " + restoreSQL(order)
+	}
+	if limit != nil {
+		tail += " " + restoreSQL(limit)
+	}
+
+	var backups []tableBackup
+	seen := make(map[*ast.TableSource]bool)
+	for _, target := range targets {
+		// Unqualified targets belong to the first table
+		source := sources[0]
+		if target != "" {
+			source = findTableSource(sources, target)
+			if source == nil {
+				return nil, fmt.Errorf("unknown table %q", target)
+			}
+		}
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+
+		tn := source.Source.(*ast.TableName)
+		table := qualifiedTableName(tn.Schema.O, tn.Name.O)
+		backupTable := qualifiedTableName(tn.Schema.O, backupTableName(tn.Name.O, suffix))
+
+		qualifier := quoteTableName(table)
+		if source.AsName.O != "" {
+			qualifier = quoteIdent(source.AsName.O)
+		}
+		distinct := ""
+		if len(sources) > 1 {
+			// Joins may repeat a row once per matching row of another table
+			distinct = "DISTINCT "
+		}
+
+		backups = append(backups, tableBackup{
+			Table:       table,
+			BackupTable: backupTable,
+			Create:      fmt.Sprintf("CREATE TABLE %s AS SELECT %s%s.* FROM %s%s", quoteTableName(backupTable), distinct, qualifier, from, tail),
+			Restore:     fmt.Sprintf("%s %s SELECT * FROM %s", restorer, quoteTableName(table), quoteTableName(backupTable)),
+		})
+	}
+
+	return backups, nil
+}
+
+// backupTableName appends _bak_<suffix>, shortening the table name so the
+// result stays within MySQL's identifier length limit
+func backupTableName(table, suffix string) string {
+	tail := "_bak_" + suffix
+	if len(table)+len(tail) > maxTableNameLen && len(tail) < maxTableNameLen {
+		table = table[:maxTableNameLen-len(tail)]
+	}
+	return table + tail
+}
+
+// qualifiedTableName prefixes the schema when there is one
+func qualifiedTableName(schema, table string) string {
+	if schema == "" {
+		return table
+	}
+	return schema + "." + table
+}
+
+// collectTableSources returns the base-table sources of a join tree in
+// order of appearance
+func collectTableSources(node ast.ResultSetNode) []*ast.TableSource {
+	switch n := node.(type) {
+	case *ast.Join:
+		sources := collectTableSources(n.Left)
+		if n.Right != nil {
+			sources = append(sources, collectTableSources(n.Right)...)
+		}
+		return sources
+	case *ast.TableSource:
+		if _, ok := n.Source.(*ast.TableName); ok {
+			return []*ast.TableSource{n}
+		}
+	}
+	return nil
+}
+
+// findTableSource resolves an alias or table name (lower case) to its source
+func findTableSource(sources []*ast.TableSource, name string) *ast.TableSource {
+	for _, source := range sources {
+		if source.AsName.L == name {
+			return source
+		}
+	}
+	for _, source := range sources {
+		if source.AsName.L == "" && source.Source.(*ast.TableName).Name.L == name {
+			return source
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildBackups(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []tableBackup
+	}{
+		{
+			name: "Single-table UPDATE",
+			sql:  "UPDATE users SET name = 'Jane' WHERE id = 1",
+			want: []tableBackup{{
+				Table:       "users",
+				BackupTable: "users_bak_ts",
+				Create:      "CREATE TABLE `users_bak_ts` AS SELECT `users`.* FROM `users` WHERE `id`=1",
+				Restore:     "REPLACE INTO `users` SELECT * FROM `users_bak_ts`",
+			}},
+		},
+		{
+			name: "DELETE keeps ORDER BY and LIMIT",
+			sql:  "DELETE FROM logs WHERE created < '2024-01-01' ORDER BY id LIMIT 1000",
+			want: []tableBackup{{
+				Table:       "logs",
+				BackupTable: "logs_bak_ts",
+				Create:      "CREATE TABLE `logs_bak_ts` AS SELECT `logs`.* FROM `logs` WHERE `created`<'2024-01-01' ORDER BY `id` LIMIT 1000",
+				Restore:     "INSERT IGNORE INTO `logs` SELECT * FROM `logs_bak_ts`",
+			}},
+		},
+		{
+			name: "UPDATE with JOIN backs up every assigned table",
+			sql:  "UPDATE Employees e JOIN Departments d ON e.DepartmentID = d.DepartmentID SET e.Salary = 1, d.Budget = 0 WHERE d.DepartmentName = 'Sales'",
+			want: []tableBackup{
+				{
+					Table:       "Employees",
+					BackupTable: "Employees_bak_ts",
+					Create:      "CREATE TABLE `Employees_bak_ts` AS SELECT DISTINCT `e`.* FROM `Employees` AS `e` JOIN `Departments` AS `d` ON `e`.`DepartmentID`=`d`.`DepartmentID` WHERE `d`.`DepartmentName`='Sales'",
+					Restore:     "REPLACE INTO `Employees` SELECT * FROM `Employees_bak_ts`",
+				},
+				{
+					Table:       "Departments",
+					BackupTable: "Departments_bak_ts",
+					Create:      "CREATE TABLE `Departments_bak_ts` AS SELECT DISTINCT `d`.* FROM `Employees` AS `e` JOIN `Departments` AS `d` ON `e`.`DepartmentID`=`d`.`DepartmentID` WHERE `d`.`DepartmentName`='Sales'",
+					Restore:     "REPLACE INTO `Departments` SELECT * FROM `Departments_bak_ts`",
+				},
+			},
+		},
+		{
+			name: "Multi-table DELETE with schema",
+			sql:  "DELETE u FROM shop.users u JOIN bans b ON u.id = b.user_id WHERE b.active = 1",
+			want: []tableBackup{{
+				Table:       "shop.users",
+				BackupTable: "shop.users_bak_ts",
+				Create:      "CREATE TABLE `shop`.`users_bak_ts` AS SELECT DISTINCT `u`.* FROM `shop`.`users` AS `u` JOIN `bans` AS `b` ON `u`.`id`=`b`.`user_id` WHERE `b`.`active`=1",
+				Restore:     "INSERT IGNORE INTO `shop`.`users` SELECT * FROM `shop`.`users_bak_ts`",
+			}},
+		},
+		{
+			name: "Reserved names stay quoted",
+			sql:  "DELETE FROM `order` WHERE `key` = 1",
+			want: []tableBackup{{
+				Table:       "order",
+				BackupTable: "order_bak_ts",
+				Create:      "CREATE TABLE `order_bak_ts` AS SELECT `order`.* FROM `order` WHERE `key`=1",
+				Restore:     "INSERT IGNORE INTO `order` SELECT * FROM `order_bak_ts`",
+			}},
+		},
+		{
+			name: "SELECT is skipped",
+			sql:  "SELECT * FROM users",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			got, err := buildBackups(stmtNodes[0], "ts")
+			if err != nil {
+				t.Fatalf("buildBackups() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildBackups() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupTableName(t *testing.T) {
+	if got := backupTableName("orders", "20240101"); got != "orders_bak_20240101" {
+		t.Errorf("backupTableName() = %q", got)
+	}
+
+	long := strings.Repeat("x", 60)
+	got := backupTableName(long, "20240101120000")
+	if len(got) != maxTableNameLen {
+		t.Errorf("backupTableName() length = %d, want %d", len(got), maxTableNameLen)
+	}
+	if !strings.HasSuffix(got, "_bak_20240101120000") {
+		t.Errorf("backupTableName() = %q, lost suffix", got)
+	}
+}
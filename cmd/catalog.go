@@ -0,0 +1,1050 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/types"
+)
+
+// schemaCatalog holds the tables and views of a schema, built from its
+// CREATE TABLE and CREATE VIEW statements (and any ALTER, RENAME or DROP
+// after them), for checking statements against
+type schemaCatalog struct {
+	tables map[string]*catalogTable
+}
+
+// catalogTable is a table, view or derived table with its columns in order
+type catalogTable struct {
+	Name    string
+	View    bool
+	Columns []catalogColumn
+	// Indexes are the B-tree indexes of a table, primary key first
+	Indexes []catalogIndex
+	// Open is set when the columns aren't all known, e.g. for views over
+	// tables outside the catalog; references into it aren't checked
+	Open bool
+	// Collation is the default of the table's string columns, "" when the
+	// server default applies
+	Collation string
+}
+
+type catalogColumn struct {
+	Name      string
+	Generated bool
+	// Stored is set for generated columns that are stored, not virtual
+	Stored  bool
+	NotNull bool
+	// Type is nil for columns of expressions
+	Type *types.FieldType
+	// Collation is the effective collation of a string column, "" when the
+	// server default applies
+	Collation string
+}
+
+// catalogIndex is an index of a table. Columns stop at the first
+// expression part, since only the columns ahead of it can be matched.
+type catalogIndex struct {
+	Name    string
+	Columns []string
+	Primary bool
+	Unique  bool
+}
+
+func newSchemaCatalog() *schemaCatalog {
+	return &schemaCatalog{tables: make(map[string]*catalogTable)}
+}
+
+// column finds a column case-insensitively, or returns nil
+func (t *catalogTable) column(name string) *catalogColumn {
+	for i := range t.Columns {
+		if strings.EqualFold(t.Columns[i].Name, name) {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// loadSchemaCatalog builds a catalog from schema files. Each path is a file,
+// a directory of .sql files or a glob; files are read in name order.
+func loadSchemaCatalog(paths []string) (*schemaCatalog, error) {
+	files, err := schemaFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	c := newSchemaCatalog()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading schema file: %v", err)
+		}
+		stmts, errs := parseStatements(string(content), file)
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("schema syntax error: %v", errs[0])
+		}
+		for _, stmt := range stmts {
+			for _, node := range stmt.Nodes {
+				c.apply(node)
+			}
+		}
+	}
+	if len(c.tables) == 0 {
+		return nil, fmt.Errorf("no CREATE TABLE or CREATE VIEW statements in %s", strings.Join(files, ", "))
+	}
+	return c, nil
+}
+
+// schemaFiles expands the --schema paths into files
+func schemaFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		if strings.ContainsAny(p, "*?[") {
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema glob %q: %v", p, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no schema files match %s", p)
+			}
+			files = append(files, matches...)
+			continue
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading schema file: %v", err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(p, "*.sql"))
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no .sql files in schema directory %s", p)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// apply records the effect of a DDL statement; other statements are ignored
+func (c *schemaCatalog) apply(node ast.StmtNode) {
+	switch stmt := node.(type) {
+	case *ast.CreateTableStmt:
+		if stmt.IfNotExists && c.tables[stmt.Table.Name.L] != nil {
+			return
+		}
+		t := &catalogTable{Name: stmt.Table.Name.O, Collation: tableCollation(stmt.Options)}
+		if stmt.ReferTable != nil {
+			if refer := c.tables[stmt.ReferTable.Name.L]; refer != nil {
+				t.Columns, t.Open, t.Collation = slices.Clone(refer.Columns), refer.Open, refer.Collation
+				for _, idx := range refer.Indexes {
+					idx.Columns = slices.Clone(idx.Columns)
+					t.Indexes = append(t.Indexes, idx)
+				}
+			} else {
+				t.Open = true
+			}
+		}
+		for _, col := range stmt.Cols {
+			t.Columns = append(t.Columns, newCatalogColumn(col, t.Collation))
+			t.addColumnKeys(col)
+		}
+		for _, constraint := range stmt.Constraints {
+			t.addConstraint(constraint)
+		}
+		if stmt.Select != nil {
+			// CREATE TABLE ... SELECT adds the columns the query returns
+			// that the definition doesn't declare
+			out := (&resolver{catalog: c}).query(stmt.Select, nil)
+			for _, col := range out.Columns {
+				if t.column(col.Name) == nil {
+					t.Columns = append(t.Columns, catalogColumn{Name: col.Name})
+				}
+			}
+			t.Open = t.Open || out.Open
+		}
+		c.tables[stmt.Table.Name.L] = t
+	case *ast.CreateViewStmt:
+		out := &catalogTable{Open: true}
+		if sel, ok := stmt.Select.(ast.ResultSetNode); ok {
+			out = (&resolver{catalog: c}).query(sel, nil)
+		}
+		t := &catalogTable{Name: stmt.ViewName.Name.O, View: true, Columns: out.Columns, Open: out.Open}
+		if len(stmt.Cols) > 0 {
+			t.Columns, t.Open = nil, false
+			for _, col := range stmt.Cols {
+				t.Columns = append(t.Columns, catalogColumn{Name: col.O})
+			}
+		}
+		c.tables[stmt.ViewName.Name.L] = t
+	case *ast.DropTableStmt:
+		for _, tn := range stmt.Tables {
+			delete(c.tables, tn.Name.L)
+		}
+	case *ast.RenameTableStmt:
+		for _, tt := range stmt.TableToTables {
+			c.rename(tt.OldTable.Name.L, tt.NewTable.Name.O)
+		}
+	case *ast.AlterTableStmt:
+		name := stmt.Table.Name.L
+		t := c.tables[name]
+		if t == nil {
+			return
+		}
+		for _, spec := range stmt.Specs {
+			switch spec.Tp {
+			case ast.AlterTableAddColumns:
+				for _, col := range spec.NewColumns {
+					t.placeColumn(newCatalogColumn(col, t.Collation), spec.Position)
+					t.addColumnKeys(col)
+				}
+			case ast.AlterTableDropColumn:
+				t.dropColumn(spec.OldColumnName.Name.O)
+			case ast.AlterTableChangeColumn, ast.AlterTableModifyColumn:
+				if len(spec.NewColumns) == 0 {
+					continue
+				}
+				old := spec.NewColumns[0].Name.Name.O
+				if spec.OldColumnName != nil {
+					old = spec.OldColumnName.Name.O
+				}
+				t.replaceColumn(old, newCatalogColumn(spec.NewColumns[0], t.Collation), spec.Position)
+				t.addColumnKeys(spec.NewColumns[0])
+			case ast.AlterTableRenameColumn:
+				if col := t.column(spec.OldColumnName.Name.O); col != nil {
+					t.renameIndexColumn(col.Name, spec.NewColumnName.Name.O)
+					col.Name = spec.NewColumnName.Name.O
+				}
+			case ast.AlterTableAddConstraint:
+				t.addConstraint(spec.Constraint)
+			case ast.AlterTableDropIndex:
+				t.dropIndex(spec.Name)
+			case ast.AlterTableDropPrimaryKey:
+				t.dropIndex("PRIMARY")
+			case ast.AlterTableRenameIndex:
+				if idx := t.index(spec.FromKey.O); idx != nil {
+					idx.Name = spec.ToKey.O
+				}
+			case ast.AlterTableRenameTable:
+				c.rename(name, spec.NewTable.Name.O)
+				name = spec.NewTable.Name.L
+			}
+		}
+	case *ast.CreateIndexStmt:
+		if t := c.tables[stmt.Table.Name.L]; t != nil && (!stmt.IfNotExists || t.index(stmt.IndexName) == nil) {
+			switch stmt.KeyType {
+			case ast.IndexKeyTypeNone, ast.IndexKeyTypeUnique:
+				t.addIndex(stmt.IndexName, stmt.IndexPartSpecifications, false, stmt.KeyType == ast.IndexKeyTypeUnique)
+			}
+		}
+	case *ast.DropIndexStmt:
+		if t := c.tables[stmt.Table.Name.L]; t != nil {
+			t.dropIndex(stmt.IndexName)
+		}
+	}
+}
+
+// newCatalogColumn records a column definition. String columns without a
+// charset or collation of their own take the table's collation.
+func newCatalogColumn(def *ast.ColumnDef, tableCollation string) catalogColumn {
+	col := catalogColumn{Name: def.Name.Name.O, Type: def.Tp}
+	collate := def.Tp.GetCollate()
+	for _, opt := range def.Options {
+		switch opt.Tp {
+		case ast.ColumnOptionGenerated:
+			col.Generated, col.Stored = true, opt.Stored
+		case ast.ColumnOptionNotNull, ast.ColumnOptionPrimaryKey:
+			col.NotNull = true
+		case ast.ColumnOptionNull:
+			col.NotNull = false
+		case ast.ColumnOptionCollate:
+			collate = opt.StrValue
+		}
+	}
+	if typeClass(def.Tp) == classString {
+		switch {
+		case collate != "":
+			col.Collation = strings.ToLower(collate)
+		case def.Tp.GetCharset() != "":
+			col.Collation = defaultCollation(def.Tp.GetCharset())
+		default:
+			col.Collation = tableCollation
+		}
+	}
+	return col
+}
+
+// tableCollation returns the default collation CREATE TABLE options set
+func tableCollation(options []*ast.TableOption) string {
+	var charset, collate string
+	for _, opt := range options {
+		switch opt.Tp {
+		case ast.TableOptionCharset:
+			charset = opt.StrValue
+		case ast.TableOptionCollate:
+			collate = opt.StrValue
+		}
+	}
+	if collate != "" {
+		return strings.ToLower(collate)
+	}
+	if charset != "" {
+		return defaultCollation(charset)
+	}
+	return ""
+}
+
+// defaultCollation returns the collation MySQL 8.0 uses for a charset
+func defaultCollation(charset string) string {
+	switch charset = strings.ToLower(charset); charset {
+	case "utf8mb4":
+		return "utf8mb4_0900_ai_ci"
+	case "utf8", "utf8mb3":
+		return "utf8mb3_general_ci"
+	case "latin1":
+		return "latin1_swedish_ci"
+	case "binary":
+		return "binary"
+	}
+	return charset + "_general_ci"
+}
+
+func (c *schemaCatalog) rename(old, name string) {
+	if t := c.tables[old]; t != nil {
+		delete(c.tables, old)
+		t.Name = name
+		c.tables[strings.ToLower(name)] = t
+	}
+}
+
+// placeColumn adds a column at the end, first or after another column
+func (t *catalogTable) placeColumn(col catalogColumn, pos *ast.ColumnPosition) {
+	i := len(t.Columns)
+	if pos != nil {
+		switch pos.Tp {
+		case ast.ColumnPositionFirst:
+			i = 0
+		case ast.ColumnPositionAfter:
+			for j := range t.Columns {
+				if strings.EqualFold(t.Columns[j].Name, pos.RelativeColumn.Name.O) {
+					i = j + 1
+				}
+			}
+		}
+	}
+	t.Columns = slices.Insert(t.Columns, i, col)
+}
+
+// dropColumn removes a column, and with it the indexes on it alone
+func (t *catalogTable) dropColumn(name string) {
+	t.Columns = slices.DeleteFunc(t.Columns, func(col catalogColumn) bool {
+		return strings.EqualFold(col.Name, name)
+	})
+	for i := range t.Indexes {
+		t.Indexes[i].Columns = slices.DeleteFunc(t.Indexes[i].Columns, func(col string) bool {
+			return strings.EqualFold(col, name)
+		})
+	}
+	t.Indexes = slices.DeleteFunc(t.Indexes, func(idx catalogIndex) bool { return len(idx.Columns) == 0 })
+}
+
+// replaceColumn redefines a column in place, or moves it when a position
+// is given
+func (t *catalogTable) replaceColumn(old string, col catalogColumn, pos *ast.ColumnPosition) {
+	if pos != nil && pos.Tp != ast.ColumnPositionNone {
+		t.dropColumn(old)
+		t.placeColumn(col, pos)
+		return
+	}
+	for i := range t.Columns {
+		if strings.EqualFold(t.Columns[i].Name, old) {
+			t.Columns[i] = col
+		}
+	}
+	t.renameIndexColumn(old, col.Name)
+}
+
+// index finds an index case-insensitively, or returns nil
+func (t *catalogTable) index(name string) *catalogIndex {
+	for i := range t.Indexes {
+		if strings.EqualFold(t.Indexes[i].Name, name) {
+			return &t.Indexes[i]
+		}
+	}
+	return nil
+}
+
+// addColumnKeys adds the indexes PRIMARY KEY and UNIQUE column options
+// declare
+func (t *catalogTable) addColumnKeys(def *ast.ColumnDef) {
+	part := []*ast.IndexPartSpecification{{Column: def.Name}}
+	for _, opt := range def.Options {
+		switch opt.Tp {
+		case ast.ColumnOptionPrimaryKey:
+			t.addIndex("", part, true, true)
+		case ast.ColumnOptionUniqKey:
+			t.addIndex("", part, false, true)
+		}
+	}
+}
+
+// addConstraint adds the index of a key constraint. A foreign key gets an
+// index of its own only when no index starts with its columns, as in InnoDB.
+func (t *catalogTable) addConstraint(c *ast.Constraint) {
+	switch c.Tp {
+	case ast.ConstraintPrimaryKey:
+		t.addIndex("", c.Keys, true, true)
+	case ast.ConstraintKey, ast.ConstraintIndex:
+		t.addIndex(c.Name, c.Keys, false, false)
+	case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+		t.addIndex(c.Name, c.Keys, false, true)
+	case ast.ConstraintForeignKey:
+		var cols []string
+		for _, part := range c.Keys {
+			if part.Column != nil {
+				cols = append(cols, part.Column.Name.O)
+			}
+		}
+		for _, idx := range t.Indexes {
+			if hasPrefix(idx.Columns, cols) {
+				return
+			}
+		}
+		t.addIndex(c.Name, c.Keys, false, false)
+	}
+}
+
+// addIndex adds an index on the column parts ahead of any expression. An
+// index without a name is named after its first column, the way MySQL
+// does.
+func (t *catalogTable) addIndex(name string, parts []*ast.IndexPartSpecification, primary, unique bool) {
+	idx := catalogIndex{Name: name, Primary: primary, Unique: unique}
+	for _, part := range parts {
+		if part.Column == nil {
+			break
+		}
+		idx.Columns = append(idx.Columns, part.Column.Name.O)
+	}
+	if len(idx.Columns) == 0 {
+		return
+	}
+	switch {
+	case primary:
+		if t.index("PRIMARY") != nil {
+			return
+		}
+		idx.Name = "PRIMARY"
+		t.Indexes = slices.Insert(t.Indexes, 0, idx)
+		return
+	case idx.Name == "":
+		idx.Name = idx.Columns[0]
+		for n := 2; t.index(idx.Name) != nil; n++ {
+			idx.Name = fmt.Sprintf("%s_%d", idx.Columns[0], n)
+		}
+	}
+	t.Indexes = append(t.Indexes, idx)
+}
+
+func (t *catalogTable) dropIndex(name string) {
+	t.Indexes = slices.DeleteFunc(t.Indexes, func(idx catalogIndex) bool {
+		return strings.EqualFold(idx.Name, name)
+	})
+}
+
+func (t *catalogTable) renameIndexColumn(old, name string) {
+	for _, idx := range t.Indexes {
+		for i, col := range idx.Columns {
+			if strings.EqualFold(col, old) {
+				idx.Columns[i] = name
+			}
+		}
+	}
+}
+
+// hasPrefix reports whether the columns of prefix lead cols
+func hasPrefix(cols, prefix []string) bool {
+	if len(prefix) > len(cols) {
+		return false
+	}
+	for i, col := range prefix {
+		if !strings.EqualFold(cols[i], col) {
+			return false
+		}
+	}
+	return true
+}
+
+// schemaIssue is a reference in a statement that doesn't match the catalog
+type schemaIssue struct {
+	Check string
+	Violation
+	// Table and Column name what an unknown-table or unknown-column issue
+	// misses; Table is "" when the column could be in several tables
+	Table, Column string
+}
+
+// resolver checks the table and column references of a statement against
+// a catalog, the way MySQL resolves names: innermost query first, then
+// the queries around it
+type resolver struct {
+	catalog *schemaCatalog
+	// text is the statement's source, to locate names without a position
+	text   string
+	issues []schemaIssue
+	// refs records the column each column reference resolved to, when set
+	refs columnRefs
+	// sources counts the sources of the statement, to number them
+	sources int
+}
+
+// scope is the tables one query block can see
+type scope struct {
+	sources []source
+	ctes    map[string]*catalogTable
+	// aliases of the select list, which GROUP BY, HAVING and ORDER BY
+	// may refer to
+	aliases []string
+	// merged are the columns joins with USING combine into one; natural
+	// is set when a NATURAL join combines columns the catalog may not list
+	merged  []string
+	natural bool
+	outer   *scope
+}
+
+// source is a table of a FROM clause under its alias. id tells apart
+// the sources of a statement, including one table under several aliases.
+type source struct {
+	id    int
+	name  string
+	label string
+	table *catalogTable
+}
+
+func (s *scope) source(name string) *source {
+	for i := range s.sources {
+		if s.sources[i].name == name {
+			return &s.sources[i]
+		}
+	}
+	return nil
+}
+
+func (s *scope) cte(name string) *catalogTable {
+	for ; s != nil; s = s.outer {
+		if t := s.ctes[name]; t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+func (r *resolver) report(check string, offset int, format string, args ...any) {
+	r.issues = append(r.issues, schemaIssue{Check: check, Violation: Violation{Offset: offset, Message: fmt.Sprintf(format, args...)}})
+}
+
+// unknown reports a table, or a column when column is set, that the
+// catalog doesn't have
+func (r *resolver) unknown(table, column string, offset int, format string, args ...any) {
+	check := "unknown-table"
+	if column != "" {
+		check = "unknown-column"
+	}
+	r.report(check, offset, format, args...)
+	r.issues[len(r.issues)-1].Table, r.issues[len(r.issues)-1].Column = table, column
+}
+
+// statement checks one statement
+func (r *resolver) statement(node ast.StmtNode) {
+	switch stmt := node.(type) {
+	case *ast.SelectStmt:
+		r.query(stmt, nil)
+	case *ast.SetOprStmt:
+		r.query(stmt, nil)
+	case *ast.UpdateStmt:
+		sc := &scope{outer: r.with(stmt.With, nil)}
+		r.from(stmt.TableRefs.TableRefs, sc)
+		for _, a := range stmt.List {
+			r.write(a.Column, sc)
+			r.expr(a.Expr, sc, false)
+		}
+		r.expr(stmt.Where, sc, false)
+		r.orderBy(stmt.Order, sc)
+	case *ast.DeleteStmt:
+		sc := &scope{outer: r.with(stmt.With, nil)}
+		r.from(stmt.TableRefs.TableRefs, sc)
+		r.expr(stmt.Where, sc, false)
+		r.orderBy(stmt.Order, sc)
+	case *ast.InsertStmt:
+		r.insert(stmt)
+	case *ast.CreateViewStmt:
+		if sel, ok := stmt.Select.(ast.ResultSetNode); ok {
+			r.query(sel, nil)
+		}
+	case *ast.CreateTableStmt:
+		if stmt.ReferTable != nil {
+			r.table(stmt.ReferTable, nil)
+		}
+		if stmt.Select != nil {
+			r.query(stmt.Select, nil)
+		}
+	case *ast.TruncateTableStmt:
+		r.table(stmt.Table, nil)
+	case *ast.DropTableStmt:
+		if !stmt.IfExists {
+			for _, tn := range stmt.Tables {
+				r.table(tn, nil)
+			}
+		}
+	case *ast.AlterTableStmt:
+		t := r.table(stmt.Table, nil)
+		for _, spec := range stmt.Specs {
+			switch spec.Tp {
+			case ast.AlterTableDropColumn, ast.AlterTableChangeColumn, ast.AlterTableRenameColumn:
+				if spec.OldColumnName != nil && !spec.IfExists && !t.Open && t.column(spec.OldColumnName.Name.O) == nil {
+					r.unknown(t.Name, spec.OldColumnName.Name.O, identOffset(r.text, spec.OldColumnName.Name.O), "unknown column %s in %s", spec.OldColumnName.Name.O, t.Name)
+				}
+			}
+		}
+	}
+}
+
+// with returns the scope holding the common table expressions of a WITH
+// clause, or outer when there are none
+func (r *resolver) with(w *ast.WithClause, outer *scope) *scope {
+	if w == nil {
+		return outer
+	}
+	sc := &scope{ctes: make(map[string]*catalogTable), outer: outer}
+	for _, cte := range w.CTEs {
+		if w.IsRecursive {
+			// A recursive CTE refers to itself before its columns are known
+			sc.ctes[cte.Name.L] = &catalogTable{Name: cte.Name.O, Open: true}
+		}
+		t := r.query(cte.Query.Query, sc)
+		t.Name = cte.Name.O
+		if len(cte.ColNameList) > 0 {
+			t = &catalogTable{Name: cte.Name.O}
+			for _, col := range cte.ColNameList {
+				t.Columns = append(t.Columns, catalogColumn{Name: col.O})
+			}
+		}
+		sc.ctes[cte.Name.L] = t
+	}
+	return sc
+}
+
+// query checks a query and returns the table it produces
+func (r *resolver) query(node ast.ResultSetNode, outer *scope) *catalogTable {
+	switch q := node.(type) {
+	case *ast.SelectStmt:
+		return r.selectStmt(q, outer)
+	case *ast.SetOprStmt:
+		return r.setOpr(q.SelectList, r.with(q.With, outer))
+	}
+	return &catalogTable{Open: true}
+}
+
+// setOpr checks every query of a UNION, EXCEPT or INTERSECT; the result
+// has the columns of the first
+func (r *resolver) setOpr(list *ast.SetOprSelectList, outer *scope) *catalogTable {
+	var out *catalogTable
+	if list == nil {
+		return &catalogTable{Open: true}
+	}
+	outer = r.with(list.With, outer)
+	for _, sel := range list.Selects {
+		var t *catalogTable
+		switch s := sel.(type) {
+		case *ast.SelectStmt:
+			t = r.selectStmt(s, outer)
+		case *ast.SetOprSelectList:
+			t = r.setOpr(s, outer)
+		default:
+			t = &catalogTable{Open: true}
+		}
+		if out == nil {
+			out = t
+		}
+	}
+	if out == nil {
+		return &catalogTable{Open: true}
+	}
+	return out
+}
+
+func (r *resolver) selectStmt(sel *ast.SelectStmt, outer *scope) *catalogTable {
+	sc := &scope{outer: r.with(sel.With, outer)}
+	if sel.From != nil && sel.From.TableRefs != nil {
+		r.from(sel.From.TableRefs, sc)
+	}
+
+	out := &catalogTable{}
+	if sel.Fields != nil {
+		for _, f := range sel.Fields.Fields {
+			if f.WildCard != nil {
+				r.wildcard(f.WildCard, sc, out)
+				continue
+			}
+			col := catalogColumn{Name: describeSQL(f.Expr)}
+			if cn, ok := f.Expr.(*ast.ColumnNameExpr); ok {
+				// Selected columns keep their type in views and derived tables
+				col.Name = cn.Name.Name.O
+				if c := r.columnExpr(cn, sc, false); c != nil {
+					col = *c
+				}
+			} else {
+				r.expr(f.Expr, sc, false)
+			}
+			if f.AsName.O != "" {
+				col.Name = f.AsName.O
+			}
+			out.Columns = append(out.Columns, col)
+			if f.AsName.L != "" {
+				sc.aliases = append(sc.aliases, f.AsName.L)
+			}
+		}
+	}
+
+	r.expr(sel.Where, sc, false)
+	if sel.GroupBy != nil {
+		for _, item := range sel.GroupBy.Items {
+			r.expr(item.Expr, sc, true)
+		}
+	}
+	if sel.Having != nil {
+		r.expr(sel.Having.Expr, sc, true)
+	}
+	r.orderBy(sel.OrderBy, sc)
+	return out
+}
+
+// wildcard adds the columns a * or t.* selects to out
+func (r *resolver) wildcard(w *ast.WildCardField, sc *scope, out *catalogTable) {
+	sources := sc.sources
+	if w.Table.L != "" {
+		src := sc.source(w.Table.L)
+		if src == nil {
+			r.unknown(w.Table.O, "", identOffset(r.text, w.Table.O), "unknown table or alias %s in %s.*", w.Table.O, w.Table.O)
+			out.Open = true
+			return
+		}
+		sources = []source{*src}
+	}
+	for _, src := range sources {
+		out.Columns = append(out.Columns, src.table.Columns...)
+		out.Open = out.Open || src.table.Open
+	}
+}
+
+func (r *resolver) orderBy(order *ast.OrderByClause, sc *scope) {
+	if order != nil {
+		for _, item := range order.Items {
+			r.expr(item.Expr, sc, true)
+		}
+	}
+}
+
+// from adds the tables of a FROM clause to sc and checks its join
+// conditions
+func (r *resolver) from(node ast.ResultSetNode, sc *scope) {
+	switch n := node.(type) {
+	case *ast.Join:
+		if n == nil {
+			return
+		}
+		r.from(n.Left, sc)
+		if n.Right != nil {
+			r.from(n.Right, sc)
+		}
+		if n.On != nil {
+			r.expr(n.On.Expr, sc, false)
+		}
+		for _, col := range n.Using {
+			sc.merged = append(sc.merged, col.Name.L)
+		}
+		sc.natural = sc.natural || n.NaturalJoin
+	case *ast.TableSource:
+		if tn, ok := n.Source.(*ast.TableName); ok {
+			src := r.source(tn.Name.O, r.table(tn, sc))
+			if n.AsName.L != "" {
+				src.name, src.label = n.AsName.L, n.AsName.O
+			}
+			sc.sources = append(sc.sources, src)
+			return
+		}
+		// Derived tables only see the queries around the FROM clause
+		t := r.query(n.Source, sc.outer)
+		sc.sources = append(sc.sources, r.source(n.AsName.O, t))
+	}
+}
+
+// source numbers a new source
+func (r *resolver) source(label string, t *catalogTable) source {
+	r.sources++
+	return source{id: r.sources, name: strings.ToLower(label), label: label, table: t}
+}
+
+// systemSchemas aren't in schema files, so their tables aren't checked
+var systemSchemas = []string{"information_schema", "mysql", "performance_schema", "sys"}
+
+// table looks up a table or common table expression, reporting unknown ones
+func (r *resolver) table(tn *ast.TableName, sc *scope) *catalogTable {
+	if tn.Schema.L == "" {
+		if t := sc.cte(tn.Name.L); t != nil {
+			return t
+		}
+	}
+	if slices.Contains(systemSchemas, tn.Schema.L) {
+		return &catalogTable{Name: tn.Name.O, Open: true}
+	}
+	if t := r.catalog.tables[tn.Name.L]; t != nil {
+		return t
+	}
+	r.unknown(tn.Name.O, "", identOffset(r.text, tn.Name.O), "unknown table %s", tn.Name.O)
+	return &catalogTable{Name: tn.Name.O, Open: true}
+}
+
+// expr checks the columns of an expression and its subqueries. aliases
+// lets names refer to the select list, as in GROUP BY, HAVING and ORDER BY.
+func (r *resolver) expr(e ast.ExprNode, sc *scope, aliases bool) {
+	if e == nil {
+		return
+	}
+	walk(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ColumnNameExpr:
+			r.columnExpr(n, sc, aliases)
+			return true
+		case *ast.SubqueryExpr:
+			r.query(n.Query, sc)
+			return true
+		}
+		return false
+	})
+}
+
+// columnExpr resolves a column reference of an expression and records
+// what it resolved to
+func (r *resolver) columnExpr(n *ast.ColumnNameExpr, sc *scope, aliases bool) *catalogColumn {
+	src, col := r.column(n.Name, n.OriginTextPosition(), sc, aliases)
+	if col != nil && r.refs != nil {
+		r.refs[n] = columnRef{Table: src.table.Name, Source: src.id, Alias: src.label, Column: col, table: src.table}
+	}
+	return col
+}
+
+// column resolves a column reference, reporting it when no table in scope
+// has it or more than one table of the same query does. It returns the
+// source and column it resolved to, nil when unknown or unchecked.
+func (r *resolver) column(name *ast.ColumnName, offset int, sc *scope, aliases bool) (*source, *catalogColumn) {
+	col := name.Name.O
+	if name.Table.L != "" {
+		qualified := name.Table.O + "." + col
+		for s := sc; s != nil; s = s.outer {
+			src := s.source(name.Table.L)
+			if src == nil {
+				continue
+			}
+			if src.table.Open {
+				return nil, nil
+			}
+			if c := src.table.column(col); c != nil {
+				return src, c
+			}
+			r.unknown(src.table.Name, col, offset, "unknown column %s: %s has no column %s", qualified, src.table.Name, col)
+			return nil, nil
+		}
+		r.unknown(name.Table.O, col, offset, "unknown column %s: no table or alias %s in the query", qualified, name.Table.O)
+		return nil, nil
+	}
+
+	for s := sc; s != nil; s = s.outer {
+		if aliases && s == sc && slices.Contains(s.aliases, name.Name.L) {
+			return nil, nil
+		}
+		var found []*source
+		open := false
+		for i, src := range s.sources {
+			if src.table.Open {
+				open = true
+			} else if src.table.column(col) != nil {
+				found = append(found, &s.sources[i])
+			}
+		}
+		switch {
+		case len(found) > 1 && (s.natural || slices.Contains(s.merged, name.Name.L)):
+			return found[0], found[0].table.column(col)
+		case len(found) > 1:
+			labels := make([]string, len(found))
+			for i, src := range found {
+				labels[i] = src.label
+			}
+			r.report("ambiguous-column", offset, "column %s is ambiguous: %s all have it; qualify it", col, strings.Join(labels, ", "))
+			return nil, nil
+		case len(found) == 1:
+			return found[0], found[0].table.column(col)
+		case open:
+			// The column may be in a table whose columns aren't known
+			return nil, nil
+		}
+	}
+
+	var labels []string
+	if sc != nil {
+		for _, src := range sc.sources {
+			labels = append(labels, src.label)
+		}
+	}
+	switch len(labels) {
+	case 0:
+		r.unknown("", col, offset, "unknown column %s", col)
+	case 1:
+		r.unknown(sc.sources[0].table.Name, col, offset, "unknown column %s in %s", col, labels[0])
+	default:
+		r.unknown("", col, offset, "unknown column %s in any of %s", col, strings.Join(labels, ", "))
+	}
+	return nil, nil
+}
+
+// write resolves a column a statement assigns, which can't be generated
+func (r *resolver) write(name *ast.ColumnName, sc *scope) {
+	offset := identOffset(r.text, name.Name.O)
+	if src, col := r.column(name, offset, sc, false); col != nil && col.Generated {
+		r.report("generated-column-write", offset, "%s is a generated column of %s and can't be written", col.Name, src.table.Name)
+	}
+}
+
+// insert checks the target table and columns of an INSERT or REPLACE and
+// that every row has a value per column
+func (r *resolver) insert(stmt *ast.InsertStmt) {
+	if stmt.Table == nil || stmt.Table.TableRefs == nil {
+		return
+	}
+	ts, ok := stmt.Table.TableRefs.Left.(*ast.TableSource)
+	if !ok {
+		return
+	}
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok {
+		return
+	}
+	t := r.table(tn, nil)
+	sc := &scope{sources: []source{r.source(tn.Name.O, t)}}
+
+	for _, col := range stmt.Columns {
+		r.write(col, sc)
+	}
+	expected, target := len(stmt.Columns), "columns"
+	if expected == 0 && !t.Open {
+		expected, target = len(t.Columns), "columns of "+t.Name
+	}
+
+	for i, row := range stmt.Lists {
+		for _, value := range row {
+			r.expr(value, sc, false)
+		}
+		if len(row) == 0 && len(stmt.Columns) == 0 {
+			// VALUES () takes every default
+			continue
+		}
+		if expected > 0 && len(row) != expected {
+			offset := 0
+			if len(row) > 0 {
+				offset = row[0].OriginTextPosition()
+			}
+			which := "VALUES"
+			if len(stmt.Lists) > 1 {
+				which = fmt.Sprintf("VALUES row %d", i+1)
+			}
+			r.report("insert-column-count", offset, "%s has %d values for the %d %s", which, len(row), expected, target)
+			continue
+		}
+		if len(stmt.Columns) == 0 && !t.Open {
+			// Without a column list every column gets a value, and
+			// generated ones only take DEFAULT
+			for j, col := range t.Columns {
+				if _, isDefault := row[j].(*ast.DefaultExpr); col.Generated && !isDefault {
+					r.report("generated-column-write", row[j].OriginTextPosition(), "%s is a generated column of %s and only takes DEFAULT", col.Name, t.Name)
+				}
+			}
+		}
+	}
+
+	if stmt.Select != nil {
+		out := r.query(stmt.Select, nil)
+		if expected > 0 && !out.Open && len(out.Columns) != expected {
+			r.report("insert-column-count", identOffset(r.text, "SELECT"), "SELECT returns %d columns for the %d %s", len(out.Columns), expected, target)
+		}
+	}
+	for _, a := range stmt.OnDuplicate {
+		r.write(a.Column, sc)
+		if stmt.Select == nil {
+			r.expr(a.Expr, sc, false)
+		}
+	}
+}
+
+// identOffset returns the offset of the first token of text naming an
+// identifier, quoted or not, or 0. Table names and assigned columns carry
+// no position in the syntax tree, so they are located by name.
+func identOffset(text, name string) int {
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\'' || c == '"':
+			i = skipQuoted(text, i) + 1
+		case c == '`':
+			end := min(skipQuoted(text, i), len(text))
+			if strings.EqualFold(strings.ReplaceAll(text[i+1:end], "``", "`"), name) {
+				return i
+			}
+			i = end + 1
+		case c == '#' || isDashComment(text[i:]) || (strings.HasPrefix(text[i:], "/*") && !strings.HasPrefix(text[i:], "/*!")):
+			i += max(leadingComments(text[i:]), 1)
+		case isWordByte(c):
+			token := firstToken(text[i:])
+			if strings.EqualFold(token, name) {
+				return i
+			}
+			i += len(token)
+		default:
+			i++
+		}
+	}
+	return 0
+}
+
+// CheckSchema resolves the tables and columns of every statement of sql
+// against catalog. DDL in sql updates the catalog as it goes, so later
+// statements see the tables earlier ones create. Statements that don't
+// parse are skipped; CheckAllSyntax reports them.
+func CheckSchema(sql, file string, catalog *schemaCatalog) []Finding {
+	stmts, _ := parseStatements(sql, file)
+	findings := []Finding{}
+	for _, stmt := range stmts {
+		for _, node := range stmt.Nodes {
+			r := &resolver{catalog: catalog, text: stmt.Text}
+			r.statement(node)
+			sort.SliceStable(r.issues, func(i, j int) bool { return r.issues[i].Offset < r.issues[j].Offset })
+
+			var seen []schemaIssue
+			for _, issue := range r.issues {
+				// A name used twice is reported once
+				if slices.Contains(seen, issue) {
+					continue
+				}
+				seen = append(seen, issue)
+				rule := funcRule{id: issue.Check, severity: SeverityError}
+				findings = append(findings, newFinding(sql, file, stmt.sqlStatement, rule, issue.Violation))
+			}
+			catalog.apply(node)
+		}
+	}
+	return findings
+}
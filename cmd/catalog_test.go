@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = `
+CREATE TABLE users (id BIGINT PRIMARY KEY, email VARCHAR(255), name VARCHAR(100), created_at DATETIME);
+CREATE TABLE orders (
+  id BIGINT PRIMARY KEY,
+  user_id BIGINT,
+  amount DECIMAL(10,2),
+  tax DECIMAL(10,2),
+  total DECIMAL(10,2) AS (amount + tax) STORED
+);
+CREATE VIEW big_orders AS SELECT o.id, o.total, u.email FROM orders o JOIN users u ON u.id = o.user_id;
+CREATE VIEW remote AS SELECT * FROM elsewhere;
+`
+
+// testCatalog builds a catalog from schema SQL
+func testCatalog(t *testing.T, schema string) *schemaCatalog {
+	t.Helper()
+	stmts, errs := parseStatements(schema, "")
+	if len(errs) > 0 {
+		t.Fatalf("schema error: %v", errs[0])
+	}
+	c := newSchemaCatalog()
+	for _, stmt := range stmts {
+		for _, node := range stmt.Nodes {
+			c.apply(node)
+		}
+	}
+	return c
+}
+
+func columnList(t *catalogTable) []string {
+	var names []string
+	for _, col := range t.Columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"Known columns", "SELECT id, email FROM users WHERE name = 'x'", nil},
+		{"Unknown column", "SELECT nme FROM users", []string{
+			"line 1, column 8: error: unknown column nme in users [unknown-column] (statement 1)",
+		}},
+		{"Unknown column across tables", "SELECT nope FROM users u JOIN orders o ON o.user_id = u.id", []string{
+			"line 1, column 8: error: unknown column nope in any of u, o [unknown-column] (statement 1)",
+		}},
+		{"Unknown qualified column", "SELECT u.amount FROM users u", []string{
+			"line 1, column 8: error: unknown column u.amount: users has no column amount [unknown-column] (statement 1)",
+		}},
+		{"Unknown qualifier", "SELECT x.id FROM users u", []string{
+			"line 1, column 8: error: unknown column x.id: no table or alias x in the query [unknown-column] (statement 1)",
+		}},
+		{"Unknown table", "SELECT * FROM users u JOIN order_items i ON i.id = u.id", []string{
+			"line 1, column 28: error: unknown table order_items [unknown-table] (statement 1)",
+		}},
+		{"Ambiguous column", "SELECT id FROM users u JOIN orders o ON o.user_id = u.id", []string{
+			"line 1, column 8: error: column id is ambiguous: u, o all have it; qualify it [ambiguous-column] (statement 1)",
+		}},
+		{"USING merges columns", "SELECT id FROM users JOIN orders USING (id)", nil},
+		{"Correlated subquery", "SELECT id FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id AND amount > 0)", nil},
+		{"Inner query first", "SELECT id FROM users WHERE id IN (SELECT id FROM orders)", nil},
+		{"Alias in ORDER BY", "SELECT created_at AS c FROM users ORDER BY c", nil},
+		{"Alias not in WHERE", "SELECT created_at AS c FROM users WHERE c > 0", []string{
+			"line 1, column 41: error: unknown column c in users [unknown-column] (statement 1)",
+		}},
+		{"View columns", "SELECT email, amount FROM big_orders", []string{
+			"line 1, column 15: error: unknown column amount in big_orders [unknown-column] (statement 1)",
+		}},
+		{"View over unknown tables", "SELECT anything FROM remote", nil},
+		{"Derived table", "SELECT d.n, d.id FROM (SELECT id, COUNT(*) AS n FROM orders GROUP BY id) d", nil},
+		{"CTE", "WITH r AS (SELECT id FROM orders) SELECT r.id, r.total FROM r", []string{
+			"line 1, column 48: error: unknown column r.total: r has no column total [unknown-column] (statement 1)",
+		}},
+		{"System schema", "SELECT table_name FROM information_schema.tables", nil},
+		{"UNION", "SELECT id FROM users UNION SELECT idd FROM orders", []string{
+			"line 1, column 35: error: unknown column idd in orders [unknown-column] (statement 1)",
+		}},
+		{"INSERT column count", "INSERT INTO orders (id, user_id) VALUES (1, 2, 3)", []string{
+			"line 1, column 42: error: VALUES has 3 values for the 2 columns [insert-column-count] (statement 1)",
+		}},
+		{"INSERT without column list", "INSERT INTO users VALUES (1, 'a@b.c', 'a', NOW()), (2, 'b')", []string{
+			"line 1, column 53: error: VALUES row 2 has 2 values for the 4 columns of users [insert-column-count] (statement 1)",
+		}},
+		{"INSERT defaults", "INSERT INTO users VALUES ()", nil},
+		{"INSERT SELECT count", "INSERT INTO users (id, email) SELECT id, user_id, amount FROM orders", []string{
+			"line 1, column 31: error: SELECT returns 3 columns for the 2 columns [insert-column-count] (statement 1)",
+		}},
+		{"INSERT unknown column", "INSERT INTO users (id, mail) VALUES (1, 'x')", []string{
+			"line 1, column 24: error: unknown column mail in users [unknown-column] (statement 1)",
+		}},
+		{"INSERT generated column", "INSERT INTO orders (id, total) VALUES (1, 2)", []string{
+			"line 1, column 25: error: total is a generated column of orders and can't be written [generated-column-write] (statement 1)",
+		}},
+		{"INSERT generated DEFAULT", "INSERT INTO orders VALUES (1, 2, 3, 4, DEFAULT)", nil},
+		{"INSERT generated value", "INSERT INTO orders VALUES (1, 2, 3, 4, 7)", []string{
+			"line 1, column 40: error: total is a generated column of orders and only takes DEFAULT [generated-column-write] (statement 1)",
+		}},
+		{"UPDATE generated column", "UPDATE orders SET total = 1, amount = amount + 1 WHERE id = 1", []string{
+			"line 1, column 19: error: total is a generated column of orders and can't be written [generated-column-write] (statement 1)",
+		}},
+		{"ON DUPLICATE KEY UPDATE", "INSERT INTO users (id, email) VALUES (1, 'x') ON DUPLICATE KEY UPDATE email = VALUES(email), nam = 'y'", []string{
+			"line 1, column 94: error: unknown column nam in users [unknown-column] (statement 1)",
+		}},
+		{"DELETE", "DELETE FROM orders WHERE userid = 1", []string{
+			"line 1, column 26: error: unknown column userid in orders [unknown-column] (statement 1)",
+		}},
+		{"Name reported once", "SELECT 1 FROM nope; SELECT 2 FROM nope a, nope b", []string{
+			"line 1, column 15: error: unknown table nope [unknown-table] (statement 1)",
+			"line 1, column 35: error: unknown table nope [unknown-table] (statement 2)",
+		}},
+		{"DDL updates the catalog", "CREATE TABLE audit (id INT, note TEXT); INSERT INTO audit VALUES (1, 'x'); ALTER TABLE audit DROP COLUMN note; SELECT note FROM audit", []string{
+			"line 1, column 119: error: unknown column note in audit [unknown-column] (statement 4)",
+		}},
+		{"ALTER unknown", "ALTER TABLE users DROP COLUMN nickname; ALTER TABLE accounts ADD COLUMN x INT", []string{
+			"line 1, column 31: error: unknown column nickname in users [unknown-column] (statement 1)",
+			"line 1, column 53: error: unknown table accounts [unknown-table] (statement 2)",
+		}},
+		{"DROP IF EXISTS", "DROP TABLE IF EXISTS nope", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range CheckSchema(tt.sql, "", testCatalog(t, testSchema)) {
+				got = append(got, f.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckSchema() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSchemaCatalogDDL(t *testing.T) {
+	c := testCatalog(t, `
+CREATE TABLE t (a INT, b INT, c INT);
+ALTER TABLE t ADD COLUMN z INT FIRST, ADD COLUMN b2 INT AFTER b, DROP COLUMN c;
+ALTER TABLE t CHANGE COLUMN a a1 BIGINT, RENAME COLUMN b TO b1, MODIFY COLUMN z INT AFTER b2;
+CREATE TABLE t_copy LIKE t;
+RENAME TABLE t TO t_new;
+CREATE TABLE IF NOT EXISTS t_new (x INT);
+CREATE VIEW v (x, y) AS SELECT a1, b1 FROM t_new;
+CREATE TABLE s AS SELECT a1, b1 AS renamed FROM t_new;
+CREATE TABLE gone (x INT);
+DROP TABLE gone;
+`)
+
+	tests := []struct {
+		table string
+		want  []string
+	}{
+		{"t_new", []string{"a1", "b1", "b2", "z"}},
+		{"t_copy", []string{"a1", "b1", "b2", "z"}},
+		{"v", []string{"x", "y"}},
+		{"s", []string{"a1", "renamed"}},
+	}
+	for _, tt := range tests {
+		table := c.tables[tt.table]
+		if table == nil {
+			t.Errorf("table %s missing", tt.table)
+			continue
+		}
+		if got := columnList(table); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s columns = %v, want %v", tt.table, got, tt.want)
+		}
+	}
+	for _, name := range []string{"t", "gone"} {
+		if c.tables[name] != nil {
+			t.Errorf("table %s should be gone", name)
+		}
+	}
+}
+
+func TestLoadSchemaCatalog(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"01_users.sql":   "CREATE TABLE users (id INT);",
+		"02_alter.sql":   "ALTER TABLE users ADD COLUMN email TEXT;",
+		"notes.txt":      "not sql",
+		"bad/broken.sql": "CREATE TABLE (",
+		"empty/x.sql":    "SELECT 1;",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		wantErr string
+	}{
+		{"Directory", []string{dir}, ""},
+		{"Glob", []string{filepath.Join(dir, "0*.sql")}, ""},
+		{"Files", []string{filepath.Join(dir, "01_users.sql"), filepath.Join(dir, "02_alter.sql")}, ""},
+		{"No match", []string{filepath.Join(dir, "*.yaml")}, "no schema files match"},
+		{"Missing", []string{filepath.Join(dir, "missing.sql")}, "error reading schema file"},
+		{"Syntax error", []string{filepath.Join(dir, "bad")}, "schema syntax error: " + filepath.Join(dir, "bad", "broken.sql") + ":1:"},
+		{"No tables", []string{filepath.Join(dir, "empty")}, "no CREATE TABLE or CREATE VIEW statements"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := loadSchemaCatalog(tt.paths)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadSchemaCatalog() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadSchemaCatalog() error = %v", err)
+			}
+			// Files are read in name order, so the ALTER sees the table
+			if got := columnList(c.tables["users"]); !reflect.DeepEqual(got, []string{"id", "email"}) {
+				t.Errorf("users columns = %v", got)
+			}
+		})
+	}
+}
+
+func TestIdentOffset(t *testing.T) {
+	tests := []struct {
+		text, name string
+		want       int
+	}{
+		{"SELECT * FROM users", "users", 14},
+		{"SELECT * FROM `Users`", "users", 14},
+		{"SELECT 'users' FROM /* users */ users", "users", 32},
+		{"SELECT users_id FROM users", "users", 21},
+		{"SELECT 1", "users", 0},
+	}
+	for _, tt := range tests {
+		if got := identOffset(tt.text, tt.name); got != tt.want {
+			t.Errorf("identOffset(%q, %q) = %d, want %d", tt.text, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogIndexes(t *testing.T) {
+	c := testCatalog(t, `
+CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR(255) UNIQUE, name VARCHAR(64));
+CREATE TABLE orders (
+  id BIGINT,
+  user_id INT,
+  status VARCHAR(16),
+  created_at DATETIME,
+  note TEXT,
+  PRIMARY KEY (id),
+  KEY (status, created_at),
+  KEY (status),
+  INDEX idx_expr ((LOWER(note))),
+  UNIQUE KEY uk_note (note(10), (LOWER(note))),
+  FULLTEXT KEY ft_note (note),
+  CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_status FOREIGN KEY (status) REFERENCES statuses (name)
+);
+CREATE INDEX idx_name ON users (name, id);
+CREATE UNIQUE INDEX uk_name ON users (name);
+DROP INDEX idx_name ON users;
+ALTER TABLE orders RENAME COLUMN status TO state, RENAME INDEX status_2 TO idx_state, DROP COLUMN created_at;
+ALTER TABLE orders DROP PRIMARY KEY, ADD PRIMARY KEY (id, user_id), ADD INDEX idx_created (id DESC);
+CREATE TABLE orders_copy LIKE orders;
+ALTER TABLE orders_copy DROP INDEX idx_state;
+`)
+
+	tests := []struct {
+		table string
+		want  []string
+	}{
+		{"users", []string{"PRIMARY* (id)", "email* (email)", "uk_name* (name)"}},
+		{"orders", []string{"PRIMARY* (id, user_id)", "status (state)", "idx_state (state)", "uk_note* (note)", "fk_user (user_id)", "idx_created (id)"}},
+		{"orders_copy", []string{"PRIMARY* (id, user_id)", "status (state)", "uk_note* (note)", "fk_user (user_id)", "idx_created (id)"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, idx := range c.tables[tt.table].Indexes {
+			name := idx.Name
+			if idx.Unique {
+				name += "*"
+			}
+			got = append(got, name+" ("+strings.Join(idx.Columns, ", ")+")")
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s indexes = %q, want %q", tt.table, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -11,27 +15,236 @@ var checkCmd = &cobra.Command{
 	Short: "Check SQL syntax",
 	Long: `Validate SQL syntax without parsing or analyzing.
 
+The input is split into statements the way the mysql client does,
+honouring quotes, comments and DELIMITER lines, and each statement is
+parsed on its own so every failing statement is reported, not just the
+first. Syntax errors carry the statement, line and column of the
+offending token and the source line with a caret under it. --format json
+prints them as JSON and --format github as GitHub Actions annotations.
+
+With --schema, statements are also checked against the tables and views
+that the schema's CREATE TABLE and CREATE VIEW statements define:
+unknown tables, unknown or ambiguous columns, INSERT rows whose value
+count doesn't match the columns, and writes to generated columns. Each
+--schema is a file, a directory of .sql files or a quoted glob. DDL in
+the checked SQL updates the schema for the statements after it.
+
+--migrations replays a directory of migrations instead, in the order
+golang-migrate (000001_name.up.sql), goose (20240101120000_name.sql,
+Up sections only) and Flyway (V1_1__name.sql, then R__name.sql) apply
+them, starting from an empty schema or the --schema tables. Each
+statement is checked against the tables the migrations before it leave,
+and the replay reports duplicate versions, references to tables or
+columns a later migration creates, CREATE TABLE of a table that exists,
+DROP TABLE of a table later migrations still use, and .sql files no
+tool would run.
+
 Examples:
   dbsqlx check "SELECT * FROM users"
-  dbsqlx check -f query.sql`,
+  dbsqlx check -f query.sql
+  dbsqlx check -f migration.sql --format json
+  dbsqlx check -f query.sql --schema './schema/*.sql'
+  dbsqlx check --migrations ./migrations`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runCheck,
 }
 
+var (
+	checkFormat     string
+	checkSchemas    []string
+	checkMigrations string
+)
+
 func init() {
 	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().StringVar(&checkFormat, "format", "text", "Output format: text, json or github")
+	checkCmd.Flags().StringArrayVar(&checkSchemas, "schema", nil, "Schema file, directory or quoted glob to check table and column references against (repeatable)")
+	checkCmd.Flags().StringVar(&checkMigrations, "migrations", "", "Directory of golang-migrate, goose or Flyway migrations to replay in version order")
+}
+
+// checkReport is the machine-readable result of a check
+type checkReport struct {
+	File       string         `json:"file,omitempty"`
+	Valid      bool           `json:"valid"`
+	Statements int            `json:"statements"`
+	Failed     int            `json:"failed"`
+	Errors     []*SyntaxError `json:"errors"`
+	// SchemaErrors are references the --schema catalog doesn't resolve
+	SchemaErrors []Finding `json:"schema_errors,omitempty"`
+	// Migrations are the files --migrations replayed, in order
+	Migrations []string `json:"migrations,omitempty"`
+	schema     bool
 }
 
 func runCheck(cmd *cobra.Command, args []string) error {
+	switch checkFormat {
+	case "text", "json", "github":
+	default:
+		return fmt.Errorf("unknown format %q (valid: text, json, github)", checkFormat)
+	}
+
+	if checkMigrations != "" {
+		return runCheckMigrations(cmd, args)
+	}
+
 	sql, err := getSQLInput(args)
 	if err != nil {
 		return err
 	}
+	// Syntax errors are reported on their own, not with the usage text
+	cmd.SilenceUsage = true
 
-	if err := CheckSQLSyntax(sql); err != nil {
-		return fmt.Errorf("SQL syntax error: %v", err)
+	total, errs := CheckAllSyntax(sql, fileInput)
+	report := checkReport{
+		File:       fileInput,
+		Statements: total,
+		Failed:     len(errs),
+		Errors:     errs,
 	}
+	if len(checkSchemas) > 0 {
+		catalog, err := loadSchemaCatalog(checkSchemas)
+		if err != nil {
+			return err
+		}
+		report.SchemaErrors, report.schema = CheckSchema(sql, fileInput, catalog), true
+	}
+	report.Valid = len(errs) == 0 && len(report.SchemaErrors) == 0
 
-	fmt.Println("✓ SQL syntax is valid")
+	if err := writeCheckReport(os.Stdout, report, checkFormat); err != nil {
+		return err
+	}
+	if !report.Valid {
+		return fmt.Errorf("%s", checkSummary(report))
+	}
 	return nil
 }
+
+// runCheckMigrations replays the --migrations directory
+func runCheckMigrations(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 || fileInput != "" {
+		return fmt.Errorf("--migrations reads the directory's files and takes no SQL or --file")
+	}
+	cmd.SilenceUsage = true
+
+	catalog := newSchemaCatalog()
+	if len(checkSchemas) > 0 {
+		var err error
+		if catalog, err = loadSchemaCatalog(checkSchemas); err != nil {
+			return err
+		}
+	}
+	report, err := CheckMigrations(checkMigrations, catalog)
+	if err != nil {
+		return err
+	}
+	if err := writeCheckReport(os.Stdout, report, checkFormat); err != nil {
+		return err
+	}
+	if !report.Valid {
+		return fmt.Errorf("%s", checkSummary(report))
+	}
+	return nil
+}
+
+// checkSummary counts the failing statements of a report
+func checkSummary(report checkReport) string {
+	var parts []string
+	if report.Failed > 0 || len(report.SchemaErrors) == 0 {
+		if report.Statements == 1 {
+			parts = append(parts, "SQL syntax error in 1 statement")
+		} else {
+			parts = append(parts, fmt.Sprintf("SQL syntax errors in %d of %d statements", report.Failed, report.Statements))
+		}
+	}
+	if n := len(report.SchemaErrors); n > 0 && report.Migrations != nil {
+		var files []string
+		for _, f := range report.SchemaErrors {
+			files = appendUnique(files, f.File)
+		}
+		parts = append(parts, fmt.Sprintf("%s in %s", counted(n, "schema error", "schema errors"), counted(len(files), "migration", "migrations")))
+	} else if n > 0 {
+		var statements []int
+		for _, f := range report.SchemaErrors {
+			statements = appendUnique(statements, f.Statement)
+		}
+		if report.Statements == 1 {
+			parts = append(parts, counted(n, "schema error", "schema errors")+" in 1 statement")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s in %d of %d statements", counted(n, "schema error", "schema errors"), len(statements), report.Statements))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// writeCheckReport prints the result of a check. Text output lists each
+// error with its excerpt; the summary is returned to the caller as an error.
+func writeCheckReport(w io.Writer, report checkReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "github":
+		for _, e := range report.Errors {
+			fmt.Fprintln(w, githubAnnotation(e))
+		}
+		for _, f := range report.SchemaErrors {
+			fmt.Fprintln(w, workflowCommand("error", f.File, f.Line, f.Column, f.Rule, f.Message, f.Statement))
+		}
+	default:
+		switch {
+		case report.Valid && report.Migrations != nil:
+			n := len(report.Migrations)
+			fmt.Fprintf(w, "✓ %s %s with valid SQL syntax and schema references\n", counted(n, "migration", "migrations"), plural(n, "replays", "replay"))
+		case report.Valid && report.schema:
+			fmt.Fprintln(w, "✓ SQL syntax and schema references are valid")
+		case report.Valid:
+			fmt.Fprintln(w, "✓ SQL syntax is valid")
+		}
+		for _, e := range report.Errors {
+			fmt.Fprintln(w, formatSyntaxError(e))
+		}
+		for _, f := range report.SchemaErrors {
+			if f.Excerpt == "" {
+				// Findings about a migration file as a whole
+				fmt.Fprintln(w, f.String())
+				continue
+			}
+			fmt.Fprintln(w, f.String()+"\n"+f.Excerpt)
+		}
+	}
+	return nil
+}
+
+// formatSyntaxError renders an error with its source excerpt below it
+func formatSyntaxError(e *SyntaxError) string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return e.Error() + "\n" + e.Excerpt
+}
+
+// githubAnnotation renders an error as a GitHub Actions workflow command
+func githubAnnotation(e *SyntaxError) string {
+	return workflowCommand("error", e.File, e.Line, e.Column, "SQL syntax error", e.Message, e.Statement)
+}
+
+// workflowCommand renders a GitHub Actions annotation at a source position
+func workflowCommand(level, file string, line, column int, title, msg string, statement int) string {
+	var props []string
+	if file != "" {
+		props = append(props, "file="+file)
+	}
+	if line > 0 {
+		props = append(props, fmt.Sprintf("line=%d", line), fmt.Sprintf("col=%d", column))
+	}
+	props = append(props, "title="+title)
+
+	if statement > 0 {
+		msg = fmt.Sprintf("%s (statement %d)", msg, statement)
+	}
+	// Workflow commands need %, CR and LF escaped
+	msg = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(msg)
+	return "::" + level + " " + strings.Join(props, ",") + "::" + msg
+}
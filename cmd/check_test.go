@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +128,61 @@ func TestCheckCommandMultipleStatements(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteCheckReport(t *testing.T) {
+	sql := "SELECT 1;\nDELETE FROM users WHERE id = = 3;\nSELECT FROM"
+	total, errs := CheckAllSyntax(sql, "m.sql")
+	report := checkReport{File: "m.sql", Statements: total, Failed: len(errs), Errors: errs}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"text", []string{"m.sql:2:30: unexpected \"=\" (statement 2)\n2 | DELETE FROM users WHERE id = = 3;\n  |                              ^", `m.sql:3:8: unexpected "FROM" (statement 3)`}},
+		{"json", []string{`"valid": false`, `"statements": 3`, `"failed": 2`, `"statement": 2`, `"line": 2`, `"column": 30`, `"token": "="`, `"excerpt": "2 | DELETE FROM users WHERE id = = 3;\n  |                              ^"`}},
+		{"github", []string{
+			`::error file=m.sql,line=2,col=30,title=SQL syntax error::unexpected "=" (statement 2)`,
+			`::error file=m.sql,line=3,col=8,title=SQL syntax error::unexpected "FROM" (statement 3)`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCheckReport(&buf, report, tt.format); err != nil {
+				t.Fatalf("writeCheckReport() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q\nGot:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSummary(t *testing.T) {
+	tests := []struct {
+		report checkReport
+		want   string
+	}{
+		{checkReport{Statements: 1, Failed: 1}, "SQL syntax error in 1 statement"},
+		{checkReport{Statements: 5, Failed: 1}, "SQL syntax errors in 1 of 5 statements"},
+		{checkReport{Statements: 5, Failed: 3}, "SQL syntax errors in 3 of 5 statements"},
+		{checkReport{Statements: 1, SchemaErrors: []Finding{{Statement: 1}}}, "1 schema error in 1 statement"},
+		{checkReport{Statements: 5, SchemaErrors: []Finding{{Statement: 2}, {Statement: 2}, {Statement: 4}}}, "3 schema errors in 2 of 5 statements"},
+		{checkReport{Statements: 5, Failed: 1, SchemaErrors: []Finding{{Statement: 2}}}, "SQL syntax errors in 1 of 5 statements; 1 schema error in 1 of 5 statements"},
+	}
+	for _, tt := range tests {
+		if got := checkSummary(tt.report); got != tt.want {
+			t.Errorf("checkSummary(%+v) = %q, want %q", tt.report, got, tt.want)
+		}
+	}
+}
+
+func TestGithubAnnotationEscapes(t *testing.T) {
+	got := githubAnnotation(&SyntaxError{Message: "100% broken\nreally"})
+	if want := "::error title=SQL syntax error::100%25 broken%0Areally"; got != want {
+		t.Errorf("githubAnnotation() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// chunkKey returns the columns a table's chunks are ranged over: its
+// primary (or first unique) key. Without a known key it returns an error
+// rather than guessing one.
+func chunkKey(catalog *keyCatalog, table string) ([]string, error) {
+	key := catalog.primaryKey(table)
+	if key == nil {
+		return nil, fmt.Errorf("cannot chunk %s: %s", table, noKeyReason(table))
+	}
+	return key, nil
+}
+
+// checkChunkKeys makes sure every table a chunked dump splits has a key,
+// before any command is printed
+func checkChunkKeys(plans []statementPlan, catalog *keyCatalog) error {
+	for _, plan := range plans {
+		for _, dump := range plan.Dumps {
+			if !chunkable(plan, dump) {
+				continue
+			}
+			for _, table := range dump.AllTables() {
+				if _, err := chunkKey(catalog, table); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// chunkable reports whether a dump is split into key ranges. Exact-id dumps
+// are already bounded and schema-only dumps hold no rows.
+func chunkable(plan statementPlan, dump tableDump) bool {
+	return dump.IDQuery == "" && plan.Preset != "schema-only"
+}
+
+// chunkPlans returns the plan a chunked table's schema is dumped with (nil
+// when its preset leaves the definition out) and the plan of its data
+// chunks. Chunks only hold rows, so restoring one never drops the rows
+// restored from another.
+func chunkPlans(plan statementPlan) (*statementPlan, statementPlan) {
+	data := plan
+	data.Preset = "pre-dml-backup"
+	if plan.Preset == "pre-dml-backup" {
+		return nil, data
+	}
+	schema := plan
+	schema.Preset = "schema-only"
+	return &schema, data
+}
+
+// rangeFilter limits filter to the keys from lo (inclusive) to hi
+// (exclusive). lo and hi are SQL literals, "" for an open end.
+func rangeFilter(key, lo, hi, filter string) string {
+	var conds []string
+	if lo != "" {
+		conds = append(conds, key+">="+lo)
+	}
+	if hi != "" {
+		conds = append(conds, key+"<"+hi)
+	}
+	if filter != "" {
+		conds = append(conds, parenthesizeOr(filter))
+	}
+	return strings.Join(conds, " and ")
+}
+
+// parenthesizeOr wraps a filter with a top-level OR so it can be ANDed
+func parenthesizeOr(filter string) string {
+	if hasTopLevelOr(filter) {
+		return "(" + filter + ")"
+	}
+	return filter
+}
+
+// chunkBoundaries returns the key values that start each chunk after the
+// first, as SQL literals. Every chunk holds size matching rows except the
+// last; the first and last chunks are open-ended so rows written meanwhile
+// are never missed.
+func chunkBoundaries(ctx context.Context, db *sql.DB, table string, key []string, filter string, size int) ([]string, error) {
+	expr := keyTuple(key)
+	var bounds []string
+	for {
+		lo := ""
+		if len(bounds) > 0 {
+			lo = bounds[len(bounds)-1]
+		}
+		hi, found, err := nthKey(ctx, db, table, key, rangeFilter(expr, lo, "", filter), size)
+		if err == nil && found && hi == lo {
+			// More than a chunk of rows share the key value, which only a
+			// non-unique --pk allows: this chunk takes them all
+			after := expr + ">" + lo
+			if filter != "" {
+				after += " and " + parenthesizeOr(filter)
+			}
+			hi, found, err = nthKey(ctx, db, table, key, after, 0)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error finding chunks of %s: %v", table, err)
+		}
+		if !found {
+			return bounds, nil
+		}
+		bounds = append(bounds, hi)
+	}
+}
+
+// nthKey returns the key of the row at offset among the rows matching
+// where, in key order, as an SQL literal
+func nthKey(ctx context.Context, db *sql.DB, table string, key []string, where string, offset int) (string, bool, error) {
+	columns := quoteColumns(key)
+	query := fmt.Sprintf("SELECT %s FROM %s", columns, quoteTableName(table))
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT 1 OFFSET %d", columns, offset)
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return "", false, err
+	}
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(key))
+	dest := make([]any, len(key))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", false, err
+	}
+	literals := make([]string, len(key))
+	for i, v := range values {
+		literals[i] = sqlLiteral(v, types[i].DatabaseTypeName())
+	}
+	if len(literals) == 1 {
+		return literals[0], true, nil
+	}
+	return "(" + strings.Join(literals, ",") + ")", true, nil
+}
+
+// chunkedCommands renders the dump of one table as one command per
+// precomputed key range, each writing its own file so they can run in
+// parallel and be rerun one by one. The table's schema comes first when
+// the preset includes it.
+func chunkedCommands(ctx context.Context, db *sql.DB, s dumpSettings, tool dumpTool, plan statementPlan, table, filter string) ([]string, error) {
+	key, err := chunkKey(s.Keys, table)
+	if err != nil {
+		return nil, err
+	}
+	expr := keyTuple(key)
+	bounds, err := chunkBoundaries(ctx, db, table, key, filter, s.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	output := func(p statementPlan, name string, where string) string {
+		file := fmt.Sprintf("%s.%s_%d_%s", s.Database, table, plan.Index, name)
+		if tool.Streams() {
+			return tool.Command(s, p, []string{table}, where, "") + " > " + file + ".sql"
+		}
+		return tool.Command(s, p, []string{table}, where, file)
+	}
+
+	schema, data := chunkPlans(plan)
+	lines := []string{fmt.Sprintf("# Chunked %s by %s: %d ranges of up to %d rows", table, expr, len(bounds)+1, s.ChunkSize)}
+	if schema != nil {
+		lines = append(lines, output(*schema, "schema", ""))
+	}
+	for i := 0; i <= len(bounds); i++ {
+		lo, hi := "", ""
+		if i > 0 {
+			lo = bounds[i-1]
+		}
+		if i < len(bounds) {
+			hi = bounds[i]
+		}
+		where := ""
+		if f := rangeFilter(expr, lo, hi, filter); f != "" {
+			where = "\"" + escapeBackquotes(f) + "\""
+		}
+		lines = append(lines, output(data, fmt.Sprintf("%04d", i+1), where))
+	}
+	return lines, nil
+}
+
+// writeChunkWalk writes the script steps dumping one table chunk by chunk.
+// The script finds each chunk's upper key while it runs, writes every chunk
+// to its own file and skips chunks whose file already exists, so a failed
+// run can be resumed with the same DBSQLX_TS.
+func writeChunkWalk(b *strings.Builder, s dumpSettings, tool dumpTool, plan statementPlan, table, filter, ext, pipe string) error {
+	key, err := chunkKey(s.Keys, table)
+	if err != nil {
+		return err
+	}
+	// The key and table go into double-quoted -e arguments
+	expr, columns := escapeBackquotes(keyTuple(key)), escapeBackquotes(quoteColumns(key))
+	quoted := make([]string, len(key))
+	for i, col := range key {
+		quoted[i] = quoteIdent(col)
+	}
+	literal, from := escapeBackquotes(keyLiteral(quoted)), escapeBackquotes(quoteTableName(table))
+	target := s.Database + "." + table
+	command := func(p statementPlan, where string) string {
+		if tool.Streams() {
+			return tool.Command(s, p, []string{table}, where, "") + pipe + " > \"$part\""
+		}
+		return tool.Command(s, p, []string{table}, where, "\"$part\"")
+	}
+	record := fmt.Sprintf("printf '%%s\\t%%s\\t%%s\\t%%s\\n' \"$file\" %s \"$filter\" \"$rows\" >> \"$manifest\"\n", shellQuote(target))
+
+	schema, data := chunkPlans(plan)
+	if schema != nil {
+		fmt.Fprintf(b, "file=\"$out_dir/%s_%d_${ts}_schema%s\"\n", target, plan.Index, ext)
+		b.WriteString("if [ ! -e \"$file\" ]; then\n")
+		b.WriteString("  part=\"$file.part\"\n")
+		b.WriteString("  " + command(*schema, "") + "\n")
+		b.WriteString("  mv \"$part\" \"$file\"\n")
+		b.WriteString("  filter=\"\"\n")
+		b.WriteString("  rows=0\n")
+		b.WriteString("  " + record)
+		b.WriteString("fi\n")
+	}
+
+	fmt.Fprintf(b, "base=%s\n", shellQuote(parenthesizeOr(filter)))
+	b.WriteString("lo=\"\"\n")
+	b.WriteString("chunk=0\n")
+	b.WriteString("while :; do\n")
+	b.WriteString("  chunk=$((chunk + 1))\n")
+	b.WriteString("  where=\"$base\"\n")
+	fmt.Fprintf(b, "  if [ -n \"$lo\" ]; then where=\"%s>=$lo${base:+ and $base}\"; fi\n", expr)
+	fmt.Fprintf(b, "  hi=\"$(mysql%s -N -e \"SELECT %s FROM %s${where:+ WHERE $where} ORDER BY %s LIMIT 1 OFFSET %d\" %s)\"\n",
+		s.ConnOpts, literal, from, columns, s.ChunkSize, s.Database)
+	// More than a chunk of rows sharing the key value (only a non-unique
+	// --pk allows it) would never move past lo: that chunk takes them all
+	b.WriteString("  if [ -n \"$hi\" ] && [ \"$hi\" = \"$lo\" ]; then\n")
+	fmt.Fprintf(b, "    hi=\"$(mysql%s -N -e \"SELECT %s FROM %s WHERE %s>$lo${base:+ and $base} ORDER BY %s LIMIT 1\" %s)\"\n",
+		s.ConnOpts, literal, from, expr, columns, s.Database)
+	b.WriteString("  fi\n")
+	b.WriteString("  filter=\"$where\"\n")
+	fmt.Fprintf(b, "  if [ -n \"$hi\" ]; then filter=\"${where:+$where and }%s<$hi\"; fi\n", expr)
+	fmt.Fprintf(b, "  file=\"$out_dir/%s_%d_${ts}_$(printf '%%04d' \"$chunk\")%s\"\n", target, plan.Index, ext)
+	b.WriteString("  if [ -e \"$file\" ]; then\n")
+	b.WriteString("    echo \"  chunk $chunk already dumped\" >&2\n")
+	b.WriteString("  else\n")
+	b.WriteString("    part=\"$file.part\"\n")
+	fmt.Fprintf(b, "    rows=\"$(mysql%s -N -e \"SELECT COUNT(*) FROM %s${filter:+ WHERE $filter}\" %s)\"\n", s.ConnOpts, from, s.Database)
+	b.WriteString("    if [ -n \"$filter\" ]; then\n")
+	b.WriteString("      " + command(data, "\"$filter\"") + "\n")
+	b.WriteString("    else\n")
+	b.WriteString("      " + command(data, "") + "\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    mv \"$part\" \"$file\"\n")
+	b.WriteString("    " + record)
+	b.WriteString("  fi\n")
+	b.WriteString("  if [ -z \"$hi\" ]; then break; fi\n")
+	b.WriteString("  lo=\"$hi\"\n")
+	b.WriteString("done\n")
+	return nil
+}
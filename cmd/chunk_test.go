@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRangeFilter(t *testing.T) {
+	tests := []struct {
+		lo, hi, filter string
+		want           string
+	}{
+		{"", "", "", ""},
+		{"", "1001", "", "id<1001"},
+		{"1001", "2001", "status='x'", "id>=1001 and id<2001 and status='x'"},
+		{"2001", "", "a=1 OR b=2", "id>=2001 and (a=1 OR b=2)"},
+		{"", "", "(a=1 OR b=2) and c=3", "(a=1 OR b=2) and c=3"},
+	}
+
+	for _, tt := range tests {
+		if got := rangeFilter("id", tt.lo, tt.hi, tt.filter); got != tt.want {
+			t.Errorf("rangeFilter(%q, %q, %q) = %q, want %q", tt.lo, tt.hi, tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestChunkKey(t *testing.T) {
+	catalog := testKeyCatalog(t, "events=event_id", "stock=warehouse_id,sku")
+	for table, want := range map[string][]string{"events": {"event_id"}, "Stock": {"warehouse_id", "sku"}} {
+		got, err := chunkKey(catalog, table)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("chunkKey(%q) = %q, %v, want %q", table, got, err, want)
+		}
+	}
+
+	// Without a known key nothing is guessed
+	if _, err := chunkKey(catalog, "logs"); err == nil || !strings.Contains(err.Error(), "--pk") {
+		t.Errorf("chunkKey(logs) error = %v, want a note asking for --pk", err)
+	}
+	stmtNodes, err := ParseAll("DELETE FROM events WHERE id < 5; DELETE FROM logs WHERE id < 5")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	if err := checkChunkKeys(buildDumpPlan(stmtNodes, catalog), catalog); err == nil || !strings.Contains(err.Error(), "logs") {
+		t.Errorf("checkChunkKeys() error = %v, want logs without a key", err)
+	}
+}
+
+func TestChunkBoundariesKeys(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE stock (warehouse_id INT, sku VARCHAR(10), PRIMARY KEY (warehouse_id, sku))",
+		"INSERT INTO stock VALUES (1,'a'),(1,'b'),(1,'c'),(1,'d'),(2,'a'),(2,'b')",
+		"CREATE TABLE hits (id INT, path VARCHAR(10))",
+		"INSERT INTO hits VALUES (1,'/'),(2,'/'),(2,'/a'),(2,'/b'),(2,'/c'),(3,'/')",
+		"CREATE TABLE `group` (`key` INT PRIMARY KEY)",
+		"INSERT INTO `group` VALUES (1),(2),(3)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		table string
+		key   []string
+		want  []string
+	}{
+		// Composite keys are ranged over as a whole, never stalling inside
+		// one warehouse
+		{"stock", []string{"warehouse_id", "sku"}, []string{"(1,'c')", "(2,'a')"}},
+		// A non-unique key takes a run of equal values into one chunk
+		{"hits", []string{"id"}, []string{"2", "3"}},
+		// Reserved names are quoted
+		{"group", []string{"key"}, []string{"3"}},
+	}
+	for _, tt := range tests {
+		got, err := chunkBoundaries(context.Background(), db, tt.table, tt.key, "", 2)
+		if err != nil {
+			t.Fatalf("chunkBoundaries(%s) error = %v", tt.table, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("chunkBoundaries(%s) = %v, want %v", tt.table, got, tt.want)
+		}
+	}
+}
+
+func TestChunkedCommands(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE logs (id INT PRIMARY KEY, level VARCHAR(10))"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 12; i++ {
+		level := "info"
+		if i%2 == 0 {
+			level = "debug"
+		}
+		if _, err := db.Exec(fmt.Sprintf("INSERT INTO logs VALUES (%d, '%s')", i, level)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := dumpSettings{ConnOpts: " -u root", Database: "shop", ChunkSize: 2, Keys: testKeyCatalog(t, "logs=id")}
+	stmtNodes, err := ParseAll("DELETE FROM logs WHERE level = 'debug'; DROP TABLE logs")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	plans := buildDumpPlan(stmtNodes, nil)
+
+	got, err := chunkedCommands(context.Background(), db, settings, mysqldumpTool{}, plans[0], "logs", "level='debug'")
+	if err != nil {
+		t.Fatalf("chunkedCommands() error = %v", err)
+	}
+	opts := "--single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF"
+	// Key columns are backquoted, escaped for the double-quoted --where
+	id := "\\`id\\`"
+	want := []string{
+		"# Chunked logs by `id`: 3 ranges of up to 2 rows",
+		`mysqldump -u root ` + opts + ` --where="` + id + `<6 and level='debug'" shop logs > shop.logs_1_0001.sql`,
+		`mysqldump -u root ` + opts + ` --where="` + id + `>=6 and ` + id + `<10 and level='debug'" shop logs > shop.logs_1_0002.sql`,
+		`mysqldump -u root ` + opts + ` --where="` + id + `>=10 and level='debug'" shop logs > shop.logs_1_0003.sql`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chunkedCommands() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Presets with the table definition dump it once, ahead of data-only chunks
+	settings.ChunkSize = 5
+	got, err = chunkedCommands(context.Background(), db, settings, mysqldumpTool{}, plans[1], "logs", "")
+	if err != nil {
+		t.Fatalf("chunkedCommands() error = %v", err)
+	}
+	want = []string{
+		"# Chunked logs by `id`: 3 ranges of up to 5 rows",
+		"mysqldump -u root --no-data --set-gtid-purged=OFF shop logs > shop.logs_2_schema.sql",
+		`mysqldump -u root ` + opts + ` --where="` + id + `<6" shop logs > shop.logs_2_0001.sql`,
+		`mysqldump -u root ` + opts + ` --where="` + id + `>=6 and ` + id + `<11" shop logs > shop.logs_2_0002.sql`,
+		`mysqldump -u root ` + opts + ` --where="` + id + `>=11" shop logs > shop.logs_2_0003.sql`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chunkedCommands() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteDumpScriptChunked(t *testing.T) {
+	stmtNodes, err := ParseAll("DELETE FROM logs WHERE level = 'debug' OR level = 'trace'; ALTER TABLE logs ADD COLUMN x INT")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	settings := dumpSettings{ConnOpts: " -u root", Database: "shop", Compression: "gzip", ChunkSize: 50000, Keys: testKeyCatalog(t, "logs=id")}
+	var buf bytes.Buffer
+	if err := writeDumpScript(&buf, buildDumpPlan(stmtNodes, nil), settings, mysqldumpTool{}); err != nil {
+		t.Fatalf("writeDumpScript() error = %v", err)
+	}
+	script := buf.String()
+	// escaped writes the script's escaped backquotes as @
+	escaped := func(s string) string { return strings.ReplaceAll(s, "@", "\\`") }
+
+	for _, part := range []string{
+		`ts="${DBSQLX_TS:-$(date +%Y%m%d_%H%M%S)}"`,
+		`[ -e "$manifest" ] || printf`,
+		"echo '[1/2] Dumping shop.logs in chunks of 50000 rows' >&2",
+		`base='(level='\''debug'\'' OR level='\''trace'\'')'`,
+		escaped(`if [ -n "$lo" ]; then where="@id@>=$lo${base:+ and $base}"; fi`),
+		escaped(`hi="$(mysql -u root -N -e "SELECT QUOTE(@id@) FROM @logs@${where:+ WHERE $where} ORDER BY @id@ LIMIT 1 OFFSET 50000" shop)"`),
+		escaped(`hi="$(mysql -u root -N -e "SELECT QUOTE(@id@) FROM @logs@ WHERE @id@>$lo${base:+ and $base} ORDER BY @id@ LIMIT 1" shop)"`),
+		escaped(`rows="$(mysql -u root -N -e "SELECT COUNT(*) FROM @logs@${filter:+ WHERE $filter}" shop)"`),
+		`file="$out_dir/shop.logs_1_${ts}_$(printf '%04d' "$chunk").sql.gz"`,
+		`--where="$filter" shop logs | gzip > "$part"`,
+		`mv "$part" "$file"`,
+		// Schema-only dumps are not chunked
+		"echo '[2/2] Dumping shop.logs' >&2",
+	} {
+		if !strings.Contains(script, part) {
+			t.Errorf("script missing %q\nGot:\n%s", part, script)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.sh")
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("bash", "-n", path).CombinedOutput(); err != nil {
+		t.Errorf("bash -n failed: %v\n%s", err, out)
+	}
+}
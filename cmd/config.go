@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read from the current directory when --config is
+// not given
+const defaultConfigFile = ".dbsqlx.yaml"
+
+// dbsqlxConfig is the content of .dbsqlx.yaml
+type dbsqlxConfig struct {
+	Lint  lintConfig  `yaml:"lint"`
+	Guard guardConfig `yaml:"guard"`
+}
+
+// guardConfig sets the guard policy of a repository
+type guardConfig struct {
+	// Protected are table names or globs whose DDL is blocked
+	Protected []string `yaml:"protected"`
+}
+
+// lintConfig tunes the lint rules, keyed by rule ID
+type lintConfig struct {
+	Rules map[string]ruleConfig `yaml:"rules"`
+}
+
+// ruleConfig enables, disables, re-ranks or scopes one rule
+type ruleConfig struct {
+	// Enabled defaults to true
+	Enabled  *bool    `yaml:"enabled"`
+	Severity Severity `yaml:"severity"`
+	// Files limits the rule to files matching one of the globs
+	Files []string `yaml:"files"`
+	// Exclude skips files matching one of the globs
+	Exclude []string `yaml:"exclude"`
+}
+
+// loadConfig reads a config file. A missing file is only an error when it
+// was asked for explicitly; otherwise the defaults apply.
+func loadConfig(file string, explicit bool) (*dbsqlxConfig, error) {
+	cfg := &dbsqlxConfig{}
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("config error in %s: %v", file, err)
+	}
+	if err := cfg.Lint.validate(ruleIDs(BuiltinRules())); err != nil {
+		return nil, fmt.Errorf("config error in %s: %v", file, err)
+	}
+	return cfg, nil
+}
+
+// validate rejects unknown rules and severities
+func (c lintConfig) validate(known []string) error {
+	for id, rc := range c.Rules {
+		if !slices.Contains(known, id) {
+			return fmt.Errorf("unknown lint rule %q (valid: %s)", id, strings.Join(known, ", "))
+		}
+		switch rc.Severity {
+		case "", SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return fmt.Errorf("rule %s: unknown severity %q (valid: error, warning, info)", id, rc.Severity)
+		}
+	}
+	return nil
+}
+
+// rulesFor returns the rules that apply to file, with configured severities.
+// Rules scoped with files don't apply to SQL given on the command line.
+func (c lintConfig) rulesFor(rules []Rule, file string) []Rule {
+	var out []Rule
+	for _, r := range rules {
+		rc, ok := c.Rules[r.ID()]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		if rc.Enabled != nil && !*rc.Enabled {
+			continue
+		}
+		if len(rc.Files) > 0 && !matchAny(rc.Files, file) {
+			continue
+		}
+		if len(rc.Exclude) > 0 && matchAny(rc.Exclude, file) {
+			continue
+		}
+		if rc.Severity != "" && rc.Severity != r.Severity() {
+			r = severityRule{Rule: r, severity: rc.Severity}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// severityRule overrides the severity of a rule
+type severityRule struct {
+	Rule
+	severity Severity
+}
+
+func (r severityRule) Severity() Severity { return r.severity }
+
+// CheckTyped keeps typed rules typed when their severity is overridden
+func (r severityRule) CheckTyped(stmt ast.StmtNode, refs columnRefs) []Violation {
+	if typed, ok := r.Rule.(typedRule); ok {
+		return typed.CheckTyped(stmt, refs)
+	}
+	return nil
+}
+
+func ruleIDs(rules []Rule) []string {
+	ids := make([]string, len(rules))
+	for i, r := range rules {
+		ids[i] = r.ID()
+	}
+	return ids
+}
+
+// matchAny reports whether file matches one of the globs
+func matchAny(globs []string, file string) bool {
+	if file == "" {
+		return false
+	}
+	for _, glob := range globs {
+		if matchGlob(glob, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a file path against a glob where * and ? stay within a
+// directory and ** spans directories. Globs without a slash match the base
+// name, so "*.sql" matches files in any directory.
+func matchGlob(glob, file string) bool {
+	file = path.Clean(filepath.ToSlash(file))
+	file = strings.TrimPrefix(file, "./")
+	if !strings.Contains(glob, "/") {
+		file = path.Base(file)
+	}
+	return globRegexp(strings.TrimPrefix(glob, "./")).MatchString(file)
+}
+
+// globRegexp compiles a glob into an anchored regular expression
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
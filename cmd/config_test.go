@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".dbsqlx.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"Empty file", "", ""},
+		{"Rules", "lint:\n  rules:\n    select-star:\n      enabled: false\n    missing-where:\n      severity: warning\n      files: [\"migrations/**\"]\n", ""},
+		{"Unknown rule", "lint:\n  rules:\n    no-where-delete:\n      enabled: false\n", `unknown lint rule "no-where-delete"`},
+		{"Unknown severity", "lint:\n  rules:\n    select-star:\n      severity: fatal\n", `rule select-star: unknown severity "fatal"`},
+		{"Unknown field", "lint:\n  rule:\n    select-star: {}\n", "field rule not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(writeConfig(t, tt.content), true)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	missing := filepath.Join(t.TempDir(), ".dbsqlx.yaml")
+	if _, err := loadConfig(missing, false); err != nil {
+		t.Errorf("loadConfig() of a missing default file error = %v", err)
+	}
+	if _, err := loadConfig(missing, true); err == nil {
+		t.Errorf("loadConfig() of a missing --config file expected an error")
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, `
+lint:
+  rules:
+    select-star:
+      enabled: false
+    large-offset:
+      severity: error
+    missing-where:
+      exclude: ["migrations/purge_*.sql"]
+    order-by-rand:
+      files: ["reports/**/*.sql"]
+`), true)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	severities := func(file string) map[string]Severity {
+		got := map[string]Severity{}
+		for _, r := range cfg.Lint.rulesFor(BuiltinRules(), file) {
+			got[r.ID()] = r.Severity()
+		}
+		return got
+	}
+
+	got := severities("migrations/001.sql")
+	if _, ok := got["select-star"]; ok {
+		t.Errorf("disabled select-star still applies")
+	}
+	if got["large-offset"] != SeverityError {
+		t.Errorf("large-offset severity = %q, want error", got["large-offset"])
+	}
+	if got["missing-where"] != SeverityError {
+		t.Errorf("missing-where should apply to migrations/001.sql")
+	}
+	if _, ok := got["order-by-rand"]; ok {
+		t.Errorf("order-by-rand is scoped to reports/ but applies to migrations/001.sql")
+	}
+
+	if _, ok := severities("./migrations/purge_2024.sql")["missing-where"]; ok {
+		t.Errorf("missing-where applies to an excluded file")
+	}
+	if _, ok := severities("reports/daily/top.sql")["order-by-rand"]; !ok {
+		t.Errorf("order-by-rand should apply to reports/daily/top.sql")
+	}
+	// SQL from the command line has no file for scoped rules to match
+	if _, ok := severities("")["order-by-rand"]; ok {
+		t.Errorf("order-by-rand is scoped to reports/ but applies to command-line SQL")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob, file string
+		want       bool
+	}{
+		{"*.sql", "a.sql", true},
+		{"*.sql", "migrations/deep/a.sql", true},
+		{"migrations/*.sql", "migrations/a.sql", true},
+		{"migrations/*.sql", "migrations/deep/a.sql", false},
+		{"migrations/**/*.sql", "migrations/a.sql", true},
+		{"migrations/**/*.sql", "migrations/deep/er/a.sql", true},
+		{"migrations/**", "migrations/deep/a.sql", true},
+		{"./migrations/?.sql", "./migrations/a.sql", true},
+		{"migrations/?.sql", "migrations/ab.sql", false},
+		{"seed[1].sql", "seed[1].sql", true},
+		{"seed[1].sql", "seed1.sql", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.glob, tt.file); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.glob, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestSeverityRuleKeepsCheck(t *testing.T) {
+	r := severityRule{Rule: ruleByID(t, "select-star"), severity: SeverityError}
+	stmtNodes, err := ParseAll("SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Check(stmtNodes[0]); len(got) != 1 || r.Severity() != SeverityError || r.ID() != "select-star" {
+		t.Errorf("severityRule = %s/%s with %v", r.ID(), r.Severity(), got)
+	}
+	if !reflect.DeepEqual(ruleIDs([]Rule{r}), []string{"select-star"}) {
+		t.Errorf("ruleIDs() = %v", ruleIDs([]Rule{r}))
+	}
+}
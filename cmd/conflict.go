@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/opcode"
+)
+
+// keyCatalog knows the column order and unique keys (primary key first) of
+// tables, from CREATE TABLE statements or --pk mappings
+type keyCatalog struct {
+	columns map[string][]string
+	keys    map[string][][]string
+}
+
+func newKeyCatalog() *keyCatalog {
+	return &keyCatalog{columns: make(map[string][]string), keys: make(map[string][][]string)}
+}
+
+// loadKeyCatalog reads the CREATE TABLE statements in schemaFile (if any)
+// and adds the --pk mappings on top
+func loadKeyCatalog(schemaFile string, pkMappings []string) (*keyCatalog, error) {
+	c := newKeyCatalog()
+	if schemaFile != "" {
+		content, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading schema file: %v", err)
+		}
+		stmtNodes, err := ParseAll(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("schema parse error: %v", err)
+		}
+		for _, stmtNode := range stmtNodes {
+			if create, ok := stmtNode.(*ast.CreateTableStmt); ok {
+				c.addCreateTable(create)
+			}
+		}
+	}
+	for _, mapping := range pkMappings {
+		if err := c.addMapping(mapping); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// addMapping adds a "table=col[,col...]" unique key
+func (c *keyCatalog) addMapping(mapping string) error {
+	table, cols, ok := strings.Cut(mapping, "=")
+	table = strings.ToLower(strings.TrimSpace(table))
+	if !ok || table == "" || strings.TrimSpace(cols) == "" {
+		return fmt.Errorf("invalid --pk %q (want table=column[,column...])", mapping)
+	}
+	var key []string
+	for _, col := range strings.Split(cols, ",") {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if col == "" {
+			return fmt.Errorf("invalid --pk %q (want table=column[,column...])", mapping)
+		}
+		key = append(key, col)
+	}
+	c.keys[table] = append(c.keys[table], key)
+	return nil
+}
+
+// addCreateTable records the columns, primary key and unique keys of a table
+func (c *keyCatalog) addCreateTable(create *ast.CreateTableStmt) {
+	table := create.Table.Name.L
+	var columns []string
+	var primary []string
+	var unique [][]string
+
+	for _, col := range create.Cols {
+		name := col.Name.Name.L
+		columns = append(columns, name)
+		for _, opt := range col.Options {
+			switch opt.Tp {
+			case ast.ColumnOptionPrimaryKey:
+				primary = []string{name}
+			case ast.ColumnOptionUniqKey:
+				unique = append(unique, []string{name})
+			}
+		}
+	}
+	for _, constraint := range create.Constraints {
+		var key []string
+		for _, part := range constraint.Keys {
+			if part.Column == nil {
+				// Expression indexes can't be matched against VALUES
+				key = nil
+				break
+			}
+			key = append(key, part.Column.Name.L)
+		}
+		if key == nil {
+			continue
+		}
+		switch constraint.Tp {
+		case ast.ConstraintPrimaryKey:
+			primary = key
+		case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			unique = append(unique, key)
+		}
+	}
+
+	c.columns[table] = columns
+	if primary != nil {
+		c.keys[table] = append([][]string{primary}, unique...)
+	} else {
+		c.keys[table] = unique
+	}
+}
+
+// primaryKey returns the primary (or first unique) key of a table, or nil
+// when none is known. A nil catalog knows no keys.
+func (c *keyCatalog) primaryKey(table string) []string {
+	if c == nil {
+		return nil
+	}
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	if keys := c.keys[strings.ToLower(table)]; len(keys) > 0 {
+		return keys[0]
+	}
+	return nil
+}
+
+// noKeyReason explains why rows of table can't be picked out by key
+func noKeyReason(table string) string {
+	return fmt.Sprintf("no primary or unique key known for %s (use --pk or --schema)", table)
+}
+
+// conflictFilter returns the filter matching the existing rows a REPLACE or
+// INSERT ... ON DUPLICATE KEY UPDATE may overwrite, or "" with a reason when
+// the rows can't be narrowed down and the whole table has to be dumped
+func conflictFilter(stmt *ast.InsertStmt, catalog *keyCatalog) (string, string) {
+	if !stmt.IsReplace && len(stmt.OnDuplicate) == 0 {
+		return "", "plain INSERT overwrites no rows"
+	}
+	if stmt.Select != nil || len(stmt.Lists) == 0 {
+		return "", "rows come from a SELECT rather than literal VALUES"
+	}
+	if stmt.Table == nil || stmt.Table.TableRefs == nil {
+		return "", "no target table"
+	}
+	source, ok := stmt.Table.TableRefs.Left.(*ast.TableSource)
+	if !ok {
+		return "", "no target table"
+	}
+	tn, ok := source.Source.(*ast.TableName)
+	if !ok {
+		return "", "no target table"
+	}
+	table := tn.Name.L
+
+	keys := catalog.keys[table]
+	if len(keys) == 0 {
+		return "", noKeyReason(tn.Name.O)
+	}
+
+	columns := make([]string, len(stmt.Columns))
+	for i, col := range stmt.Columns {
+		columns[i] = col.Name.L
+	}
+	if len(columns) == 0 {
+		columns = catalog.columns[table]
+		if len(columns) == 0 {
+			return "", fmt.Sprintf("no column list and no --schema for %s", tn.Name.O)
+		}
+	}
+
+	var conditions []string
+	for _, key := range keys {
+		positions := make([]int, len(key))
+		for i, col := range key {
+			positions[i] = slices.Index(columns, col)
+		}
+		if slices.Contains(positions, -1) {
+			// Key columns left to their defaults (auto increment) can't
+			// collide with existing rows
+			continue
+		}
+
+		var tuples []string
+		for _, row := range stmt.Lists {
+			if len(row) != len(columns) {
+				return "", "VALUES rows don't match the column list"
+			}
+			var values []string
+			for _, pos := range positions {
+				if !isLiteral(row[pos]) {
+					return "", fmt.Sprintf("non-literal value for key column %s", columns[pos])
+				}
+				values = append(values, restoreSQL(row[pos]))
+			}
+			tuple := values[0]
+			if len(values) > 1 {
+				tuple = "(" + strings.Join(values, ",") + ")"
+			}
+			tuples = appendUnique(tuples, tuple)
+		}
+
+		target := key[0]
+		if len(key) > 1 {
+			target = "(" + strings.Join(key, ",") + ")"
+		}
+		if len(tuples) == 1 {
+			conditions = append(conditions, target+"="+tuples[0])
+		} else {
+			conditions = append(conditions, target+" IN ("+strings.Join(tuples, ",")+")")
+		}
+	}
+	if len(conditions) == 0 {
+		return "", "no unique key is fully given in VALUES"
+	}
+	return strings.Join(conditions, " OR "), ""
+}
+
+// isLiteral reports whether expr is a constant, optionally negated
+func isLiteral(expr ast.ExprNode) bool {
+	switch e := expr.(type) {
+	case ast.ValueExpr:
+		return true
+	case *ast.UnaryOperationExpr:
+		if e.Op == opcode.Minus || e.Op == opcode.Plus {
+			return isLiteral(e.V)
+		}
+	}
+	return false
+}
+
+// applyConflictFilters narrows the target-table dump of every REPLACE and
+// INSERT ... ON DUPLICATE KEY UPDATE to the rows it may overwrite. Those
+// dumps default to the pre-dml-backup preset. Statements that can't be
+// narrowed keep the whole-table dump and get a note explaining why.
+func applyConflictFilters(plans []statementPlan, stmtNodes []ast.StmtNode, catalog *keyCatalog) []string {
+	var notes []string
+	for i := range plans {
+		plan := &plans[i]
+		if plan.Index < 1 || plan.Index > len(stmtNodes) {
+			continue
+		}
+		stmt, ok := stmtNodes[plan.Index-1].(*ast.InsertStmt)
+		if !ok || (!stmt.IsReplace && len(stmt.OnDuplicate) == 0) {
+			continue
+		}
+
+		filter, reason := conflictFilter(stmt, catalog)
+		if filter == "" {
+			notes = append(notes, fmt.Sprintf("# Statement %d: dumping the whole table: %s", plan.Index, reason))
+			continue
+		}
+		plan.Preset = "pre-dml-backup"
+		if len(plan.Dumps) > 0 {
+			plan.Dumps = []tableDump{{Table: plan.Dumps[0].Table, Filter: filter}}
+		}
+	}
+	return notes
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+const conflictSchema = `CREATE TABLE users (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  email VARCHAR(100) NOT NULL,
+  name VARCHAR(100),
+  UNIQUE KEY uk_email (email)
+);
+CREATE TABLE stock (
+  warehouse_id INT NOT NULL,
+  sku VARCHAR(20) NOT NULL,
+  qty INT,
+  PRIMARY KEY (warehouse_id, sku)
+);`
+
+func TestConflictFilter(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.sql")
+	if err := os.WriteFile(schema, []byte(conflictSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := loadKeyCatalog(schema, []string{"events=event_id"})
+	if err != nil {
+		t.Fatalf("loadKeyCatalog() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		sql        string
+		wantFilter string
+		wantReason string
+	}{
+		{
+			name:       "REPLACE matches primary and unique keys",
+			sql:        "REPLACE INTO users (id, email, name) VALUES (1, 'a@x.io', 'A'), (2, 'b@x.io', 'B')",
+			wantFilter: "id IN (1,2) OR email IN ('a@x.io','b@x.io')",
+		},
+		{
+			name:       "Auto increment id left out",
+			sql:        "INSERT INTO users (email, name) VALUES ('a@x.io', 'A') ON DUPLICATE KEY UPDATE name = VALUES(name)",
+			wantFilter: "email='a@x.io'",
+		},
+		{
+			name:       "Composite key without a column list",
+			sql:        "REPLACE INTO stock VALUES (1, 'X-1', 5), (1, 'X-2', -3), (1, 'X-1', 7)",
+			wantFilter: "(warehouse_id,sku) IN ((1,'X-1'),(1,'X-2'))",
+		},
+		{
+			name:       "--pk mapping",
+			sql:        "INSERT INTO events SET event_id = 42, payload = 'x' ON DUPLICATE KEY UPDATE payload = 'x'",
+			wantFilter: "event_id=42",
+		},
+		{
+			name:       "Unknown table",
+			sql:        "REPLACE INTO logs (id) VALUES (1)",
+			wantReason: "no primary or unique key known for logs (use --pk or --schema)",
+		},
+		{
+			name:       "Non-literal key",
+			sql:        "REPLACE INTO users (id, email) VALUES (1 + 1, 'a@x.io')",
+			wantReason: "non-literal value for key column id",
+		},
+		{
+			name:       "INSERT ... SELECT",
+			sql:        "REPLACE INTO users (id, email) SELECT id, email FROM staging",
+			wantReason: "rows come from a SELECT rather than literal VALUES",
+		},
+		{
+			name:       "Plain INSERT",
+			sql:        "INSERT INTO users (id, email) VALUES (1, 'a@x.io')",
+			wantReason: "plain INSERT overwrites no rows",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			filter, reason := conflictFilter(stmtNodes[0].(*ast.InsertStmt), catalog)
+			if filter != tt.wantFilter || reason != tt.wantReason {
+				t.Errorf("conflictFilter() = (%q, %q), want (%q, %q)", filter, reason, tt.wantFilter, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestApplyConflictFilters(t *testing.T) {
+	catalog, err := loadKeyCatalog("", []string{"users=id"})
+	if err != nil {
+		t.Fatalf("loadKeyCatalog() error = %v", err)
+	}
+	stmtNodes, err := ParseAll(`REPLACE INTO users (id, name) VALUES (7, 'x');
+INSERT INTO logs (msg) VALUES ('hi') ON DUPLICATE KEY UPDATE msg = 'hi';
+INSERT INTO users (id, name) VALUES (8, 'y')`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	plans := buildDumpPlan(stmtNodes, nil)
+	notes := applyConflictFilters(plans, stmtNodes, catalog)
+
+	if want := []tableDump{{Table: "users", Filter: "id=7"}}; !reflect.DeepEqual(plans[0].Dumps, want) || plans[0].Preset != "pre-dml-backup" {
+		t.Errorf("REPLACE plan = %+v, want dumps %+v with pre-dml-backup", plans[0], want)
+	}
+	if want := []tableDump{{Table: "logs"}}; !reflect.DeepEqual(plans[1].Dumps, want) {
+		t.Errorf("unnarrowed plan dumps = %+v, want %+v", plans[1].Dumps, want)
+	}
+	if want := []tableDump{{Table: "users"}}; !reflect.DeepEqual(plans[2].Dumps, want) || plans[2].Preset != "none" {
+		t.Errorf("plain INSERT plan = %+v, want unchanged", plans[2])
+	}
+	wantNotes := []string{"# Statement 2: dumping the whole table: no primary or unique key known for logs (use --pk or --schema)"}
+	if !reflect.DeepEqual(notes, wantNotes) {
+		t.Errorf("notes = %q, want %q", notes, wantNotes)
+	}
+}
+
+func TestLoadKeyCatalogRejectsBadMapping(t *testing.T) {
+	for _, mapping := range []string{"users", "=id", "users=", "users=id,,name"} {
+		if _, err := loadKeyCatalog("", []string{mapping}); err == nil {
+			t.Errorf("loadKeyCatalog(%q) expected error", mapping)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+	"github.com/pingcap/tidb/pkg/parser/opcode"
+	"github.com/pingcap/tidb/pkg/parser/types"
+)
+
+// columnRef is the schema column a column reference resolved to, and the
+// source (a table under one alias) of the statement it was read from
+type columnRef struct {
+	Table  string
+	Source int
+	Alias  string
+	Column *catalogColumn
+	// table is the source's table, to tell catalog tables from derived
+	// ones
+	table *catalogTable
+}
+
+func (c columnRef) String() string {
+	return c.Table + "." + c.Column.Name
+}
+
+// columnRefs maps the column references of a statement to their columns
+type columnRefs map[*ast.ColumnNameExpr]columnRef
+
+// resolveColumns resolves the column references of a statement against
+// catalog. References the catalog doesn't know are left out.
+func resolveColumns(stmt ast.StmtNode, catalog *schemaCatalog) columnRefs {
+	r := &resolver{catalog: catalog, refs: columnRefs{}}
+	r.statement(stmt)
+	return r.refs
+}
+
+// typedRule is a Rule that needs the schema's column types. Lint runs
+// CheckTyped on top of Check when --schema is given.
+type typedRule interface {
+	Rule
+	CheckTyped(stmt ast.StmtNode, refs columnRefs) []Violation
+}
+
+// typedFuncRule adapts a typed check function to the typedRule interface.
+// Without a schema it finds nothing.
+type typedFuncRule struct {
+	id       string
+	severity Severity
+	check    func(stmt ast.StmtNode, refs columnRefs) []Violation
+}
+
+func (r typedFuncRule) ID() string                     { return r.id }
+func (r typedFuncRule) Severity() Severity             { return r.severity }
+func (r typedFuncRule) Check(ast.StmtNode) []Violation { return nil }
+func (r typedFuncRule) CheckTyped(stmt ast.StmtNode, refs columnRefs) []Violation {
+	return r.check(stmt, refs)
+}
+
+// valueClass groups types by how MySQL compares them
+type valueClass int
+
+const (
+	classOther valueClass = iota
+	classInteger
+	classNumber
+	classString
+	classTemporal
+)
+
+func typeClass(tp *types.FieldType) valueClass {
+	if tp == nil {
+		return classOther
+	}
+	switch tp.GetType() {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong:
+		return classInteger
+	case mysql.TypeNewDecimal, mysql.TypeFloat, mysql.TypeDouble:
+		return classNumber
+	case mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeString,
+		mysql.TypeTinyBlob, mysql.TypeBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+		return classString
+	case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
+		return classTemporal
+	}
+	return classOther
+}
+
+// typeName renders a column type the way SHOW CREATE TABLE does in MySQL
+// 8.0, e.g. VARCHAR(20) or BIGINT UNSIGNED
+func typeName(tp *types.FieldType) string {
+	if typeClass(tp) != classInteger {
+		return strings.ToUpper(tp.CompactStr())
+	}
+	name := strings.ToUpper(types.TypeToStr(tp.GetType(), ""))
+	if mysql.HasUnsignedFlag(tp.GetFlag()) {
+		name += " UNSIGNED"
+	}
+	return name
+}
+
+// comparedPair is two operands a statement compares
+type comparedPair struct {
+	L, R ast.ExprNode
+}
+
+// comparisons lists the operand pairs of the comparisons, IN lists and
+// BETWEENs of a statement
+func comparisons(stmt ast.StmtNode) []comparedPair {
+	var pairs []comparedPair
+	walk(stmt, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.BinaryOperationExpr:
+			if isComparison(e.Op) || e.Op == opcode.NullEQ {
+				pairs = append(pairs, comparedPair{e.L, e.R})
+			}
+		case *ast.PatternInExpr:
+			for _, item := range e.List {
+				pairs = append(pairs, comparedPair{e.Expr, item})
+			}
+		case *ast.BetweenExpr:
+			pairs = append(pairs, comparedPair{e.Expr, e.Left}, comparedPair{e.Expr, e.Right})
+		}
+		return false
+	})
+	return pairs
+}
+
+// literal classifies a constant as classNumber or classString
+func literal(expr ast.ExprNode) (valueClass, bool) {
+	if u, ok := expr.(*ast.UnaryOperationExpr); ok && u.Op == opcode.Minus {
+		expr = u.V
+	}
+	v, ok := expr.(ast.ValueExpr)
+	if !ok {
+		return classOther, false
+	}
+	switch v.GetValue().(type) {
+	case int64, uint64, float64:
+		return classNumber, true
+	case string:
+		return classString, true
+	}
+	// Decimals have no type of their own in the parser package
+	if v.GetType().GetType() == mysql.TypeNewDecimal {
+		return classNumber, true
+	}
+	return classOther, false
+}
+
+// dateLiteral matches the date and datetime strings MySQL converts, with
+// any of its delimiters, or as bare digits
+var dateLiteral = regexp.MustCompile(`^(\d{2,4}[-/.]\d{1,2}[-/.]\d{1,2}([ T]\d{1,2}(:\d{1,2}(:\d{1,2}(\.\d{1,6})?)?)?)?|\d{8}(\d{6})?)$`)
+
+// checkImplicitConversion reports columns compared to literals that MySQL
+// converts the column for: a string column to a number, or a date column
+// to a string that isn't a date
+func checkImplicitConversion(stmt ast.StmtNode, refs columnRefs) []Violation {
+	var found []Violation
+	for _, p := range comparisons(stmt) {
+		for _, side := range []comparedPair{{p.L, p.R}, {p.R, p.L}} {
+			cn, ok := side.L.(*ast.ColumnNameExpr)
+			if !ok {
+				continue
+			}
+			ref, ok := refs[cn]
+			if !ok {
+				continue
+			}
+			class, ok := literal(side.R)
+			if !ok {
+				continue
+			}
+			lit := describeSQL(side.R)
+			switch typeClass(ref.Column.Type) {
+			case classString:
+				if class == classNumber {
+					found = append(found, Violation{
+						Offset:  cn.OriginTextPosition(),
+						Message: fmt.Sprintf("%s is %s but is compared to the number %s, so every value is converted to a number and no index on it can be used; quote the literal", ref, typeName(ref.Column.Type), lit),
+					})
+				}
+			case classTemporal:
+				v, isValue := side.R.(ast.ValueExpr)
+				if class == classString && isValue && !dateLiteral.MatchString(strings.TrimSpace(v.GetValue().(string))) {
+					found = append(found, Violation{
+						Offset:  cn.OriginTextPosition(),
+						Message: fmt.Sprintf("%s is %s but is compared to %s, which isn't a date, so the values are compared as strings and no index on it can be used", ref, typeName(ref.Column.Type), lit),
+					})
+				}
+			}
+		}
+	}
+	return dedupe(found)
+}
+
+// columnPairs lists the comparisons between two resolved columns
+func columnPairs(stmt ast.StmtNode, refs columnRefs) [][2]*ast.ColumnNameExpr {
+	var pairs [][2]*ast.ColumnNameExpr
+	for _, p := range comparisons(stmt) {
+		l, lok := p.L.(*ast.ColumnNameExpr)
+		r, rok := p.R.(*ast.ColumnNameExpr)
+		if !lok || !rok {
+			continue
+		}
+		if _, ok := refs[l]; !ok {
+			continue
+		}
+		if _, ok := refs[r]; ok {
+			pairs = append(pairs, [2]*ast.ColumnNameExpr{l, r})
+		}
+	}
+	return pairs
+}
+
+// checkCollationMismatch reports string columns compared across collations
+func checkCollationMismatch(stmt ast.StmtNode, refs columnRefs) []Violation {
+	var found []Violation
+	for _, p := range columnPairs(stmt, refs) {
+		l, r := refs[p[0]], refs[p[1]]
+		if typeClass(l.Column.Type) != classString || typeClass(r.Column.Type) != classString {
+			continue
+		}
+		if l.Column.Collation == "" || r.Column.Collation == "" || l.Column.Collation == r.Column.Collation {
+			continue
+		}
+		found = append(found, Violation{
+			Offset:  p[0].OriginTextPosition(),
+			Message: fmt.Sprintf("%s (%s) and %s (%s) have different collations, so one side is converted and no index on it can be used", l, l.Column.Collation, r, r.Column.Collation),
+		})
+	}
+	return dedupe(found)
+}
+
+// checkJoinTypeMismatch reports columns compared across types that keep
+// MySQL from using an index for the comparison: signed with unsigned
+// integers, or strings with numbers
+func checkJoinTypeMismatch(stmt ast.StmtNode, refs columnRefs) []Violation {
+	var found []Violation
+	for _, p := range columnPairs(stmt, refs) {
+		l, r := refs[p[0]], refs[p[1]]
+		lc, rc := typeClass(l.Column.Type), typeClass(r.Column.Type)
+
+		var why string
+		switch {
+		case lc == classInteger && rc == classInteger:
+			if mysql.HasUnsignedFlag(l.Column.Type.GetFlag()) != mysql.HasUnsignedFlag(r.Column.Type.GetFlag()) {
+				why = "indexes serve joins best between columns of the same type and signedness"
+			}
+		case lc == classString && (rc == classInteger || rc == classNumber):
+			why = fmt.Sprintf("%s is converted to a number for every row, so no index on it can be used", l)
+		case rc == classString && (lc == classInteger || lc == classNumber):
+			why = fmt.Sprintf("%s is converted to a number for every row, so no index on it can be used", r)
+		}
+		if why == "" {
+			continue
+		}
+		found = append(found, Violation{
+			Offset:  p[0].OriginTextPosition(),
+			Message: fmt.Sprintf("%s (%s) is compared to %s (%s): %s; give both the same type", l, typeName(l.Column.Type), r, typeName(r.Column.Type), why),
+		})
+	}
+	return dedupe(found)
+}
+
+// dedupe keeps the first violation of each column reference, e.g. of a
+// column compared to several numbers of one IN list
+func dedupe(found []Violation) []Violation {
+	var out []Violation
+	for _, v := range found {
+		if !slices.ContainsFunc(out, func(o Violation) bool { return o.Offset == v.Offset }) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
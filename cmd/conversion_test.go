@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const typedSchema = `
+CREATE TABLE users (
+  id INT PRIMARY KEY,
+  phone VARCHAR(20),
+  email VARCHAR(255) COLLATE utf8mb4_general_ci,
+  created_at DATETIME,
+  birthday DATE
+) DEFAULT CHARSET=utf8mb4;
+CREATE TABLE accounts (
+  id BIGINT UNSIGNED PRIMARY KEY,
+  user_id BIGINT UNSIGNED,
+  owner_id INT,
+  email VARCHAR(255),
+  legacy_email VARCHAR(255) CHARACTER SET latin1,
+  ref VARCHAR(32),
+  score DECIMAL(5,2)
+) DEFAULT CHARSET=utf8mb4;
+CREATE VIEW user_emails AS SELECT id, email FROM users;
+`
+
+func TestTypedRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		sql  string
+		// want lists the messages found, each prefixed with its offset
+		want []string
+	}{
+		{"implicit-conversion", "SELECT id FROM users WHERE phone = 5551234", []string{
+			"27 users.phone is VARCHAR(20) but is compared to the number 5551234, so every value is converted to a number and no index on it can be used; quote the literal",
+		}},
+		{"implicit-conversion", "SELECT id FROM users WHERE -1 < phone", []string{
+			"32 users.phone is VARCHAR(20) but is compared to the number -1, so every value is converted to a number and no index on it can be used; quote the literal",
+		}},
+		{"implicit-conversion", "DELETE FROM users WHERE phone IN (1, 2, '3')", []string{
+			"24 users.phone is VARCHAR(20) but is compared to the number 1, so every value is converted to a number and no index on it can be used; quote the literal",
+		}},
+		{"implicit-conversion", "SELECT id FROM users WHERE phone = '5551234' AND id = '7'", nil},
+		{"implicit-conversion", "SELECT id FROM users WHERE created_at > 'yesterday'", []string{
+			"27 users.created_at is DATETIME but is compared to 'yesterday', which isn't a date, so the values are compared as strings and no index on it can be used",
+		}},
+		{"implicit-conversion", "SELECT id FROM users WHERE birthday BETWEEN '2024-01-01' AND '2024/12/31 23:59:59' OR created_at = '20240101120000'", nil},
+		{"implicit-conversion", "SELECT id FROM users u WHERE EXISTS (SELECT 1 FROM accounts a WHERE a.ref = 5)", []string{
+			"68 accounts.ref is VARCHAR(32) but is compared to the number 5, so every value is converted to a number and no index on it can be used; quote the literal",
+		}},
+		{"implicit-conversion", "SELECT id FROM unknown WHERE phone = 5", nil},
+
+		{"collation-mismatch", "SELECT u.id FROM users u JOIN accounts a ON a.email = u.email", []string{
+			"44 accounts.email (utf8mb4_0900_ai_ci) and users.email (utf8mb4_general_ci) have different collations, so one side is converted and no index on it can be used",
+		}},
+		{"collation-mismatch", "SELECT a.id FROM accounts a JOIN accounts b ON a.legacy_email = b.email", []string{
+			"47 accounts.legacy_email (latin1_swedish_ci) and accounts.email (utf8mb4_0900_ai_ci) have different collations, so one side is converted and no index on it can be used",
+		}},
+		{"collation-mismatch", "SELECT e.id FROM user_emails e JOIN users u ON u.email = e.email", nil},
+
+		{"join-type-mismatch", "SELECT u.id FROM users u JOIN accounts a ON a.user_id = u.id", []string{
+			"44 accounts.user_id (BIGINT UNSIGNED) is compared to users.id (INT): indexes serve joins best between columns of the same type and signedness; give both the same type",
+		}},
+		{"join-type-mismatch", "SELECT u.id FROM users u JOIN accounts a ON a.owner_id = u.id", nil},
+		{"join-type-mismatch", "SELECT u.id FROM users u, accounts a WHERE u.id = a.ref", []string{
+			"43 users.id (INT) is compared to accounts.ref (VARCHAR(32)): accounts.ref is converted to a number for every row, so no index on it can be used; give both the same type",
+		}},
+		{"join-type-mismatch", "SELECT a.id FROM accounts a JOIN users u ON a.score = u.phone", []string{
+			"44 accounts.score (DECIMAL(5,2)) is compared to users.phone (VARCHAR(20)): users.phone is converted to a number for every row, so no index on it can be used; give both the same type",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.sql[:min(len(tt.sql), 40)], func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			rule := ruleByID(t, tt.rule).(typedRule)
+			if got := rule.Check(stmtNodes[0]); got != nil {
+				t.Errorf("Check() without a schema = %v, want nil", got)
+			}
+
+			refs := resolveColumns(stmtNodes[0], testCatalog(t, typedSchema))
+			var got []string
+			for _, v := range rule.CheckTyped(stmtNodes[0], refs) {
+				got = append(got, fmt.Sprintf("%d %s", v.Offset, v.Message))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckTyped() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogColumnTypes(t *testing.T) {
+	c := testCatalog(t, typedSchema+`
+CREATE TABLE plain (name VARCHAR(10), n INT);
+ALTER TABLE accounts ADD COLUMN note TEXT;
+`)
+
+	tests := []struct {
+		table, column string
+		wantType      string
+		wantCollation string
+	}{
+		{"users", "id", "INT", ""},
+		{"users", "phone", "VARCHAR(20)", "utf8mb4_0900_ai_ci"},
+		{"users", "email", "VARCHAR(255)", "utf8mb4_general_ci"},
+		{"accounts", "user_id", "BIGINT UNSIGNED", ""},
+		{"accounts", "legacy_email", "VARCHAR(255)", "latin1_swedish_ci"},
+		{"accounts", "note", "TEXT", "utf8mb4_0900_ai_ci"},
+		{"plain", "name", "VARCHAR(10)", ""},
+		{"user_emails", "email", "VARCHAR(255)", "utf8mb4_general_ci"},
+	}
+	for _, tt := range tests {
+		col := c.tables[tt.table].column(tt.column)
+		if col == nil {
+			t.Errorf("%s.%s missing", tt.table, tt.column)
+			continue
+		}
+		if got := typeName(col.Type); got != tt.wantType || col.Collation != tt.wantCollation {
+			t.Errorf("%s.%s = %s %q, want %s %q", tt.table, tt.column, got, col.Collation, tt.wantType, tt.wantCollation)
+		}
+	}
+}
+
+func TestLintSQLWithSchema(t *testing.T) {
+	sql := "CREATE TABLE t (code VARCHAR(8));\n" +
+		"SELECT code FROM t WHERE code = 42"
+
+	if report := LintSQL(sql, "m.sql", BuiltinRules(), nil); len(report.Findings) != 0 {
+		t.Errorf("Findings without a schema = %v", report.Findings)
+	}
+
+	// A severity override keeps the rule typed
+	rules := []Rule{severityRule{Rule: ruleByID(t, "implicit-conversion"), severity: SeverityError}}
+	report := LintSQL(sql, "m.sql", rules, newSchemaCatalog())
+	want := "m.sql:2:26: error: t.code is VARCHAR(8) but is compared to the number 42, so every value is converted to a number and no index on it can be used; quote the literal [implicit-conversion] (statement 2)"
+	if len(report.Findings) != 1 || report.Findings[0].String() != want {
+		t.Errorf("Findings = %v, want %s", report.Findings, want)
+	}
+}
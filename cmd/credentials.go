@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Credential modes for generated client commands. None of them ever place
+// the password itself on the command line.
+const (
+	credModeEnv          = "env"
+	credModeDefaultsFile = "defaults-file"
+	credModeLoginPath    = "login-path"
+	credModePrompt       = "prompt"
+)
+
+var credentialModes = []string{credModeEnv, credModeDefaultsFile, credModeLoginPath, credModePrompt}
+
+const redactedSecret = "****"
+
+// minRawSecretLen is the shortest password masked wherever it appears.
+// Shorter ones such as "root" or "1" would mangle ordinary text, so they
+// are only masked in credential contexts.
+const minRawSecretLen = 6
+
+// credentials describes how generated mysql/mysqldump commands authenticate
+type credentials struct {
+	Mode         string
+	Password     string
+	LoginPath    string
+	DefaultsFile string
+}
+
+// resolveCredentials picks the credential mode from the flags. Without an
+// explicit mode, --login-path wins, a supplied password goes to a temporary
+// defaults file, and otherwise the client falls back to MYSQL_PWD.
+func resolveCredentials(mode, password, loginPath string) (*credentials, error) {
+	c := &credentials{Mode: strings.TrimSpace(mode), Password: password, LoginPath: strings.TrimSpace(loginPath)}
+
+	if c.Mode == "" {
+		switch {
+		case c.LoginPath != "":
+			c.Mode = credModeLoginPath
+		case c.Password != "":
+			c.Mode = credModeDefaultsFile
+		default:
+			c.Mode = credModeEnv
+		}
+	}
+
+	switch c.Mode {
+	case credModeEnv, credModePrompt:
+	case credModeLoginPath:
+		if c.LoginPath == "" {
+			return nil, fmt.Errorf("--password-mode=%s requires --login-path", credModeLoginPath)
+		}
+	case credModeDefaultsFile:
+		if c.Password == "" {
+			return nil, fmt.Errorf("--password-mode=%s requires --password", credModeDefaultsFile)
+		}
+		path, err := writeDefaultsExtraFile(c.Password)
+		if err != nil {
+			return nil, err
+		}
+		c.DefaultsFile = path
+	default:
+		return nil, fmt.Errorf("unknown password mode %q (valid: %s)", c.Mode, strings.Join(credentialModes, ", "))
+	}
+
+	return c, nil
+}
+
+// writeDefaultsExtraFile stores the password in a [client] option file that
+// only the current user can read
+func writeDefaultsExtraFile(password string) (string, error) {
+	f, err := os.CreateTemp("", "dbsqlx-*.cnf")
+	if err != nil {
+		return "", fmt.Errorf("error creating defaults file: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("error securing defaults file: %v", err)
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password)
+	if _, err := fmt.Fprintf(f, "[client]\npassword=\"%s\"\n", escaped); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("error writing defaults file: %v", err)
+	}
+
+	return f.Name(), nil
+}
+
+// Opts returns the client options carrying the credentials. Option-file
+// options must come first on a mysql client command line.
+func (c *credentials) Opts() string {
+	switch c.Mode {
+	case credModeDefaultsFile:
+		return fmt.Sprintf(" --defaults-extra-file=%s", c.DefaultsFile)
+	case credModeLoginPath:
+		return fmt.Sprintf(" --login-path=%s", c.LoginPath)
+	case credModePrompt:
+		return " -p"
+	}
+	return ""
+}
+
+// Notes returns comment lines explaining how the generated commands
+// authenticate, or nil when nothing needs explaining. removedOnExit tells
+// that a script cleans up the defaults file itself.
+func (c *credentials) Notes(removedOnExit bool) []string {
+	switch c.Mode {
+	case credModeDefaultsFile:
+		if removedOnExit {
+			return []string{fmt.Sprintf("# Credentials are read from %s (mode 0600), removed when the script exits", c.DefaultsFile)}
+		}
+		return []string{
+			fmt.Sprintf("# Credentials are read from %s (mode 0600)", c.DefaultsFile),
+			fmt.Sprintf("# Remove it when done: rm -f %s", c.DefaultsFile),
+		}
+	case credModeEnv:
+		if c.Password != "" {
+			return []string{"# Credentials are read from MYSQL_PWD; export it before running these commands"}
+		}
+	}
+	return nil
+}
+
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Environment references such as "$MYSQL_PWD" are not secrets
+	{regexp.MustCompile(`(--password=)([^"$\s]\S*|"[^"$]*")`), "${1}" + redactedSecret},
+	{regexp.MustCompile(`(MYSQL_PWD=)\S+`), "${1}" + redactedSecret},
+	{regexp.MustCompile(`(\s-p)\S+`), "${1}" + redactedSecret},
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`), "${1}" + redactedSecret + "@"},
+}
+
+// secrets holds passwords learned from sources other than --password, such
+// as a DSN, so they can be redacted as well
+var secrets []string
+
+// registerSecret marks a value that must never appear in printed output
+func registerSecret(secret string) {
+	if secret != "" {
+		secrets = append(secrets, secret)
+	}
+}
+
+// redactSecrets masks anything that looks like an inline credential in text
+// that is about to be printed, and the configured password wherever it
+// appears when it is long enough not to match ordinary text
+func redactSecrets(s string) string {
+	for _, secret := range append([]string{password}, secrets...) {
+		if len(secret) >= minRawSecretLen {
+			s = strings.ReplaceAll(s, secret, redactedSecret)
+		}
+	}
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
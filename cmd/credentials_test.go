@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		password  string
+		loginPath string
+		wantMode  string
+		wantOpts  string
+		wantErr   bool
+	}{
+		{
+			name:     "No password falls back to MYSQL_PWD",
+			wantMode: credModeEnv,
+			wantOpts: "",
+		},
+		{
+			name:      "Login path implies login-path mode",
+			loginPath: "prod",
+			wantMode:  credModeLoginPath,
+			wantOpts:  " --login-path=prod",
+		},
+		{
+			name:     "Prompt mode",
+			mode:     credModePrompt,
+			password: "secret",
+			wantMode: credModePrompt,
+			wantOpts: " -p",
+		},
+		{
+			name:     "Explicit env mode ignores password",
+			mode:     credModeEnv,
+			password: "secret",
+			wantMode: credModeEnv,
+			wantOpts: "",
+		},
+		{
+			name:    "Login-path mode without login path",
+			mode:    credModeLoginPath,
+			wantErr: true,
+		},
+		{
+			name:    "Defaults-file mode without password",
+			mode:    credModeDefaultsFile,
+			wantErr: true,
+		},
+		{
+			name:     "Unknown mode",
+			mode:     "inline",
+			password: "secret",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := resolveCredentials(tt.mode, tt.password, tt.loginPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if c.Mode != tt.wantMode {
+				t.Errorf("Mode = %q, want %q", c.Mode, tt.wantMode)
+			}
+			if got := c.Opts(); got != tt.wantOpts {
+				t.Errorf("Opts() = %q, want %q", got, tt.wantOpts)
+			}
+		})
+	}
+}
+
+func TestDefaultsFileCredentials(t *testing.T) {
+	c, err := resolveCredentials("", `se"cret`, "")
+	if err != nil {
+		t.Fatalf("resolveCredentials() error = %v", err)
+	}
+	defer os.Remove(c.DefaultsFile)
+
+	if c.Mode != credModeDefaultsFile {
+		t.Fatalf("Mode = %q, want %q", c.Mode, credModeDefaultsFile)
+	}
+
+	info, err := os.Stat(c.DefaultsFile)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("defaults file permissions = %o, want 600", perm)
+	}
+
+	content, err := os.ReadFile(c.DefaultsFile)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "[client]\npassword=\"se\\\"cret\"\n"; string(content) != want {
+		t.Errorf("defaults file = %q, want %q", content, want)
+	}
+
+	if opts := c.Opts(); strings.Contains(opts, "cret") {
+		t.Errorf("Opts() leaked password: %q", opts)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	defer ResetGlobals()
+	password = "hunter2"
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "Inline password flag",
+			in:   "mysqldump -u root --password=abc db users",
+			want: "mysqldump -u root --password=**** db users",
+		},
+		{
+			name: "Environment reference is kept",
+			in:   `mysqlsh -u root --password="$MYSQL_PWD"`,
+			want: `mysqlsh -u root --password="$MYSQL_PWD"`,
+		},
+		{
+			name: "Short password flag",
+			in:   "mysql -u root -pabc db",
+			want: "mysql -u root -p**** db",
+		},
+		{
+			name: "Bare prompt flag is kept",
+			in:   "mysql -u root -p db",
+			want: "mysql -u root -p db",
+		},
+		{
+			name: "MYSQL_PWD assignment",
+			in:   "MYSQL_PWD=abc mysqldump db",
+			want: "MYSQL_PWD=**** mysqldump db",
+		},
+		{
+			name: "DSN password",
+			in:   "invalid DSN mysql://root:abc@db:3306/app",
+			want: "invalid DSN mysql://root:****@db:3306/app",
+		},
+		{
+			name: "Configured password anywhere",
+			in:   "access denied for hunter2",
+			want: "access denied for ****",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactSecrets(tt.in); got != tt.want {
+				t.Errorf("redactSecrets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Short passwords are masked in credential contexts only
+	password = "root"
+	in := "mysqldump -u root --password=root --where=\"id=1\" db users > db.users_1.sql"
+	want := "mysqldump -u root --password=**** --where=\"id=1\" db users > db.users_1.sql"
+	if got := redactSecrets(in); got != want {
+		t.Errorf("redactSecrets() = %q, want %q", got, want)
+	}
+}
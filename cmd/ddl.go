@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// ddlChanges summarises what an ALTER TABLE does to existing data
+type ddlChanges struct {
+	droppedColumns []string
+	typeChange     bool
+	indexChange    bool
+}
+
+// alterChanges inspects the specs of an ALTER TABLE
+func alterChanges(stmt *ast.AlterTableStmt) ddlChanges {
+	var c ddlChanges
+	for _, spec := range stmt.Specs {
+		switch spec.Tp {
+		case ast.AlterTableDropColumn:
+			if spec.OldColumnName != nil {
+				c.droppedColumns = appendUnique(c.droppedColumns, spec.OldColumnName.Name.O)
+			}
+		case ast.AlterTableModifyColumn, ast.AlterTableChangeColumn:
+			c.typeChange = true
+		case ast.AlterTableAddConstraint:
+			if spec.Constraint != nil && spec.Constraint.Tp != ast.ConstraintCheck {
+				c.indexChange = true
+			}
+		case ast.AlterTableDropIndex, ast.AlterTableDropPrimaryKey, ast.AlterTableDropForeignKey,
+			ast.AlterTableRenameIndex, ast.AlterTableIndexInvisible:
+			c.indexChange = true
+		}
+	}
+	return c
+}
+
+// applyDDLPlans replaces the generic dumps of destructive DDL with plans
+// suited to what the statement destroys, and labels each with the reason:
+//   - DROP TABLE and TRUNCATE get a full schema and data dump
+//   - ALTER TABLE ... DROP COLUMN gets a schema-only dump plus an export of
+//     the key and the dropped column
+//   - ALTERs changing column types or indexes capture SHOW CREATE TABLE
+//   - other ALTERs keep the schema-only dump
+//
+// The key of a dropped column's table comes from the catalog. Without one
+// the whole table is dumped instead, flagged as a superset.
+func applyDDLPlans(plans []statementPlan, stmtNodes []ast.StmtNode, catalog *keyCatalog) {
+	for i := range plans {
+		plan := &plans[i]
+		if plan.Index < 1 || plan.Index > len(stmtNodes) {
+			continue
+		}
+
+		switch stmt := stmtNodes[plan.Index-1].(type) {
+		case *ast.DropTableStmt:
+			if stmt.IsView {
+				continue
+			}
+			plan.Reason = "full schema and data dump: DROP TABLE removes the table"
+		case *ast.TruncateTableStmt:
+			plan.Reason = "full schema and data dump: TRUNCATE removes every row"
+		case *ast.AlterTableStmt:
+			if stmt.Table == nil || len(plan.Dumps) == 0 {
+				continue
+			}
+			table := plan.Dumps[0].Table
+			changes := alterChanges(stmt)
+
+			switch {
+			case len(changes.droppedColumns) > 0:
+				dropped := strings.Join(changes.droppedColumns, ", ")
+				key := catalog.primaryKey(table)
+				if key == nil {
+					plan.Preset = "consistent"
+					plan.Dumps = []tableDump{{Table: table, Superset: true}}
+					plan.Reason = fmt.Sprintf("superset dump: %s, so the whole table is dumped: DROP COLUMN discards %s values", noKeyReason(table), dropped)
+					continue
+				}
+				for _, col := range changes.droppedColumns {
+					plan.Queries = append(plan.Queries, planQuery{
+						Table: table,
+						Name:  "col_" + col,
+						Query: fmt.Sprintf("SELECT %s, %s FROM %s", quoteColumns(key), quoteIdent(col), quoteTableName(table)),
+					})
+				}
+				plan.Reason = fmt.Sprintf("schema-only dump plus %s values by %s: DROP COLUMN discards them",
+					dropped, strings.Join(key, ", "))
+			case changes.typeChange || changes.indexChange:
+				what := "column types"
+				if !changes.typeChange {
+					what = "indexes"
+				} else if changes.indexChange {
+					what = "column types and indexes"
+				}
+				plan.Dumps = nil
+				plan.Queries = []planQuery{{Table: table, Name: "create", Query: "SHOW CREATE TABLE " + quoteTableName(table), Definition: true}}
+				plan.Reason = fmt.Sprintf("SHOW CREATE TABLE capture: ALTER changes %s but keeps the rows", what)
+			default:
+				plan.Reason = "schema-only dump: ALTER keeps the existing rows"
+			}
+		}
+	}
+}
+
+// queryFile names the file a plan query's result is saved to
+func queryFile(database string, plan statementPlan, q planQuery) string {
+	return fmt.Sprintf("%s_%d%s", queryName(database, q), plan.Index, q.Ext())
+}
+
+// queryName identifies a plan query in output file names
+func queryName(database string, q planQuery) string {
+	return fmt.Sprintf("%s.%s_%s", database, q.Table, q.Name)
+}
+
+// Ext is the extension of the query's output file
+func (q planQuery) Ext() string {
+	if q.Definition {
+		return ".sql"
+	}
+	return ".tsv"
+}
+
+// queryCommand renders the mysql command that saves q's result into file.
+// Definitions keep only the CREATE statement column of the result.
+func queryCommand(s dumpSettings, q planQuery, file string) string {
+	if q.Definition {
+		return fmt.Sprintf("mysql%s -N --batch --raw -e %s %s | cut -f2- > %s", s.ConnOpts, shellQuote(q.Query), s.Database, file)
+	}
+	return fmt.Sprintf("mysql%s --batch -e %s %s > %s", s.ConnOpts, shellQuote(q.Query), s.Database, file)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyDDLPlans(t *testing.T) {
+	catalog, err := loadKeyCatalog("", []string{"orders=order_id"})
+	if err != nil {
+		t.Fatalf("loadKeyCatalog() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		sql         string
+		wantPreset  string
+		wantDumps   []tableDump
+		wantQueries []planQuery
+		wantReason  string
+	}{
+		{
+			name:       "DROP TABLE",
+			sql:        "DROP TABLE users",
+			wantPreset: "consistent",
+			wantDumps:  []tableDump{{Table: "users"}},
+			wantReason: "full schema and data dump: DROP TABLE removes the table",
+		},
+		{
+			name:       "TRUNCATE",
+			sql:        "TRUNCATE TABLE logs",
+			wantPreset: "consistent",
+			wantDumps:  []tableDump{{Table: "logs"}},
+			wantReason: "full schema and data dump: TRUNCATE removes every row",
+		},
+		{
+			name:       "DROP COLUMN with a known key",
+			sql:        "ALTER TABLE orders DROP COLUMN note, DROP COLUMN legacy_ref",
+			wantPreset: "schema-only",
+			wantDumps:  []tableDump{{Table: "orders"}},
+			wantQueries: []planQuery{
+				{Table: "orders", Name: "col_note", Query: "SELECT `order_id`, `note` FROM `orders`"},
+				{Table: "orders", Name: "col_legacy_ref", Query: "SELECT `order_id`, `legacy_ref` FROM `orders`"},
+			},
+			wantReason: "schema-only dump plus note, legacy_ref values by order_id: DROP COLUMN discards them",
+		},
+		{
+			name:       "DROP COLUMN without a known key",
+			sql:        "ALTER TABLE users DROP COLUMN age",
+			wantPreset: "consistent",
+			wantDumps:  []tableDump{{Table: "users", Superset: true}},
+			wantReason: "superset dump: no primary or unique key known for users (use --pk or --schema), so the whole table is dumped: DROP COLUMN discards age values",
+		},
+		{
+			name:        "MODIFY COLUMN",
+			sql:         "ALTER TABLE users MODIFY COLUMN name VARCHAR(200)",
+			wantPreset:  "schema-only",
+			wantQueries: []planQuery{{Table: "users", Name: "create", Query: "SHOW CREATE TABLE `users`", Definition: true}},
+			wantReason:  "SHOW CREATE TABLE capture: ALTER changes column types but keeps the rows",
+		},
+		{
+			name:        "Index and type changes",
+			sql:         "ALTER TABLE users ADD INDEX idx_name (name), CHANGE COLUMN age years INT",
+			wantPreset:  "schema-only",
+			wantQueries: []planQuery{{Table: "users", Name: "create", Query: "SHOW CREATE TABLE `users`", Definition: true}},
+			wantReason:  "SHOW CREATE TABLE capture: ALTER changes column types and indexes but keeps the rows",
+		},
+		{
+			name:        "DROP INDEX",
+			sql:         "ALTER TABLE users DROP INDEX idx_name",
+			wantPreset:  "schema-only",
+			wantQueries: []planQuery{{Table: "users", Name: "create", Query: "SHOW CREATE TABLE `users`", Definition: true}},
+			wantReason:  "SHOW CREATE TABLE capture: ALTER changes indexes but keeps the rows",
+		},
+		{
+			name:       "ADD COLUMN",
+			sql:        "ALTER TABLE users ADD COLUMN age INT",
+			wantPreset: "schema-only",
+			wantDumps:  []tableDump{{Table: "users"}},
+			wantReason: "schema-only dump: ALTER keeps the existing rows",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			plans := buildDumpPlan(stmtNodes, nil)
+			applyDDLPlans(plans, stmtNodes, catalog)
+			plan := plans[0]
+
+			if plan.Preset != tt.wantPreset {
+				t.Errorf("Preset = %q, want %q", plan.Preset, tt.wantPreset)
+			}
+			if !reflect.DeepEqual(plan.Dumps, tt.wantDumps) {
+				t.Errorf("Dumps = %+v, want %+v", plan.Dumps, tt.wantDumps)
+			}
+			if !reflect.DeepEqual(plan.Queries, tt.wantQueries) {
+				t.Errorf("Queries = %+v, want %+v", plan.Queries, tt.wantQueries)
+			}
+			if plan.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", plan.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestQueryCommand(t *testing.T) {
+	settings := dumpSettings{ConnOpts: " -u root", Database: "shop"}
+	plan := statementPlan{Index: 3}
+
+	tests := []struct {
+		q    planQuery
+		want string
+	}{
+		{
+			q:    planQuery{Table: "users", Name: "col_age", Query: "SELECT id, age FROM users"},
+			want: "mysql -u root --batch -e 'SELECT id, age FROM users' shop > shop.users_col_age_3.tsv",
+		},
+		{
+			q:    planQuery{Table: "users", Name: "create", Query: "SHOW CREATE TABLE users", Definition: true},
+			want: "mysql -u root -N --batch --raw -e 'SHOW CREATE TABLE users' shop | cut -f2- > shop.users_create_3.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := queryCommand(settings, tt.q, queryFile("shop", plan, tt.q)); got != tt.want {
+			t.Errorf("queryCommand() =\n%s\nwant\n%s", got, tt.want)
+		}
+	}
+}
+
+func TestWriteDumpScriptWithQueries(t *testing.T) {
+	stmtNodes, err := ParseAll("ALTER TABLE users DROP COLUMN age")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	plans := buildDumpPlan(stmtNodes, nil)
+	applyDDLPlans(plans, stmtNodes, testKeyCatalog(t, "users=id"))
+
+	settings := dumpSettings{ConnOpts: " -u root", Database: "shop", Compression: "none"}
+	var buf bytes.Buffer
+	if err := writeDumpScript(&buf, plans, settings, mysqldumpTool{}); err != nil {
+		t.Fatalf("writeDumpScript() error = %v", err)
+	}
+	script := buf.String()
+
+	for _, part := range []string{
+		"# Plan: schema-only dump plus age values by id",
+		"[1/2] Dumping shop.users",
+		"[2/2] Saving shop.users_col_age",
+		`file="$out_dir/shop.users_col_age_1_${ts}.tsv"`,
+		"mysql -u root --batch -e 'SELECT `id`, `age` FROM `users`' shop > \"$file\"",
+	} {
+		if !strings.Contains(script, part) {
+			t.Errorf("script missing %q\nGot:\n%s", part, script)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.sh")
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("bash", "-n", path).CombinedOutput(); err != nil {
+		t.Errorf("bash -n failed: %v\n%s", err, out)
+	}
+}
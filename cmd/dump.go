@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -9,8 +13,9 @@ import (
 
 var dumpCmd = &cobra.Command{
 	Use:   "dump [sql-statement]",
-	Short: "Generate mysqldump commands",
-	Long: `Generate mysqldump commands from SQL statements.
+	Short: "Generate mysqldump, mydumper, dumpling or mysqlsh commands",
+	Long: `Generate mysqldump commands from SQL statements, or the equivalent
+mydumper, dumpling or MySQL Shell util.dumpTables commands with --tool.
 
 Automatically filters WHERE conditions per table and provides helpers
 for JOINed queries.
@@ -18,13 +23,87 @@ for JOINed queries.
 Examples:
   dbsqlx dump "SELECT * FROM users WHERE id = 1" -d mydb
   dbsqlx dump -f query.sql -u root -h localhost -d production
-  dbsqlx dump -f query.sql -u admin -p secret -d mydb --ip 192.168.1.100`,
+  dbsqlx dump -f query.sql -u admin -p secret -d mydb --ip 192.168.1.100
+  dbsqlx dump -f query.sql -u admin --login-path=prod -d mydb
+  dbsqlx dump -f query.sql --dsn "mysql://admin@db.internal:3307/mydb?tls=true"
+  dbsqlx dump -f migration.sql -d mydb --script backup.sh --script-compress gzip
+  dbsqlx dump -f query.sql -d mydb --preset consistent --mysqldump-opt=--skip-lock-tables
+  dbsqlx dump -f query.sql -d mydb --tool dumpling --password-mode env
+  dbsqlx dump -f report.sql -d mydb --mode export --format csv
+  dbsqlx dump -f migration.sql -d mydb --out-dir ./backup
+  dbsqlx dump -f migration.sql -d mydb -u admin --execute --out-dir backups
+  dbsqlx dump -f migration.sql -d mydb -u admin --preflight --execute
+  dbsqlx dump -f migration.sql -d mydb --merge
+  dbsqlx dump -f purge.sql -d mydb -u admin --schema schema.sql --chunk-size 100000 --script purge_backup.sh
+
+UPDATE/DELETE dumps use the pre-dml-backup preset and ALTER dumps use
+schema-only unless --preset is given.
+Passwords are never printed. By default a supplied --password is written to
+a temporary 0600 --defaults-extra-file; use --password-mode to reference
+MYSQL_PWD (env), a mysql_config_editor login path (login-path), or let the
+client prompt for it (prompt).`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runDump,
 }
 
+var (
+	passwordMode   string
+	loginPath      string
+	scriptPath     string
+	scriptCompress string
+
+	preset               string
+	mysqldumpExtraOpts   []string
+	skipColumnStatistics bool
+
+	dumpToolName string
+
+	dumpMode     string
+	exportFormat string
+	outfileDir   string
+
+	executeDump bool
+	dumpOutDir  string
+	preflight   bool
+	mergeDumps  bool
+
+	pkMappings []string
+	schemaFile string
+
+	chunkSize int
+)
+
 func init() {
 	rootCmd.AddCommand(dumpCmd)
+
+	dumpCmd.Flags().StringVar(&passwordMode, "password-mode", "", "How commands authenticate: env, defaults-file, login-path or prompt")
+	dumpCmd.Flags().StringVar(&loginPath, "login-path", "", "mysql_config_editor login path to use")
+	dumpCmd.Flags().StringVar(&scriptPath, "script", "", "Write an executable bash script to this file instead of printing commands")
+	dumpCmd.Flags().StringVar(&scriptCompress, "script-compress", "none", "Compress script output files: none, gzip or zstd")
+	dumpCmd.Flags().StringVar(&preset, "preset", "", "mysqldump option preset: pre-dml-backup, schema-only, consistent or none (default: per action)")
+	dumpCmd.Flags().StringArrayVar(&mysqldumpExtraOpts, "mysqldump-opt", nil, "Extra option passed through to mysqldump (repeatable)")
+	dumpCmd.Flags().StringVar(&dumpToolName, "tool", "mysqldump", "Dump tool to generate commands for: mysqldump, mydumper, dumpling or mysqlsh")
+	dumpCmd.Flags().StringVar(&dumpMode, "mode", "dump", "dump: per-table dumps; export: export exactly the rows each SELECT returns")
+	dumpCmd.Flags().StringVar(&exportFormat, "format", "tsv", "Export format for --mode export: tsv, csv or json")
+	dumpCmd.Flags().StringVar(&outfileDir, "outfile-dir", "", "Export with SELECT ... INTO OUTFILE into this server-side directory")
+	dumpCmd.Flags().BoolVar(&executeDump, "execute", false, "Connect and write the dumps directly instead of printing commands")
+	dumpCmd.Flags().StringVar(&dumpOutDir, "out-dir", "", "Write one dump script per table and statement plus manifest.json into this directory (with --execute: the dump files, default .)")
+	dumpCmd.Flags().StringArrayVar(&pkMappings, "pk", nil, "Unique key of a table as table=col[,col...] for exact-row and REPLACE/ON DUPLICATE KEY dumps (repeatable)")
+	dumpCmd.Flags().StringVar(&schemaFile, "schema", "", "File with CREATE TABLE statements to read keys and column order from")
+	dumpCmd.Flags().BoolVar(&mergeDumps, "merge", false, "Merge dumps of the same table across statements and share commands between tables with identical filters")
+	dumpCmd.Flags().BoolVar(&preflight, "preflight", false, "Count the rows each dump would read instead of dumping (runs the counts with --execute)")
+	dumpCmd.Flags().IntVar(&chunkSize, "chunk-size", 0, "Split each table's data dump into primary-key ranges of this many rows")
+	dumpCmd.Flags().BoolVar(&skipColumnStatistics, "skip-column-statistics", false, "Add --column-statistics=0 (8.0 mysqldump against 5.7 servers)")
+}
+
+// emitf prints generated output with any credentials redacted
+func emitf(format string, a ...any) {
+	fmt.Print(redactSecrets(fmt.Sprintf(format, a...)))
+}
+
+// emitln prints a generated output line with any credentials redacted
+func emitln(s string) {
+	fmt.Println(redactSecrets(s))
 }
 
 func runDump(cmd *cobra.Command, args []string) error {
@@ -46,80 +125,263 @@ func runDump(cmd *cobra.Command, args []string) error {
 	database = strings.TrimSpace(database)
 
 	// Build connection options
-	connTarget := ""
-	if ip != "" {
-		connTarget = ip
-	} else if host != "" {
-		connTarget = host
+	conn, err := resolveConnection(cmd)
+	if err != nil {
+		return err
 	}
+	database = conn.Database
 
-	connOpts := ""
-	if connTarget != "" {
-		connOpts += fmt.Sprintf(" -h %s", connTarget)
+	catalog, err := loadKeyCatalog(schemaFile, pkMappings)
+	if err != nil {
+		return err
 	}
-	if user != "" {
-		connOpts += fmt.Sprintf(" -u %s", user)
+	plans := buildDumpPlan(stmtNodes, catalog)
+	planNotes := applyConflictFilters(plans, stmtNodes, catalog)
+	applyDDLPlans(plans, stmtNodes, catalog)
+	if preset != "" {
+		if err := validatePreset(preset); err != nil {
+			return err
+		}
+		for i := range plans {
+			plans[i].Preset = preset
+		}
 	}
-	if password != "" {
-		connOpts += fmt.Sprintf(" --password=%s", password)
+	if mergeDumps {
+		plans = mergePlans(plans)
 	}
 
-	// Process each statement
-	for _, stmtNode := range stmtNodes {
-		_, tableNames, action, whereFilter, primaryTable := Extract(&stmtNode)
+	if chunkSize < 0 {
+		return fmt.Errorf("--chunk-size must be positive")
+	}
+	if chunkSize > 0 {
+		if err := checkChunkKeys(plans, catalog); err != nil {
+			return err
+		}
+	}
 
-		if len(tableNames) == 0 {
-			fmt.Println("# No tables found in SQL statement")
-			continue
+	if executeDump {
+		return runExecute(plans, conn, statementPositions(sql, stmtNodes))
+	}
+
+	creds, err := resolveCredentials(passwordMode, conn.Password, loginPath)
+	if err != nil {
+		return err
+	}
+	if err := validateMysqldumpOpts(mysqldumpExtraOpts); err != nil {
+		return err
+	}
+
+	settings := dumpSettings{
+		ConnOpts:             creds.Opts() + conn.ClientOpts(),
+		Conn:                 conn,
+		Creds:                creds,
+		Database:             database,
+		Notes:                append(creds.Notes(scriptPath != ""), planNotes...),
+		ExtraOpts:            mysqldumpExtraOpts,
+		SkipColumnStatistics: skipColumnStatistics,
+		ChunkSize:            chunkSize,
+		Keys:                 catalog,
+	}
+	if scriptPath != "" || dumpOutDir != "" {
+		settings.Compression = scriptCompress
+	}
+	// A single script is the only user of the defaults file; --out-dir
+	// scripts share it
+	settings.RemoveDefaultsFile = scriptPath != ""
+	connOpts := settings.ConnOpts
+
+	if preflight {
+		if scriptPath != "" || dumpMode != "dump" {
+			return fmt.Errorf("--preflight cannot be combined with --script or --mode")
 		}
+		for _, note := range settings.Notes {
+			emitln(note)
+		}
+		for _, line := range preflightCommands(preflightChecks(plans), settings) {
+			emitln(line)
+		}
+		return nil
+	}
 
-		// For UPDATE/DELETE, only dump the primary table
-		tablesToDump := tableNames
-		if (action == "UPDATE" || action == "DELETE") && primaryTable != "" {
-			tablesToDump = []string{primaryTable}
+	switch dumpMode {
+	case "dump":
+	case "export":
+		if scriptPath != "" || dumpToolName != "mysqldump" || dumpOutDir != "" || chunkSize > 0 {
+			return fmt.Errorf("--mode export cannot be combined with --script, --tool, --out-dir or --chunk-size")
+		}
+		lines, err := exportCommands(stmtNodes, settings, exportSettings{Format: exportFormat, OutfileDir: outfileDir})
+		if err != nil {
+			return err
+		}
+		for _, note := range settings.Notes {
+			emitln(note)
+		}
+		for _, line := range lines {
+			emitln(line)
 		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q (valid: dump, export)", dumpMode)
+	}
 
-		// Generate mysqldump command for each table
-		for _, tableName := range tablesToDump {
-			tableSpecificFilter := FilterWhereForTable(whereFilter, tableName, tableNames)
+	tool, err := lookupDumpTool(dumpToolName, settings)
+	if err != nil {
+		return err
+	}
 
-			// For cross-table conditions, provide helper
-			if (action == "UPDATE" || action == "DELETE") && tableName == primaryTable && len(tableNames) > 1 {
-				allConditionsFilter := whereFilter
-				for _, tbl := range tableNames {
-					allConditionsFilter = strings.ReplaceAll(allConditionsFilter, tbl+".", "")
-				}
+	if dumpOutDir != "" {
+		if scriptPath != "" {
+			return fmt.Errorf("--out-dir cannot be combined with --script")
+		}
+		if err := writeOutDir(dumpOutDir, plans, settings, tool, dumpToolName, statementPositions(sql, stmtNodes)); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Dump scripts and %s written to %s\n", manifestFile, dumpOutDir)
+		return nil
+	}
+
+	if scriptPath != "" {
+		var buf bytes.Buffer
+		if err := writeDumpScript(&buf, plans, settings, tool); err != nil {
+			return err
+		}
+		if err := os.WriteFile(scriptPath, buf.Bytes(), 0755); err != nil {
+			return fmt.Errorf("error writing script: %v", err)
+		}
+		fmt.Printf("✓ Dump script written to %s\n", scriptPath)
+		return nil
+	}
+
+	db, err := openChunkDatabase(conn)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		defer db.Close()
+	} else if chunkSize > 0 {
+		// Without a server to precompute ranges on, print the script that
+		// walks the key space while it runs
+		settings.Compression = scriptCompress
+		return writeDumpScript(os.Stdout, plans, settings, tool)
+	}
+
+	for _, note := range settings.Notes {
+		emitln(note)
+	}
+
+	// Process each statement
+	for _, plan := range plans {
+		if len(plan.Dumps) == 0 && len(plan.Queries) == 0 {
+			emitln("# No tables found in SQL statement")
+			continue
+		}
+		if plan.Text != "" && len(plan.Statements) > 0 {
+			emitln("# " + plan.Text)
+		}
+		if plan.Reason != "" {
+			emitf("# Statement %d (%s): %s\n", plan.Index, plan.Action, plan.Reason)
+		}
 
-				if allConditionsFilter != tableSpecificFilter && tableSpecificFilter != "" {
-					fmt.Println("# To get exact rows matching all JOIN conditions:")
-					fmt.Println("# Step 1: Get matching IDs")
-					fmt.Printf("# mysql -N -e \"SELECT e.id FROM %s e ", tableName)
-
-					for _, tbl := range tableNames {
-						if tbl != tableName {
-							alias := string(strings.ToLower(tbl)[0])
-							if alias == string(strings.ToLower(tableName)[0]) {
-								alias = string(strings.ToLower(tbl)[0:2])
-							}
-							fmt.Printf("JOIN %s %s ON <join_condition> ", tbl, alias)
-						}
+		for _, dump := range plan.Dumps {
+			if db != nil && chunkable(plan, dump) {
+				for _, table := range dump.AllTables() {
+					lines, err := chunkedCommands(context.Background(), db, settings, tool, plan, table, dump.Filter)
+					if err != nil {
+						return err
+					}
+					for _, line := range lines {
+						emitln(line)
 					}
+				}
+				continue
+			}
+
+			output := fmt.Sprintf("%s.%s_%d", database, dump.Name(), plan.Index)
 
-					fmt.Printf("WHERE %s\" %s > /tmp/%s_ids.txt\n", whereFilter, database, tableName)
-					fmt.Println("# Step 2: Dump exact rows")
-					fmt.Printf("# mysqldump%s --where=\"id IN ($(cat /tmp/%s_ids.txt | tr '\\n' ',' | sed 's/,$//' ))\" %s %s\n", connOpts, tableName, database, tableName)
-					fmt.Println("#")
-					fmt.Println("# Or use partial filter (may include extra rows):")
+			if dump.IDQuery != "" {
+				ids := fmt.Sprintf("%s\"$(paste -sd, /tmp/%s_ids.txt))\"", shellQuote(keyTuple(dump.Key)+" IN ("), dump.Table)
+				if plan.Limit != "" {
+					emitln("# To get exactly the rows within ORDER BY/LIMIT:")
+				} else {
+					emitln("# To get exact rows matching all JOIN conditions:")
 				}
+				emitln("# Step 1: Get matching IDs")
+				emitf("# mysql%s -N --raw -e %s %s > /tmp/%s_ids.txt\n", connOpts, shellQuote(dump.idListQuery()), database, dump.Table)
+				emitln("# Step 2: Dump exact rows")
+				emitln("# " + tool.Command(settings, plan, dump.AllTables(), ids, output))
+				emitln("#")
+				emitln("# Or use partial filter (may include extra rows):")
 			}
 
-			if tableSpecificFilter != "" {
-				fmt.Printf("mysqldump%s --where=\"%s\" %s %s\n", connOpts, tableSpecificFilter, database, tableName)
-			} else {
-				fmt.Printf("mysqldump%s %s %s\n", connOpts, database, tableName)
+			where := ""
+			if dump.Filter != "" {
+				where = "\"" + dump.Filter + "\""
 			}
+			emitln(tool.Command(settings, plan, dump.AllTables(), where, output))
+		}
+		for _, q := range plan.Queries {
+			emitln(queryCommand(settings, q, queryFile(database, plan, q)))
 		}
 	}
 
 	return nil
 }
+
+// openChunkDatabase connects to look up the key ranges of printed chunked
+// dumps. It returns nil without --chunk-size or a server named by --host,
+// --ip, --socket or --dsn.
+func openChunkDatabase(conn *connOptions) (*sql.DB, error) {
+	if chunkSize == 0 || (host == "" && ip == "" && socket == "" && dsn == "") {
+		return nil, nil
+	}
+	if passwordMode == credModeLoginPath || loginPath != "" {
+		return nil, fmt.Errorf("--chunk-size cannot read login paths to find key ranges; use --password, MYSQL_PWD or --script")
+	}
+	return openDatabase(conn)
+}
+
+// runExecute dumps the planned rows itself over database/sql instead of
+// printing commands, or counts them for --preflight
+func runExecute(plans []statementPlan, conn *connOptions, positions []textPosition) error {
+	if scriptPath != "" || dumpToolName != "mysqldump" || dumpMode != "dump" || len(mysqldumpExtraOpts) > 0 || chunkSize > 0 {
+		return fmt.Errorf("--execute cannot be combined with --script, --tool, --mode, --mysqldump-opt or --chunk-size")
+	}
+	if passwordMode == credModeLoginPath || loginPath != "" {
+		return fmt.Errorf("--execute cannot read login paths; use --password or MYSQL_PWD")
+	}
+	if database == "" {
+		return fmt.Errorf("--execute requires a database")
+	}
+
+	db, err := openDatabase(conn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if preflight {
+		results, err := runPreflight(context.Background(), db, preflightChecks(plans))
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := writePreflightTable(&buf, results); err != nil {
+			return err
+		}
+		emitf("%s", buf.String())
+		return nil
+	}
+
+	outDir := dumpOutDir
+	if outDir == "" {
+		outDir = "."
+	}
+	results, err := executeDumpPlan(context.Background(), db, plans, database, outDir)
+	for _, r := range results {
+		emitf("✓ %s.%s: %d rows -> %s\n", database, r.Table, r.Rows, r.File)
+	}
+	if err != nil {
+		return err
+	}
+	return writeManifest(outDir, executeManifest(database, plans, results, positions))
+}
@@ -0,0 +1,490 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// maxInsertLen caps the size of one extended INSERT, like mysqldump's
+// net_buffer_length
+const maxInsertLen = 1 << 20
+
+// dumpResult describes one file written by the native executor
+type dumpResult struct {
+	Statement int
+	Table     string
+	File      string
+	Rows      int64
+	// Query is set for the results of plan queries
+	Query string
+}
+
+// openDatabase connects to the server described by conn through
+// database/sql. The password falls back to MYSQL_PWD like the mysql client.
+func openDatabase(conn *connOptions) (*sql.DB, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = conn.User
+	cfg.Passwd = conn.Password
+	if cfg.Passwd == "" {
+		cfg.Passwd = os.Getenv("MYSQL_PWD")
+	}
+	cfg.DBName = conn.Database
+
+	if conn.Socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = conn.Socket
+	} else {
+		h := conn.Host
+		if h == "" {
+			h = "127.0.0.1"
+		}
+		p := conn.Port
+		if p == 0 {
+			p = 3306
+		}
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(h, strconv.Itoa(p))
+	}
+
+	tlsConfig, err := buildTLSConfig(conn)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		if err := mysql.RegisterTLSConfig("dbsqlx", tlsConfig); err != nil {
+			return nil, fmt.Errorf("error registering TLS config: %v", err)
+		}
+		cfg.TLSConfig = "dbsqlx"
+	} else if conn.SSLMode == "PREFERRED" {
+		cfg.TLSConfig = "preferred"
+	}
+	if conn.Compress {
+		if err := cfg.Apply(mysql.EnableCompression(true)); err != nil {
+			return nil, fmt.Errorf("invalid connection settings: %v", err)
+		}
+	}
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings: %v", err)
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// buildTLSConfig maps --ssl-mode and the certificate flags onto a
+// tls.Config, or returns nil when TLS isn't required. DISABLED wins over
+// any certificate flags, and like the mysql client a CA without --ssl-mode
+// means VERIFY_CA.
+func buildTLSConfig(conn *connOptions) (*tls.Config, error) {
+	mode := conn.SSLMode
+	switch mode {
+	case "DISABLED":
+		return nil, nil
+	case "", "PREFERRED":
+		if conn.SSLCA == "" && conn.SSLCert == "" {
+			return nil, nil
+		}
+	}
+	if mode == "" {
+		mode = "REQUIRED"
+		if conn.SSLCA != "" {
+			mode = "VERIFY_CA"
+		}
+	}
+
+	cfg := &tls.Config{ServerName: conn.Host}
+	if conn.SSLCA != "" {
+		pem, err := os.ReadFile(conn.SSLCA)
+		if err != nil {
+			return nil, fmt.Errorf("error reading --ssl-ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", conn.SSLCA)
+		}
+		cfg.RootCAs = pool
+	}
+	if conn.SSLCert != "" || conn.SSLKey != "" {
+		cert, err := tls.LoadX509KeyPair(conn.SSLCert, conn.SSLKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case "VERIFY_IDENTITY":
+	case "VERIFY_CA":
+		// Verify the chain but not the host name
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, len(rawCerts))
+			for i, raw := range rawCerts {
+				c, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs[i] = c
+			}
+			opts := x509.VerifyOptions{Roots: cfg.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, c := range certs[1:] {
+				opts.Intermediates.AddCert(c)
+			}
+			_, err := certs[0].Verify(opts)
+			return err
+		}
+	case "PREFERRED", "REQUIRED":
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg, nil
+}
+
+// executeDumpPlan runs every planned dump inside one consistent-snapshot
+// transaction and writes mysqldump-compatible SQL files into outDir
+func executeDumpPlan(ctx context.Context, db *sql.DB, plans []statementPlan, database, outDir string) ([]dumpResult, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting: %v", err)
+	}
+	defer conn.Close()
+
+	for _, stmt := range []string{
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT",
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("error starting snapshot: %v", err)
+		}
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	var results []dumpResult
+	for _, plan := range plans {
+		for _, dump := range plan.Dumps {
+			for _, table := range dump.AllTables() {
+				single := tableDump{Table: table, Filter: dump.Filter, IDQuery: dump.IDQuery, Key: dump.Key, Superset: dump.Superset}
+				file := filepath.Join(outDir, fmt.Sprintf("%s.%s_%d.sql", database, table, plan.Index))
+				rows, err := dumpTableToFile(ctx, conn, plan, single, database, file)
+				if err != nil {
+					return results, fmt.Errorf("statement %d, table %s: %v", plan.Index, table, err)
+				}
+				results = append(results, dumpResult{Statement: plan.Index, Table: table, File: file, Rows: rows})
+			}
+		}
+		for _, q := range plan.Queries {
+			file := filepath.Join(outDir, queryFile(database, plan, q))
+			rows, err := saveQueryResult(ctx, conn, q, file)
+			if err != nil {
+				return results, fmt.Errorf("statement %d, %s: %v", plan.Index, q.Query, err)
+			}
+			results = append(results, dumpResult{Statement: plan.Index, Table: q.Table, File: file, Rows: rows, Query: q.Query})
+		}
+	}
+
+	return results, nil
+}
+
+// dumpTableToFile writes one table dump. Exact-row dumps select the primary
+// table's rows through the statement's id query as a derived table, which
+// MySQL accepts even when the query has a LIMIT.
+func dumpTableToFile(ctx context.Context, conn *sql.Conn, plan statementPlan, dump tableDump, database, file string) (int64, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	where := dump.Filter
+	if dump.IDQuery != "" {
+		where = dump.exactFilter()
+	}
+
+	fmt.Fprintf(w, "-- dbsqlx native dump\n--\n-- Database: %s\n-- Table: %s\n", database, dump.Table)
+	if where != "" {
+		fmt.Fprintf(w, "-- WHERE: %s\n", where)
+	}
+	fmt.Fprintf(w, "-- Dump date: %s\n\n", time.Now().UTC().Format(time.RFC3339))
+	w.WriteString("/*!40101 SET NAMES utf8mb4 */;\n")
+	w.WriteString("/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;\n")
+	w.WriteString("/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;\n\n")
+
+	if plan.Preset != "pre-dml-backup" {
+		var name, createStmt string
+		if err := conn.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteTableName(dump.Table)).Scan(&name, &createStmt); err != nil {
+			return 0, fmt.Errorf("error reading table definition: %v", err)
+		}
+		fmt.Fprintf(w, "DROP TABLE IF EXISTS %s;\n%s;\n\n", quoteTableName(dump.Table), createStmt)
+	}
+
+	var count int64
+	if plan.Preset != "schema-only" {
+		count, err = writeInserts(ctx, conn, w, dump.Table, where)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	w.WriteString("/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;\n")
+	w.WriteString("/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;\n")
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	return count, f.Close()
+}
+
+// insertColumns returns the columns of table a restore can write, in table
+// order: generated columns are computed by the server and can't be
+// inserted. They are read from the table definition, which marks them on
+// every server, unlike information_schema's EXTRA (which also says
+// DEFAULT_GENERATED for plain columns with expression defaults).
+func insertColumns(ctx context.Context, conn *sql.Conn, table string) ([]string, error) {
+	var name, createStmt string
+	if err := conn.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteTableName(table)).Scan(&name, &createStmt); err != nil {
+		return nil, fmt.Errorf("error reading table definition: %v", err)
+	}
+	stmtNodes, err := ParseAll(createStmt)
+	if err != nil || len(stmtNodes) != 1 {
+		return nil, fmt.Errorf("error parsing definition of %s: %v", table, err)
+	}
+	catalog := newSchemaCatalog()
+	catalog.apply(stmtNodes[0])
+
+	var columns []string
+	for _, t := range catalog.tables {
+		for _, col := range t.Columns {
+			if !col.Generated {
+				columns = append(columns, col.Name)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns found for %s", table)
+	}
+	return columns, nil
+}
+
+// writeInserts streams the filtered rows of table as extended INSERTs
+// naming their columns, as mysqldump does, so generated columns are left
+// to the server
+func writeInserts(ctx context.Context, conn *sql.Conn, w io.Writer, table, where string) (int64, error) {
+	columns, err := insertColumns(ctx, conn, table)
+	if err != nil {
+		return 0, err
+	}
+	query := "SELECT " + quoteColumns(columns) + " FROM " + quoteTableName(table)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("error selecting rows: %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.RawBytes, len(types))
+	dest := make([]any, len(types))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	fmt.Fprintf(w, "-- Dumping data for table %s\n\n", quoteTableName(table))
+
+	var count int64
+	var stmt strings.Builder
+	flush := func() error {
+		if stmt.Len() == 0 {
+			return nil
+		}
+		stmt.WriteString(";\n")
+		_, err := io.WriteString(w, stmt.String())
+		stmt.Reset()
+		return err
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+
+		var row strings.Builder
+		row.WriteByte('(')
+		for i, v := range values {
+			if i > 0 {
+				row.WriteByte(',')
+			}
+			row.WriteString(sqlLiteral(v, types[i].DatabaseTypeName()))
+		}
+		row.WriteByte(')')
+
+		if stmt.Len() > 0 && stmt.Len()+row.Len() > maxInsertLen {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+		if stmt.Len() == 0 {
+			fmt.Fprintf(&stmt, "INSERT INTO %s (%s) VALUES ", quoteTableName(table), quoteColumns(columns))
+		} else {
+			stmt.WriteByte(',')
+		}
+		stmt.WriteString(row.String())
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	if err := flush(); err != nil {
+		return count, err
+	}
+	_, err = io.WriteString(w, "\n")
+	return count, err
+}
+
+// saveQueryResult writes a plan query's result like the mysql client's
+// --batch output, or just the CREATE statement for definitions
+func saveQueryResult(ctx context.Context, conn *sql.Conn, q planQuery, file string) (int64, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	if q.Definition {
+		var name, createStmt string
+		if err := conn.QueryRowContext(ctx, q.Query).Scan(&name, &createStmt); err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(w, "%s;\n", createStmt)
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+		return 1, f.Close()
+	}
+
+	rows, err := conn.QueryContext(ctx, q.Query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	w.WriteString(strings.Join(columns, "\t") + "\n")
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		fields := make([]string, len(values))
+		for i, v := range values {
+			fields[i] = batchField(v)
+		}
+		w.WriteString(strings.Join(fields, "\t") + "\n")
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	if err := w.Flush(); err != nil {
+		return count, err
+	}
+	return count, f.Close()
+}
+
+// batchField escapes a value the way mysql --batch prints it
+func batchField(v sql.RawBytes) string {
+	if v == nil {
+		return "NULL"
+	}
+	r := strings.NewReplacer("\\", `\\`, "\t", `\t`, "\n", `\n`, "\x00", `\0`)
+	return r.Replace(string(v))
+}
+
+// sqlLiteral renders a raw column value the way mysqldump --hex-blob does:
+// numbers bare, binary data as hex and everything else as an escaped string
+func sqlLiteral(v sql.RawBytes, dbType string) string {
+	if v == nil {
+		return "NULL"
+	}
+
+	t := strings.TrimPrefix(dbType, "UNSIGNED ")
+	switch t {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return string(v)
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		if len(v) == 0 {
+			return "''"
+		}
+		return fmt.Sprintf("0x%X", []byte(v))
+	}
+
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, c := range []byte(v) {
+		switch c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteIdent backquotes an identifier
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteTableName backquotes each part of a possibly schema-qualified name
+func quoteTableName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdent(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+)
+
+// startTestServer runs an in-process MySQL-protocol server holding an empty
+// database and returns connection options for it
+func startTestServer(t *testing.T, database string) *connOptions {
+	t.Helper()
+
+	db := memory.NewDatabase(database)
+	db.BaseDatabase.EnablePrimaryKeyIndexes()
+	provider := memory.NewDBProvider(db)
+	engine := sqle.NewDefault(provider)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+
+	srv, err := server.NewServer(server.Config{Protocol: "tcp", Address: addr.String()}, engine, gmssql.NewContext, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		t.Fatalf("server.NewServer() error = %v", err)
+	}
+	go srv.Start()
+	t.Cleanup(func() { srv.Close() })
+
+	return &connOptions{Host: "127.0.0.1", Port: addr.Port, User: "root", Database: database}
+}
+
+func TestExecuteDumpPlan(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(50), avatar BLOB, score DECIMAL(5,2))",
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, status VARCHAR(20))",
+		`INSERT INTO users VALUES (1, 'ann', NULL, 1.50), (2, 'bob''s', x'00ff', NULL), (3, 'line\nbreak', NULL, 3.00)`,
+		"INSERT INTO orders VALUES (10, 1, 'open'), (11, 2, 'cancelled'), (12, 3, 'open')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("setup %q: %v", q, err)
+		}
+	}
+
+	stmtNodes, err := ParseAll(`SELECT * FROM users WHERE id >= 2;
+UPDATE orders o JOIN users u ON o.user_id = u.id SET o.status = 'closed' WHERE o.status = 'open' AND u.name = 'ann'`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	plans := buildDumpPlan(stmtNodes, testKeyCatalog(t, "orders=id"))
+
+	outDir := t.TempDir()
+	results, err := executeDumpPlan(context.Background(), db, plans, "shop", outDir)
+	if err != nil {
+		t.Fatalf("executeDumpPlan() error = %v", err)
+	}
+
+	want := []dumpResult{
+		{Statement: 1, Table: "users", File: filepath.Join(outDir, "shop.users_1.sql"), Rows: 2},
+		{Statement: 2, Table: "orders", File: filepath.Join(outDir, "shop.orders_2.sql"), Rows: 1},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("executeDumpPlan() = %+v, want %+v", results, want)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	m := executeManifest("shop", plans, results, nil)
+	if len(m.Dumps) != 2 || m.Dumps[1].File != "shop.orders_2.sql" || m.Dumps[1].Rows == nil || *m.Dumps[1].Rows != 1 || !m.Dumps[1].Exact {
+		t.Errorf("executeManifest() = %+v", m.Dumps)
+	}
+
+	users := readFile(t, results[0].File)
+	for _, s := range []string{
+		"DROP TABLE IF EXISTS `users`;",
+		"CREATE TABLE `users`",
+		`INSERT INTO ` + "`users` (`id`, `name`, `avatar`, `score`)" + ` VALUES (2,'bob\'s',0x00FF,NULL),(3,'line\nbreak',NULL,3.00);`,
+	} {
+		if !strings.Contains(users, s) {
+			t.Errorf("users dump missing %q:\n%s", s, users)
+		}
+	}
+
+	// The UPDATE uses the pre-dml-backup preset: data only, exact rows
+	orders := readFile(t, results[1].File)
+	if strings.Contains(orders, "CREATE TABLE") {
+		t.Errorf("pre-dml-backup dump should not contain CREATE TABLE:\n%s", orders)
+	}
+	if !strings.Contains(orders, "INSERT INTO `orders` (`id`, `user_id`, `status`) VALUES (10,1,'open');") {
+		t.Errorf("orders dump missing exact row:\n%s", orders)
+	}
+}
+
+func TestExecuteDumpPlanRoundTrip(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		"CREATE TABLE notes (id INT PRIMARY KEY, body TEXT)",
+		`INSERT INTO notes VALUES (1, 'quote '' and \\ backslash'), (2, 'tab\tand "double"')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("setup %q: %v", q, err)
+		}
+	}
+
+	stmtNodes, err := ParseAll("DELETE FROM notes WHERE id > 0")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	results, err := executeDumpPlan(context.Background(), db, buildDumpPlan(stmtNodes, nil), "shop", t.TempDir())
+	if err != nil {
+		t.Fatalf("executeDumpPlan() error = %v", err)
+	}
+
+	// Replay the INSERT into an emptied table and compare the values
+	var insert string
+	for _, line := range strings.Split(readFile(t, results[0].File), "\n") {
+		if strings.HasPrefix(line, "INSERT INTO") {
+			insert = strings.TrimSuffix(line, ";")
+		}
+	}
+	want := queryStrings(t, db, "SELECT body FROM notes ORDER BY id")
+	if _, err := db.Exec("DELETE FROM notes"); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatalf("replaying %q: %v", insert, err)
+	}
+	got := queryStrings(t, db, "SELECT body FROM notes ORDER BY id")
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("round trip = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteDumpPlanGeneratedColumns(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		// go-mysql-server fails to project around VIRTUAL columns, so this
+		// one is STORED; both are left out the same way
+		"CREATE TABLE items (id INT PRIMARY KEY, price INT, qty INT, total INT AS (price * qty) STORED)",
+		"INSERT INTO items (id, price, qty) VALUES (1, 10, 2), (2, 7, 3)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("setup %q: %v", q, err)
+		}
+	}
+
+	stmtNodes, err := ParseAll("DELETE FROM items WHERE id > 0")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	results, err := executeDumpPlan(context.Background(), db, buildDumpPlan(stmtNodes, nil), "shop", t.TempDir())
+	if err != nil {
+		t.Fatalf("executeDumpPlan() error = %v", err)
+	}
+
+	// Generated columns are left out, and the INSERT restores
+	var insert string
+	for _, line := range strings.Split(readFile(t, results[0].File), "\n") {
+		if strings.HasPrefix(line, "INSERT INTO") {
+			insert = strings.TrimSuffix(line, ";")
+		}
+	}
+	if want := "INSERT INTO `items` (`id`, `price`, `qty`) VALUES (1,10,2),(2,7,3)"; insert != want {
+		t.Fatalf("INSERT = %q, want %q", insert, want)
+	}
+	want := queryStrings(t, db, "SELECT CONCAT(id, ':', total) FROM items ORDER BY id")
+	if _, err := db.Exec("DELETE FROM items"); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatalf("replaying %q: %v", insert, err)
+	}
+	got := queryStrings(t, db, "SELECT CONCAT(id, ':', total) FROM items ORDER BY id")
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("round trip = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteDumpPlanBounded(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		"CREATE TABLE logs (id INT PRIMARY KEY, level VARCHAR(10))",
+		"INSERT INTO logs VALUES (1, 'info'), (2, 'debug'), (3, 'debug'), (4, 'debug'), (5, 'info')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("setup %q: %v", q, err)
+		}
+	}
+
+	stmtNodes, err := ParseAll("DELETE FROM logs WHERE level = 'debug' ORDER BY id DESC LIMIT 2")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	results, err := executeDumpPlan(context.Background(), db, buildDumpPlan(stmtNodes, testKeyCatalog(t, "logs=id")), "shop", t.TempDir())
+	if err != nil {
+		t.Fatalf("executeDumpPlan() error = %v", err)
+	}
+	if results[0].Rows != 2 {
+		t.Errorf("Rows = %d, want 2", results[0].Rows)
+	}
+	logs := readFile(t, results[0].File)
+	if !strings.Contains(logs, "(3,'debug')") || !strings.Contains(logs, "(4,'debug')") || strings.Contains(logs, "(2,'debug')") {
+		t.Errorf("logs dump should hold rows 3 and 4 only:\n%s", logs)
+	}
+}
+
+func TestExecuteDumpPlanQueries(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(50), age INT)",
+		"INSERT INTO users VALUES (1, 'ann', 30), (2, 'tab\there', NULL)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("setup %q: %v", q, err)
+		}
+	}
+
+	stmtNodes, err := ParseAll("ALTER TABLE users DROP COLUMN name; ALTER TABLE users MODIFY COLUMN age BIGINT")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	plans := buildDumpPlan(stmtNodes, nil)
+	applyDDLPlans(plans, stmtNodes, testKeyCatalog(t, "users=id"))
+
+	outDir := t.TempDir()
+	results, err := executeDumpPlan(context.Background(), db, plans, "shop", outDir)
+	if err != nil {
+		t.Fatalf("executeDumpPlan() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("executeDumpPlan() = %+v, want schema dump, column export and definition", results)
+	}
+
+	if got, want := readFile(t, filepath.Join(outDir, "shop.users_col_name_1.tsv")), "id\tname\n1\tann\n2\ttab\\there\n"; got != want {
+		t.Errorf("column export = %q, want %q", got, want)
+	}
+	if got := readFile(t, filepath.Join(outDir, "shop.users_create_2.sql")); !strings.HasPrefix(got, "CREATE TABLE `users`") {
+		t.Errorf("definition = %q, want SHOW CREATE TABLE output", got)
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	tests := []struct {
+		value  sql.RawBytes
+		dbType string
+		want   string
+	}{
+		{nil, "VARCHAR", "NULL"},
+		{sql.RawBytes("42"), "INT", "42"},
+		{sql.RawBytes("18446744073709551615"), "UNSIGNED BIGINT", "18446744073709551615"},
+		{sql.RawBytes("1.50"), "DECIMAL", "1.50"},
+		{sql.RawBytes{0, 0xff}, "BLOB", "0x00FF"},
+		{sql.RawBytes{}, "VARBINARY", "''"},
+		{sql.RawBytes("it's\n\\"), "VARCHAR", `'it\'s\n\\'`},
+		{sql.RawBytes("2024-01-01 00:00:00"), "DATETIME", "'2024-01-01 00:00:00'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType+"_"+strconv.Quote(string(tt.value)), func(t *testing.T) {
+			if got := sqlLiteral(tt.value, tt.dbType); got != tt.want {
+				t.Errorf("sqlLiteral() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	tests := []struct {
+		name       string
+		conn       connOptions
+		wantNil    bool
+		wantErr    bool
+		skipVerify bool
+	}{
+		{name: "No flags", conn: connOptions{}, wantNil: true},
+		{name: "DISABLED ignores certificates", conn: connOptions{SSLMode: "DISABLED", SSLCA: missing, SSLCert: missing}, wantNil: true},
+		{name: "PREFERRED without certificates", conn: connOptions{SSLMode: "PREFERRED"}, wantNil: true},
+		{name: "REQUIRED skips verification", conn: connOptions{SSLMode: "REQUIRED"}, skipVerify: true},
+		{name: "VERIFY_IDENTITY verifies", conn: connOptions{SSLMode: "VERIFY_IDENTITY"}},
+		{name: "CA without a mode is read", conn: connOptions{SSLCA: missing}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTLSConfig(&tt.conn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("buildTLSConfig() = %+v, want nil %v", got, tt.wantNil)
+			}
+			if got != nil && got.InsecureSkipVerify != tt.skipVerify {
+				t.Errorf("InsecureSkipVerify = %v, want %v", got.InsecureSkipVerify, tt.skipVerify)
+			}
+		})
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func queryStrings(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		values = append(values, v)
+	}
+	return values
+}
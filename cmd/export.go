@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// Export formats for --mode export
+const (
+	exportTSV  = "tsv"
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// csvAwk turns mysql --batch output into RFC 4180 quoted CSV. Batch output
+// is tab separated with \t, \n, \\ and \0 escaped, which are turned back
+// into the raw characters, and prints SQL NULL as NULL, which becomes an
+// empty unquoted field.
+const csvAwk = `awk 'function unescape(s,  out, c, i) { out = ""; for (i = 1; i <= length(s); i++) { c = substr(s, i, 1); if (c == "\\" && i < length(s)) { c = substr(s, ++i, 1); if (c == "n") c = "\n"; else if (c == "t") c = "\t"; else if (c == "0") c = sprintf("%c", 0) } out = out c } return out } ` +
+	`BEGIN { FS = "\t"; OFS = "," } { for (i = 1; i <= NF; i++) { if (NR > 1 && $i == "NULL") { $i = "" } else { $i = unescape($i); gsub(/"/, "\"\"", $i); $i = "\"" $i "\"" } } print }'`
+
+// exportSettings controls how SELECT results are exported
+type exportSettings struct {
+	Format string
+	// OutfileDir switches to server-side SELECT ... INTO OUTFILE, writing
+	// into this directory on the database host
+	OutfileDir string
+}
+
+// exportCommands renders one command per SELECT statement that exports
+// exactly the rows the query returns. Other statements yield a comment.
+func exportCommands(stmtNodes []ast.StmtNode, settings dumpSettings, export exportSettings) ([]string, error) {
+	switch export.Format {
+	case exportTSV, exportCSV, exportJSON:
+	default:
+		return nil, fmt.Errorf("unknown export format %q (valid: tsv, csv, json)", export.Format)
+	}
+
+	var lines []string
+	for idx, stmtNode := range stmtNodes {
+		file := fmt.Sprintf("%s_export_%d.%s", settings.Database, idx+1, export.Format)
+
+		switch stmtNode.(type) {
+		case *ast.SelectStmt, *ast.SetOprStmt:
+		default:
+			_, _, action, _, _ := Extract(&stmtNode)
+			if action == "" {
+				action = "this"
+			}
+			lines = append(lines, fmt.Sprintf("# Statement %d: export mode only handles SELECT, skipping %s statement", idx+1, action))
+			continue
+		}
+
+		query := strings.TrimSuffix(strings.TrimSpace(stmtNode.Text()), ";")
+		if export.Format == exportJSON {
+			var err error
+			query, err = jsonObjectSelect(query)
+			if err != nil {
+				return nil, fmt.Errorf("statement %d: %v", idx+1, err)
+			}
+		}
+
+		if export.OutfileDir != "" {
+			outfile := path.Join(export.OutfileDir, file)
+			lines = append(lines, fmt.Sprintf("mysql%s -e %s %s", settings.ConnOpts, shellQuote(query+intoOutfileClause(outfile, export.Format)), settings.Database))
+			continue
+		}
+
+		switch export.Format {
+		case exportTSV:
+			lines = append(lines, fmt.Sprintf("mysql%s --batch -e %s %s > %s", settings.ConnOpts, shellQuote(query), settings.Database, file))
+		case exportCSV:
+			lines = append(lines, fmt.Sprintf("mysql%s --batch -e %s %s | %s > %s", settings.ConnOpts, shellQuote(query), settings.Database, csvAwk, file))
+		case exportJSON:
+			lines = append(lines, fmt.Sprintf("mysql%s --batch --raw -N -e %s %s > %s", settings.ConnOpts, shellQuote(query), settings.Database, file))
+		}
+	}
+
+	return lines, nil
+}
+
+// intoOutfileClause returns the INTO OUTFILE clause for a server-side export
+func intoOutfileClause(file, format string) string {
+	clause := fmt.Sprintf(" INTO OUTFILE '%s'", strings.ReplaceAll(file, "'", "''"))
+	switch format {
+	case exportCSV:
+		clause += ` FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' LINES TERMINATED BY '\n'`
+	case exportJSON:
+		clause += ` FIELDS ESCAPED BY '' LINES TERMINATED BY '\n'`
+	}
+	return clause
+}
+
+// jsonObjectSelect rewrites the field list of a SELECT into a single
+// JSON_OBJECT(...) so each result row becomes one JSON document. Keys are
+// the field aliases, column names or expression text. References to field
+// aliases in GROUP BY, HAVING and ORDER BY are replaced by the aliased
+// expressions since the aliases no longer exist.
+func jsonObjectSelect(query string) (string, error) {
+	stmtNodes, err := ParseAll(query)
+	if err != nil {
+		return "", err
+	}
+	sel, ok := stmtNodes[0].(*ast.SelectStmt)
+	if !ok || len(stmtNodes) != 1 {
+		return "", fmt.Errorf("json export does not support UNION queries")
+	}
+	if sel.Fields == nil {
+		return "", fmt.Errorf("json export needs a field list")
+	}
+
+	var args []ast.ExprNode
+	aliases := make(map[string]ast.ExprNode)
+	for _, field := range sel.Fields.Fields {
+		if field.WildCard != nil {
+			return "", fmt.Errorf("json export needs explicit columns instead of *")
+		}
+		key := field.AsName.O
+		if key != "" {
+			aliases[field.AsName.L] = field.Expr
+		} else if col, ok := field.Expr.(*ast.ColumnNameExpr); ok {
+			key = col.Name.Name.O
+		} else {
+			key = describeSQL(field.Expr)
+		}
+		args = append(args, ast.NewValueExpr(key, "", ""), field.Expr)
+	}
+
+	inliner := &aliasInliner{aliases: aliases}
+	if sel.GroupBy != nil {
+		sel.GroupBy.Accept(inliner)
+	}
+	if sel.Having != nil {
+		sel.Having.Accept(inliner)
+	}
+	if sel.OrderBy != nil {
+		sel.OrderBy.Accept(inliner)
+	}
+
+	sel.Fields.Fields = []*ast.SelectField{{
+		Expr: &ast.FuncCallExpr{FnName: ast.NewCIStr("JSON_OBJECT"), Args: args},
+	}}
+
+	rewritten := restoreSQL(sel)
+	if rewritten == "" {
+		return "", fmt.Errorf("could not rewrite query for json export")
+	}
+	return rewritten, nil
+}
+
+// aliasInliner replaces unqualified references to select field aliases with
+// the aliased expressions
+type aliasInliner struct {
+	aliases map[string]ast.ExprNode
+}
+
+func (v *aliasInliner) Enter(in ast.Node) (ast.Node, bool) {
+	return in, false
+}
+
+func (v *aliasInliner) Leave(in ast.Node) (ast.Node, bool) {
+	if col, ok := in.(*ast.ColumnNameExpr); ok && col.Name.Table.L == "" {
+		if expr, found := v.aliases[col.Name.Name.L]; found {
+			return expr, true
+		}
+	}
+	return in, true
+}
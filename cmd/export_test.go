@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportCommands(t *testing.T) {
+	settings := dumpSettings{ConnOpts: " -u root", Database: "shop"}
+	sql := `SELECT name FROM users WHERE id < 10 ORDER BY name LIMIT 5;
+DELETE FROM users WHERE id = 1`
+
+	tests := []struct {
+		name   string
+		export exportSettings
+		want   []string
+	}{
+		{
+			name:   "TSV through the mysql client",
+			export: exportSettings{Format: exportTSV},
+			want: []string{
+				"mysql -u root --batch -e 'SELECT name FROM users WHERE id < 10 ORDER BY name LIMIT 5' shop > shop_export_1.tsv",
+				"# Statement 2: export mode only handles SELECT, skipping DELETE statement",
+			},
+		},
+		{
+			name:   "CSV through the mysql client",
+			export: exportSettings{Format: exportCSV},
+			want: []string{
+				"mysql -u root --batch -e 'SELECT name FROM users WHERE id < 10 ORDER BY name LIMIT 5' shop | " + csvAwk + " > shop_export_1.csv",
+				"# Statement 2: export mode only handles SELECT, skipping DELETE statement",
+			},
+		},
+		{
+			name:   "CSV into a server-side outfile",
+			export: exportSettings{Format: exportCSV, OutfileDir: "/var/lib/mysql-files"},
+			want: []string{
+				`mysql -u root -e 'SELECT name FROM users WHERE id < 10 ORDER BY name LIMIT 5 INTO OUTFILE '\''/var/lib/mysql-files/shop_export_1.csv'\'' FIELDS TERMINATED BY '\'','\'' OPTIONALLY ENCLOSED BY '\''"'\'' LINES TERMINATED BY '\''\n'\''' shop`,
+				"# Statement 2: export mode only handles SELECT, skipping DELETE statement",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtNodes, err := ParseAll(sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			got, err := exportCommands(stmtNodes, settings, tt.export)
+			if err != nil {
+				t.Fatalf("exportCommands() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exportCommands() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONObjectSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Columns become keys",
+			sql:  "SELECT id, u.name FROM users u WHERE id = 1",
+			want: "SELECT JSON_OBJECT('id', `id`, 'name', `u`.`name`) FROM `users` AS `u` WHERE `id`=1",
+		},
+		{
+			name: "Aliases are inlined in GROUP BY, HAVING and ORDER BY",
+			sql:  "SELECT status, COUNT(*) AS n FROM orders GROUP BY status HAVING n > 1 ORDER BY n DESC",
+			want: "SELECT JSON_OBJECT('status', `status`, 'n', COUNT(1)) FROM `orders` GROUP BY `status` HAVING COUNT(1)>1 ORDER BY COUNT(1) DESC",
+		},
+		{
+			name:    "Wildcard is rejected",
+			sql:     "SELECT * FROM users",
+			wantErr: true,
+		},
+		{
+			name:    "UNION is rejected",
+			sql:     "SELECT a FROM t UNION SELECT b FROM u",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jsonObjectSelect(tt.sql)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jsonObjectSelect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("jsonObjectSelect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVAwk(t *testing.T) {
+	if _, err := exec.LookPath("awk"); err != nil {
+		t.Skip("awk not available")
+	}
+	// mysql --batch output: a header, then a note holding a tab, a newline
+	// and a backslash, a SQL NULL and a quote
+	batch := "id\tname\tnote\n" +
+		"1\tann\tcol\\tA\\nline 2 \\\\ end\n" +
+		"2\tNULL\tsay \"hi\"\n"
+	cmd := exec.Command("sh", "-c", csvAwk)
+	cmd.Stdin = strings.NewReader(batch)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("awk failed: %v", err)
+	}
+	want := "\"id\",\"name\",\"note\"\n" +
+		"\"1\",\"ann\",\"col\tA\nline 2 \\ end\"\n" +
+		"\"2\",,\"say \"\"hi\"\"\"\n"
+	if string(out) != want {
+		t.Errorf("csvAwk output = %q, want %q", out, want)
+	}
+}
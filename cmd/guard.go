@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+	"github.com/spf13/cobra"
+)
+
+var guardCmd = &cobra.Command{
+	Use:   "guard [sql-statement]",
+	Short: "Block dangerous statements unless approved",
+	Long: `Fail when the SQL contains dangerous statements, for deployment pipelines.
+
+Blocked statements and their exit codes:
+  drop-database   10  DROP DATABASE
+  drop-table      11  DROP TABLE
+  truncate        12  TRUNCATE TABLE
+  unbounded-dml   13  UPDATE or DELETE without WHERE or LIMIT, or with a
+                      WHERE that matches every row
+  grant-all       14  GRANT ALL
+  protected-ddl   15  DDL on a protected table (--protect or .dbsqlx.yaml)
+
+When several statements are blocked the lowest code wins; syntax errors
+exit with 2.
+
+A statement is allowed when it is approved, either by a comment ahead of
+it, optionally limited to some categories:
+
+  -- dbsqlx:approve truncate reason="reset staging data"
+  TRUNCATE TABLE sessions;
+
+or by passing the token printed for it with --approve (or
+DBSQLX_APPROVE, comma-separated). Tokens are derived from the statement,
+so they stop matching when it changes.
+
+Examples:
+  dbsqlx guard -f migration.sql
+  dbsqlx guard -f migration.sql --protect users --protect 'billing_*'
+  dbsqlx guard -f migration.sql --approve 3f2a9c1b7d0e`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runGuard,
+}
+
+var (
+	guardProtected  []string
+	guardApprovals  []string
+	guardConfigFile string
+)
+
+func init() {
+	rootCmd.AddCommand(guardCmd)
+
+	guardCmd.Flags().StringArrayVar(&guardProtected, "protect", nil, "Protected table name or glob (repeatable)")
+	guardCmd.Flags().StringArrayVar(&guardApprovals, "approve", nil, "Approval token of a blocked statement (repeatable)")
+	guardCmd.Flags().StringVar(&guardConfigFile, "config", "", "Config file (default .dbsqlx.yaml when present)")
+}
+
+// approveDirective starts an inline approval comment
+const approveDirective = "dbsqlx:approve"
+
+const exitSyntaxError = 2
+
+// guardCategories lists the kinds of blocked statements in exit code order
+var guardCategories = []struct {
+	ID   string
+	Code int
+}{
+	{"drop-database", 10},
+	{"drop-table", 11},
+	{"truncate", 12},
+	{"unbounded-dml", 13},
+	{"grant-all", 14},
+	{"protected-ddl", 15},
+}
+
+// guardExitCode returns the exit code of a category
+func guardExitCode(category string) int {
+	for _, c := range guardCategories {
+		if c.ID == category {
+			return c.Code
+		}
+	}
+	return 1
+}
+
+// guardHit is a reason to block a statement
+type guardHit struct {
+	Category string
+	Reason   string
+}
+
+// guardResult is the verdict on one dangerous statement
+type guardResult struct {
+	File      string
+	Statement int
+	Line      int
+	// Token approves the statement with --approve
+	Token string
+	Hits  []guardHit
+	// ApprovedBy is "comment" or "token" when the statement is allowed
+	ApprovedBy string
+	Reason     string
+}
+
+// guardStatement lists why a statement is dangerous. Tables come from
+// Extract; protected matches table names case-insensitively, with globs.
+func guardStatement(stmtNode ast.StmtNode, protected []string) []guardHit {
+	_, tableNames, _, _, primaryTable := Extract(&stmtNode)
+	var hits []guardHit
+
+	switch stmt := stmtNode.(type) {
+	case *ast.DropDatabaseStmt:
+		hits = append(hits, guardHit{"drop-database", fmt.Sprintf("DROP DATABASE removes %s and every table in it", stmt.Name.O)})
+	case *ast.DropTableStmt:
+		if !stmt.IsView {
+			hits = append(hits, guardHit{"drop-table", fmt.Sprintf("DROP TABLE removes %s", strings.Join(tableNames, ", "))})
+		}
+	case *ast.TruncateTableStmt:
+		hits = append(hits, guardHit{"truncate", fmt.Sprintf("TRUNCATE removes every row of %s", strings.Join(tableNames, ", "))})
+	case *ast.UpdateStmt:
+		if reason := unbounded(stmt.Where, stmt.Limit); reason != "" {
+			hits = append(hits, guardHit{"unbounded-dml", fmt.Sprintf("UPDATE %s changes every row of %s", reason, primaryTable)})
+		}
+	case *ast.DeleteStmt:
+		if reason := unbounded(stmt.Where, stmt.Limit); reason != "" {
+			hits = append(hits, guardHit{"unbounded-dml", fmt.Sprintf("DELETE %s removes every row of %s", reason, primaryTable)})
+		}
+	case *ast.GrantStmt:
+		for _, priv := range stmt.Privs {
+			if priv.Priv == mysql.AllPriv {
+				users := make([]string, len(stmt.Users))
+				for i, u := range stmt.Users {
+					users[i] = u.User.String()
+				}
+				hits = append(hits, guardHit{"grant-all", fmt.Sprintf("GRANT ALL on %s to %s", grantTarget(stmt.Level), strings.Join(users, ", "))})
+				break
+			}
+		}
+	}
+
+	var guarded []string
+	for _, table := range ddlTables(stmtNode, tableNames) {
+		if matchAny(protected, strings.ToLower(table)) {
+			guarded = appendUnique(guarded, table)
+		}
+	}
+	if len(guarded) > 0 {
+		what := "protected table "
+		if len(guarded) > 1 {
+			what = "protected tables "
+		}
+		hits = append(hits, guardHit{"protected-ddl", "DDL on " + what + strings.Join(guarded, ", ")})
+	}
+	return hits
+}
+
+// unbounded says why an UPDATE or DELETE reaches every row, or returns ""
+// when its WHERE or LIMIT bounds it
+func unbounded(where ast.ExprNode, limit *ast.Limit) string {
+	switch {
+	case limit != nil:
+		return ""
+	case where == nil:
+		return "without WHERE"
+	case len(columnNames(where)) == 0:
+		return fmt.Sprintf("with WHERE %s", describeSQL(where))
+	}
+	return ""
+}
+
+// grantTarget renders the level of a GRANT, e.g. *.* or shop.users
+func grantTarget(level *ast.GrantLevel) string {
+	if level == nil {
+		return "*.*"
+	}
+	db := level.DBName
+	if db == "" {
+		db = "*"
+	}
+	switch level.Level {
+	case ast.GrantLevelGlobal:
+		return "*.*"
+	case ast.GrantLevelDB:
+		return db + ".*"
+	}
+	if level.DBName == "" {
+		return level.TableName
+	}
+	return level.DBName + "." + level.TableName
+}
+
+// ddlTables lists the tables a DDL statement changes, nil for other
+// statements. Extract knows the tables of ALTER, DROP and TRUNCATE.
+func ddlTables(stmtNode ast.StmtNode, tableNames []string) []string {
+	switch stmt := stmtNode.(type) {
+	case *ast.AlterTableStmt, *ast.DropTableStmt, *ast.TruncateTableStmt:
+		return tableNames
+	case *ast.RenameTableStmt:
+		var tables []string
+		for _, t := range stmt.TableToTables {
+			tables = append(tables, t.OldTable.Name.O, t.NewTable.Name.O)
+		}
+		return tables
+	case *ast.CreateIndexStmt:
+		return []string{stmt.Table.Name.O}
+	case *ast.DropIndexStmt:
+		return []string{stmt.Table.Name.O}
+	}
+	return nil
+}
+
+// approvalToken identifies a statement by its normalised text, so
+// reformatting keeps the token while any change to the SQL voids it
+func approvalToken(stmtNode ast.StmtNode) string {
+	sum := sha256.Sum256([]byte(describeSQL(stmtNode)))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// guardSQL evaluates every statement of sql and returns the dangerous ones
+// with their approvals, plus the syntax errors
+func guardSQL(sql, file string, protected, tokens []string) ([]guardResult, int, []*SyntaxError) {
+	stmts, errs := parseStatements(sql, file)
+	var results []guardResult
+
+	for _, stmt := range stmts {
+		for _, node := range stmt.Nodes {
+			hits := guardStatement(node, protected)
+			if len(hits) == 0 {
+				continue
+			}
+			r := guardResult{File: file, Statement: stmt.Index, Token: approvalToken(node), Hits: hits}
+			r.Line, _ = lineColumn(sql, stmt.Offset+leadingComments(stmt.Text))
+
+			if slices.Contains(tokens, r.Token) {
+				r.ApprovedBy = "token"
+			}
+			for _, d := range statementDirectives(stmt.sqlStatement, approveDirective) {
+				categories, reason := parseIgnoreArgs(d.Args)
+				if r.ApprovedBy == "" && coversHits(categories, hits) {
+					r.ApprovedBy, r.Reason = "comment", reason
+				}
+			}
+			results = append(results, r)
+		}
+	}
+	return results, len(stmts) + len(errs), errs
+}
+
+// coversHits reports whether an approval for categories (all when empty)
+// covers every hit
+func coversHits(categories []string, hits []guardHit) bool {
+	if len(categories) == 0 {
+		return true
+	}
+	for _, h := range hits {
+		if !slices.Contains(categories, h.Category) {
+			return false
+		}
+	}
+	return true
+}
+
+func runGuard(cmd *cobra.Command, args []string) error {
+	sql, err := getSQLInput(args)
+	if err != nil {
+		return err
+	}
+
+	configFile := guardConfigFile
+	if configFile == "" {
+		configFile = defaultConfigFile
+	}
+	cfg, err := loadConfig(configFile, guardConfigFile != "")
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	protected := append(slices.Clone(cfg.Guard.Protected), guardProtected...)
+	for i := range protected {
+		protected[i] = strings.ToLower(protected[i])
+	}
+	tokens := guardApprovals
+	if env := os.Getenv("DBSQLX_APPROVE"); env != "" {
+		tokens = append(slices.Clone(tokens), strings.Split(env, ",")...)
+	}
+
+	results, total, errs := guardSQL(sql, fileInput, protected, tokens)
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Println(formatSyntaxError(e))
+		}
+		return &exitError{code: exitSyntaxError, msg: checkSummary(checkReport{Statements: total, Failed: len(errs)}) + "; guard can't vet SQL that doesn't parse"}
+	}
+
+	writeGuardResults(os.Stdout, results)
+	return guardVerdict(results, total)
+}
+
+// writeGuardResults prints one line per dangerous statement
+func writeGuardResults(w io.Writer, results []guardResult) {
+	for _, r := range results {
+		loc := fmt.Sprintf("statement %d", r.Statement)
+		if r.File != "" {
+			loc = fmt.Sprintf("%s:%d: statement %d", r.File, r.Line, r.Statement)
+		}
+		categories := make([]string, len(r.Hits))
+		reasons := make([]string, len(r.Hits))
+		for i, h := range r.Hits {
+			categories[i], reasons[i] = h.Category, h.Reason
+		}
+
+		switch r.ApprovedBy {
+		case "":
+			fmt.Fprintf(w, "BLOCKED %s [%s]: %s (approve with --approve %s)\n", loc, strings.Join(categories, ", "), strings.Join(reasons, "; "), r.Token)
+		case "comment":
+			reason := "no reason"
+			if r.Reason != "" {
+				reason = fmt.Sprintf("reason %q", r.Reason)
+			}
+			fmt.Fprintf(w, "approved %s [%s]: %s (by comment, %s)\n", loc, strings.Join(categories, ", "), strings.Join(reasons, "; "), reason)
+		default:
+			fmt.Fprintf(w, "approved %s [%s]: %s (by token %s)\n", loc, strings.Join(categories, ", "), strings.Join(reasons, "; "), r.Token)
+		}
+	}
+}
+
+// guardVerdict fails with the lowest exit code among the blocked
+// statements' categories, or reports success
+func guardVerdict(results []guardResult, total int) error {
+	blocked, code := 0, 0
+	for _, r := range results {
+		if r.ApprovedBy != "" {
+			continue
+		}
+		blocked++
+		for _, h := range r.Hits {
+			if c := guardExitCode(h.Category); code == 0 || c < code {
+				code = c
+			}
+		}
+	}
+	if blocked > 0 {
+		return &exitError{code: code, msg: fmt.Sprintf("%d of %s blocked", blocked, counted(total, "statement", "statements"))}
+	}
+	if len(results) > 0 {
+		emitf("✓ %s approved\n", counted(len(results), "dangerous statement", "dangerous statements"))
+	} else {
+		emitf("✓ No dangerous statements in %s\n", counted(total, "statement", "statements"))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGuardStatement(t *testing.T) {
+	protected := []string{"users", "billing_*"}
+
+	tests := []struct {
+		sql  string
+		want []guardHit
+	}{
+		{"DROP DATABASE shop", []guardHit{{"drop-database", "DROP DATABASE removes shop and every table in it"}}},
+		{"DROP TABLE a, b", []guardHit{{"drop-table", "DROP TABLE removes a, b"}}},
+		{"DROP VIEW v", nil},
+		{"TRUNCATE TABLE cache", []guardHit{{"truncate", "TRUNCATE removes every row of cache"}}},
+		{"DELETE FROM sessions", []guardHit{{"unbounded-dml", "DELETE without WHERE removes every row of sessions"}}},
+		{"UPDATE t SET a = 1 WHERE 1 = 1", []guardHit{{"unbounded-dml", "UPDATE with WHERE 1=1 changes every row of t"}}},
+		{"UPDATE t SET a = (SELECT MAX(b) FROM u)", []guardHit{{"unbounded-dml", "UPDATE without WHERE changes every row of t"}}},
+		{"DELETE FROM logs WHERE created < '2024-01-01'", nil},
+		{"DELETE FROM logs LIMIT 1000", nil},
+		{"GRANT ALL PRIVILEGES ON *.* TO 'app'@'%'", []guardHit{{"grant-all", "GRANT ALL on *.* to app@%"}}},
+		{"GRANT ALL ON shop.orders TO 'app'@'localhost'", []guardHit{{"grant-all", "GRANT ALL on shop.orders to app@localhost"}}},
+		{"GRANT SELECT ON shop.* TO 'app'@'%'", nil},
+		{"ALTER TABLE Users ADD COLUMN x INT", []guardHit{{"protected-ddl", "DDL on protected table Users"}}},
+		{"ALTER TABLE orders ADD COLUMN x INT", nil},
+		{"DROP TABLE users", []guardHit{{"drop-table", "DROP TABLE removes users"}, {"protected-ddl", "DDL on protected table users"}}},
+		{"RENAME TABLE billing_old TO billing_archive", []guardHit{{"protected-ddl", "DDL on protected tables billing_old, billing_archive"}}},
+		{"CREATE INDEX idx_email ON users (email)", []guardHit{{"protected-ddl", "DDL on protected table users"}}},
+		{"DROP INDEX idx_email ON users", []guardHit{{"protected-ddl", "DDL on protected table users"}}},
+		{"UPDATE users SET a = 1 WHERE id = 1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			if got := guardStatement(stmtNodes[0], protected); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("guardStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardSQLApprovals(t *testing.T) {
+	sql := "DELETE FROM sessions;\n" +
+		"-- dbsqlx:approve truncate reason=\"reset staging data\"\n" +
+		"TRUNCATE TABLE cache;\n" +
+		"-- dbsqlx:approve truncate\n" +
+		"DROP TABLE users;\n" +
+		"DROP TABLE tmp;\n" +
+		"SELECT 1"
+
+	stmtNodes, err := ParseAll("drop   table\n tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tokens survive reformatting
+	token := approvalToken(stmtNodes[0])
+
+	results, total, errs := guardSQL(sql, "m.sql", []string{"users"}, []string{token})
+	if total != 5 || len(errs) != 0 {
+		t.Fatalf("guardSQL() = %d statements, %v", total, errs)
+	}
+
+	type verdict struct {
+		statement, line int
+		approvedBy      string
+		reason          string
+	}
+	var got []verdict
+	for _, r := range results {
+		got = append(got, verdict{r.Statement, r.Line, r.ApprovedBy, r.Reason})
+	}
+	want := []verdict{
+		{1, 1, "", ""},
+		{2, 3, "comment", "reset staging data"},
+		// Approving truncate doesn't cover drop-table and protected-ddl
+		{3, 5, "", ""},
+		{4, 6, "token", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("guardSQL() = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	writeGuardResults(&buf, results)
+	for _, line := range []string{
+		"BLOCKED m.sql:1: statement 1 [unbounded-dml]: DELETE without WHERE removes every row of sessions (approve with --approve " + results[0].Token + ")",
+		`approved m.sql:3: statement 2 [truncate]: TRUNCATE removes every row of cache (by comment, reason "reset staging data")`,
+		"BLOCKED m.sql:5: statement 3 [drop-table, protected-ddl]: DROP TABLE removes users; DDL on protected table users",
+		"approved m.sql:6: statement 4 [drop-table]: DROP TABLE removes tmp (by token " + token + ")",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("output missing %q\nGot:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestGuardVerdict(t *testing.T) {
+	blocked := func(categories ...string) guardResult {
+		r := guardResult{}
+		for _, c := range categories {
+			r.Hits = append(r.Hits, guardHit{Category: c})
+		}
+		return r
+	}
+	approved := blocked("drop-database")
+	approved.ApprovedBy = "comment"
+
+	tests := []struct {
+		name     string
+		results  []guardResult
+		wantCode int
+	}{
+		{"Nothing dangerous", nil, 0},
+		{"All approved", []guardResult{approved}, 0},
+		{"One category", []guardResult{approved, blocked("grant-all")}, 14},
+		{"Lowest code wins", []guardResult{blocked("protected-ddl"), blocked("truncate", "protected-ddl"), blocked("unbounded-dml")}, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guardVerdict(tt.results, 5)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("guardVerdict() error = %v", err)
+				}
+				return
+			}
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) || exitErr.code != tt.wantCode {
+				t.Errorf("guardVerdict() = %v, want exit code %d", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGuardConfig(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, "guard:\n  protected: [users, \"billing_*\"]\n"), true)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if want := []string{"users", "billing_*"}; !reflect.DeepEqual(cfg.Guard.Protected, want) {
+		t.Errorf("Guard.Protected = %v, want %v", cfg.Guard.Protected, want)
+	}
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [sql-statement]",
+	Short: "Lint SQL for risky or slow patterns",
+	Long: `Check each statement against a set of lint rules.
+
+Built-in rules:
+  missing-where          error    UPDATE or DELETE without WHERE
+  null-comparison        error    = NULL or <> NULL, which are never true
+  cartesian-join         warning  joins with no condition relating the tables
+  leading-wildcard-like  warning  LIKE patterns starting with % or _
+  function-on-column     warning  functions around indexed-looking columns in conditions
+  order-by-rand          warning  ORDER BY RAND()
+  large-in-list          warning  IN lists with more than 1000 values
+  large-offset           warning  LIMIT offsets above 10000
+  implicit-conversion    warning  string columns compared to numbers, date columns
+                                  to strings that aren't dates (needs --schema)
+  collation-mismatch     warning  string columns compared across collations
+                                  (needs --schema)
+  join-type-mismatch     warning  columns compared across signedness or string and
+                                  number types (needs --schema)
+  select-star            info     SELECT * outside EXISTS
+
+Findings carry the rule, severity, statement, line and column. Error
+findings and syntax errors make the command fail.
+
+Rules are tuned in the lint section of .dbsqlx.yaml (or --config):
+
+  lint:
+    rules:
+      select-star:
+        enabled: false
+      large-offset:
+        severity: error
+        files: ["reports/**/*.sql"]
+        exclude: ["reports/adhoc/*"]
+
+A comment ahead of a statement suppresses findings of that statement:
+
+  -- dbsqlx:ignore missing-where reason="full purge approved"
+  DELETE FROM sessions;
+
+Suppressions are listed after the findings for auditing.
+
+--schema takes the same schema files as check --schema and gives the
+typed rules the column types and collations of the tables.
+
+Examples:
+  dbsqlx lint "DELETE FROM logs"
+  dbsqlx lint -f migration.sql
+  dbsqlx lint -f migration.sql --format github
+  dbsqlx lint -f migration.sql --config ci/dbsqlx.yaml
+  dbsqlx lint -f query.sql --schema ./schema`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLint,
+}
+
+var (
+	lintFormat     string
+	lintConfigFile string
+	lintSchemas    []string
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "Output format: text, json or github")
+	lintCmd.Flags().StringVar(&lintConfigFile, "config", "", "Config file (default .dbsqlx.yaml when present)")
+	lintCmd.Flags().StringArrayVar(&lintSchemas, "schema", nil, "Schema file, directory or quoted glob whose column types the typed rules use (repeatable)")
+}
+
+// Finding is a rule violation located in the source
+type Finding struct {
+	Rule      string   `json:"rule"`
+	Severity  Severity `json:"severity"`
+	File      string   `json:"file,omitempty"`
+	Statement int      `json:"statement"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	Offset    int      `json:"offset"`
+	Message   string   `json:"message"`
+	// Excerpt is the source line with a caret under the offending node
+	Excerpt string `json:"excerpt"`
+}
+
+// String renders the finding the way compilers report diagnostics
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s: %s: %s [%s]", sourceLocation(f.File, f.Line, f.Column), f.Severity, f.Message, f.Rule)
+	if f.Statement == 0 {
+		// Findings about a file as a whole have no statement
+		return s
+	}
+	return fmt.Sprintf("%s (statement %d)", s, f.Statement)
+}
+
+// LintReport is the result of a lint run
+type LintReport struct {
+	File         string         `json:"file,omitempty"`
+	Statements   int            `json:"statements"`
+	Findings     []Finding      `json:"findings"`
+	SyntaxErrors []*SyntaxError `json:"syntax_errors"`
+	Suppressions []*Suppression `json:"suppressions"`
+}
+
+// LintSQL runs rules against every statement of sql that parses. Findings
+// are ordered by position; statements that don't parse are reported as
+// syntax errors. Findings hidden by dbsqlx:ignore comments move to the
+// report's suppressions. With a catalog, typed rules check the statements
+// against its column types, and DDL in sql updates it as it goes.
+func LintSQL(sql, file string, rules []Rule, catalog *schemaCatalog) LintReport {
+	stmts, errs := parseStatements(sql, file)
+	report := LintReport{
+		File:         file,
+		Statements:   len(stmts) + len(errs),
+		Findings:     []Finding{},
+		SyntaxErrors: errs,
+		Suppressions: []*Suppression{},
+	}
+	known := append(ruleIDs(BuiltinRules()), ruleIDs(rules)...)
+
+	for _, stmt := range stmts {
+		var found []Finding
+		for _, node := range stmt.Nodes {
+			var refs columnRefs
+			if catalog != nil {
+				refs = resolveColumns(node, catalog)
+			}
+			for _, rule := range rules {
+				violations := rule.Check(node)
+				if typed, ok := rule.(typedRule); ok && refs != nil {
+					violations = append(violations, typed.CheckTyped(node, refs)...)
+				}
+				for _, v := range violations {
+					found = append(found, newFinding(sql, file, stmt.sqlStatement, rule, v))
+				}
+			}
+			if catalog != nil {
+				catalog.apply(node)
+			}
+		}
+		sort.SliceStable(found, func(i, j int) bool { return found[i].Offset < found[j].Offset })
+
+		sups := statementSuppressions(sql, file, stmt.sqlStatement, known)
+		report.Findings = append(report.Findings, suppress(found, sups)...)
+		report.Suppressions = append(report.Suppressions, sups...)
+	}
+	return report
+}
+
+// newFinding positions a violation of stmt within the whole input src.
+// Violations without a position point at the statement's first token.
+func newFinding(src, file string, stmt sqlStatement, rule Rule, v Violation) Finding {
+	offset := stmt.Offset + min(max(v.Offset, leadingComments(stmt.Text)), len(stmt.Text))
+	f := Finding{
+		Rule:      rule.ID(),
+		Severity:  rule.Severity(),
+		File:      file,
+		Statement: stmt.Index,
+		Offset:    offset,
+		Message:   v.Message,
+	}
+	f.Line, f.Column = lineColumn(src, offset)
+	f.Excerpt = excerpt(src, offset, f.Line, utf8.RuneCountInString(firstToken(src[offset:])))
+	return f
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	switch lintFormat {
+	case "text", "json", "github":
+	default:
+		return fmt.Errorf("unknown format %q (valid: text, json, github)", lintFormat)
+	}
+
+	sql, err := getSQLInput(args)
+	if err != nil {
+		return err
+	}
+	// Findings are reported on their own, not with the usage text
+	cmd.SilenceUsage = true
+
+	configFile := lintConfigFile
+	if configFile == "" {
+		configFile = defaultConfigFile
+	}
+	cfg, err := loadConfig(configFile, lintConfigFile != "")
+	if err != nil {
+		return err
+	}
+
+	var catalog *schemaCatalog
+	if len(lintSchemas) > 0 {
+		if catalog, err = loadSchemaCatalog(lintSchemas); err != nil {
+			return err
+		}
+	}
+
+	report := LintSQL(sql, fileInput, cfg.Lint.rulesFor(BuiltinRules(), fileInput), catalog)
+	if err := writeLintReport(os.Stdout, report, lintFormat); err != nil {
+		return err
+	}
+
+	if len(report.SyntaxErrors) > 0 {
+		return fmt.Errorf("%s", checkSummary(checkReport{Statements: report.Statements, Failed: len(report.SyntaxErrors)}))
+	}
+	summary := lintSummary(report)
+	if severityCount(report, SeverityError) > 0 {
+		return fmt.Errorf("%s", summary)
+	}
+	if lintFormat == "text" {
+		fmt.Println(summary)
+	}
+	return nil
+}
+
+// writeLintReport prints the findings and syntax errors of a lint run
+func writeLintReport(w io.Writer, report LintReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "github":
+		for _, e := range report.SyntaxErrors {
+			fmt.Fprintln(w, githubAnnotation(e))
+		}
+		for _, f := range report.Findings {
+			fmt.Fprintln(w, workflowCommand(githubLevel(f.Severity), f.File, f.Line, f.Column, f.Rule, f.Message, f.Statement))
+		}
+		// Only suppressions that need fixing are annotated
+		for _, s := range report.Suppressions {
+			if len(s.UnknownRules) > 0 || len(s.Suppressed) == 0 {
+				fmt.Fprintln(w, workflowCommand("warning", s.File, s.Line, 1, "dbsqlx:ignore", s.describe(), 0))
+			}
+		}
+	default:
+		for _, e := range report.SyntaxErrors {
+			fmt.Fprintln(w, formatSyntaxError(e))
+		}
+		for _, f := range report.Findings {
+			fmt.Fprintln(w, f.String()+"\n"+f.Excerpt)
+		}
+		if len(report.Suppressions) > 0 {
+			fmt.Fprintln(w, "Suppressions:")
+			for _, s := range report.Suppressions {
+				fmt.Fprintln(w, "  "+s.String())
+			}
+		}
+	}
+	return nil
+}
+
+// githubLevel maps a severity to a GitHub Actions annotation level
+func githubLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "notice"
+}
+
+func severityCount(report LintReport, s Severity) int {
+	n := 0
+	for _, f := range report.Findings {
+		if f.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+// lintSummary counts the findings of a report by severity, e.g.
+// "3 findings: 1 error, 2 warnings (1 suppressed)"
+func lintSummary(report LintReport) string {
+	suppressed := ""
+	if n := suppressedCount(report); n > 0 {
+		suppressed = fmt.Sprintf(" (%d suppressed)", n)
+	}
+	if len(report.Findings) == 0 {
+		return fmt.Sprintf("✓ No lint findings in %s%s", counted(report.Statements, "statement", "statements"), suppressed)
+	}
+	var parts []string
+	for _, s := range []struct {
+		severity     Severity
+		one, several string
+	}{
+		{SeverityError, "error", "errors"},
+		{SeverityWarning, "warning", "warnings"},
+		{SeverityInfo, "info", "info"},
+	} {
+		if n := severityCount(report, s.severity); n > 0 {
+			parts = append(parts, counted(n, s.one, s.several))
+		}
+	}
+	return counted(len(report.Findings), "finding", "findings") + ": " + strings.Join(parts, ", ") + suppressed
+}
+
+func suppressedCount(report LintReport) int {
+	n := 0
+	for _, s := range report.Suppressions {
+		n += len(s.Suppressed)
+	}
+	return n
+}
+
+// counted renders n with the singular or plural noun
+func counted(n int, one, several string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, several)
+}
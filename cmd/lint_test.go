@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintSQL(t *testing.T) {
+	sql := "-- cleanup\n" +
+		"DELETE FROM logs;\n" +
+		"SELECT id FROM users\n  WHERE name LIKE '%son' AND email = NULL;\n" +
+		"SELECT FROM;\n" +
+		"SELECT id FROM users WHERE id = 1"
+
+	report := LintSQL(sql, "m.sql", BuiltinRules(), nil)
+	if report.Statements != 4 {
+		t.Errorf("Statements = %d, want 4", report.Statements)
+	}
+	if len(report.SyntaxErrors) != 1 || report.SyntaxErrors[0].Statement != 3 {
+		t.Errorf("SyntaxErrors = %v, want one in statement 3", report.SyntaxErrors)
+	}
+
+	// Every statement is linted, so earlier trees must survive later parses
+	var got []string
+	for _, f := range report.Findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"m.sql:2:1: error: DELETE without WHERE removes every row of logs [missing-where] (statement 1)",
+		`m.sql:4:9: warning: LIKE '%son' starts with a wildcard, so no index can narrow the scan [leading-wildcard-like] (statement 2)`,
+		"m.sql:4:30: error: = NULL is never true; use IS NULL [null-comparison] (statement 2)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if want := "4 |   WHERE name LIKE '%son' AND email = NULL;\n  |                              ^^^^^"; report.Findings[2].Excerpt != want {
+		t.Errorf("Excerpt =\n%s\nwant\n%s", report.Findings[2].Excerpt, want)
+	}
+}
+
+func TestLintSummary(t *testing.T) {
+	tests := []struct {
+		severities []Severity
+		want       string
+	}{
+		{nil, "✓ No lint findings in 3 statements"},
+		{[]Severity{SeverityInfo}, "1 finding: 1 info"},
+		{[]Severity{SeverityError, SeverityWarning, SeverityWarning, SeverityInfo}, "4 findings: 1 error, 2 warnings, 1 info"},
+	}
+	for _, tt := range tests {
+		report := LintReport{Statements: 3}
+		for _, s := range tt.severities {
+			report.Findings = append(report.Findings, Finding{Severity: s})
+		}
+		if got := lintSummary(report); got != tt.want {
+			t.Errorf("lintSummary(%v) = %q, want %q", tt.severities, got, tt.want)
+		}
+	}
+}
+
+func TestWriteLintReport(t *testing.T) {
+	report := LintSQL("SELECT * FROM t;\nDELETE FROM t", "m.sql", BuiltinRules(), nil)
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"text", []string{
+			"m.sql:1:8: info: SELECT * fetches every column; list the columns you need [select-star] (statement 1)\n1 | SELECT * FROM t;\n  |        ^",
+			"m.sql:2:1: error: DELETE without WHERE removes every row of t [missing-where] (statement 2)",
+		}},
+		{"json", []string{`"statements": 2`, `"rule": "missing-where"`, `"severity": "error"`, `"line": 2`, `"syntax_errors": []`}},
+		{"github", []string{
+			"::notice file=m.sql,line=1,col=8,title=select-star::SELECT * fetches every column; list the columns you need (statement 1)",
+			"::error file=m.sql,line=2,col=1,title=missing-where::DELETE without WHERE removes every row of t (statement 2)",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeLintReport(&buf, report, tt.format); err != nil {
+				t.Fatalf("writeLintReport() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q\nGot:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// manifestFile is the name of the manifest written into --out-dir
+const manifestFile = "manifest.json"
+
+// textPosition is a 1-based line and column in the SQL input
+type textPosition struct {
+	Line   int
+	Column int
+}
+
+// dumpManifest describes every dump written into an output directory
+type dumpManifest struct {
+	Database    string          `json:"database"`
+	Tool        string          `json:"tool"`
+	GeneratedAt string          `json:"generated_at"`
+	Dumps       []manifestEntry `json:"dumps"`
+}
+
+// manifestEntry describes one dump and the statement it backs up
+type manifestEntry struct {
+	Statement int `json:"statement"`
+	// Statements lists every source statement of a merged dump
+	Statements []int    `json:"statements,omitempty"`
+	Line       int      `json:"line"`
+	Column     int      `json:"column"`
+	Action     string   `json:"action,omitempty"`
+	SQL        string   `json:"sql,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	Table      string   `json:"table"`
+	Tables     []string `json:"tables,omitempty"`
+	Filter     string   `json:"filter"`
+	// Exact is set when the dump holds exactly the rows the statement
+	// touches rather than a superset
+	Exact   bool   `json:"exact"`
+	IDQuery string `json:"id_query,omitempty"`
+	// Query is set for saved query results instead of table dumps
+	Query   string `json:"query,omitempty"`
+	Command string `json:"command,omitempty"`
+	Script  string `json:"script,omitempty"`
+	File    string `json:"file,omitempty"`
+	Rows    *int64 `json:"rows,omitempty"`
+}
+
+// statementPositions locates each statement's text in the input
+func statementPositions(sql string, stmtNodes []ast.StmtNode) []textPosition {
+	positions := make([]textPosition, len(stmtNodes))
+	cursor := 0
+	for i, stmtNode := range stmtNodes {
+		text := strings.TrimSpace(stmtNode.Text())
+		offset := cursor
+		if idx := strings.Index(sql[cursor:], text); idx >= 0 && text != "" {
+			offset = cursor + idx
+			cursor = offset + len(text)
+		}
+		before := sql[:offset]
+		positions[i] = textPosition{
+			Line:   strings.Count(before, "\n") + 1,
+			Column: offset - strings.LastIndex(before, "\n"),
+		}
+	}
+	return positions
+}
+
+// dumpIsExact reports whether a dump selects exactly the rows its statement
+// touches: either through captured ids, or because the statement reads one
+// table and its whole WHERE clause became the filter
+func dumpIsExact(plan statementPlan, dump tableDump) bool {
+	if dump.Superset {
+		return false
+	}
+	if dump.IDQuery != "" {
+		return true
+	}
+	return len(plan.Tables) == 1 && len(dump.Also) == 0 && dump.Filter == plan.Where
+}
+
+// newManifestEntry fills in the statement and filter details of a dump
+func newManifestEntry(plan statementPlan, dump tableDump, positions []textPosition) manifestEntry {
+	e := manifestEntry{
+		Statement: plan.Index,
+		Action:    plan.Action,
+		SQL:       plan.Text,
+		Reason:    plan.Reason,
+		Table:     dump.Table,
+		Filter:    dump.Filter,
+		Exact:     dumpIsExact(plan, dump),
+		IDQuery:   dump.IDQuery,
+	}
+	if len(dump.Also) > 0 {
+		e.Tables = dump.AllTables()
+	}
+
+	source := plan.Index
+	if len(plan.Statements) > 0 {
+		// Merged plans are numbered on their own
+		e.Statements = plan.Statements
+		e.SQL = ""
+		source = plan.Statements[0]
+	}
+	if source >= 1 && source <= len(positions) {
+		e.Line, e.Column = positions[source-1].Line, positions[source-1].Column
+	}
+	return e
+}
+
+// writeOutDir writes one executable dump script per planned dump into dir
+// together with manifest.json
+func writeOutDir(dir string, plans []statementPlan, settings dumpSettings, tool dumpTool, toolName string, positions []textPosition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	m := dumpManifest{Database: settings.Database, Tool: toolName, Dumps: []manifestEntry{}}
+	for _, plan := range plans {
+		for _, dump := range plan.Dumps {
+			single := plan
+			single.Dumps = []tableDump{dump}
+
+			var buf bytes.Buffer
+			if err := writeDumpScript(&buf, []statementPlan{single}, settings, tool); err != nil {
+				return err
+			}
+			script := fmt.Sprintf("%s.%s_%d.sh", settings.Database, dump.Name(), plan.Index)
+			if err := os.WriteFile(filepath.Join(dir, script), buf.Bytes(), 0755); err != nil {
+				return fmt.Errorf("error writing script: %v", err)
+			}
+
+			where := ""
+			if dump.Filter != "" {
+				where = "\"" + dump.Filter + "\""
+			}
+			e := newManifestEntry(plan, dump, positions)
+			e.Script = script
+			e.Command = redactSecrets(tool.Command(settings, plan, dump.AllTables(), where, fmt.Sprintf("%s.%s_%d", settings.Database, dump.Name(), plan.Index)))
+			m.Dumps = append(m.Dumps, e)
+		}
+
+		for _, q := range plan.Queries {
+			single := plan
+			single.Dumps, single.Queries = nil, []planQuery{q}
+
+			var buf bytes.Buffer
+			if err := writeDumpScript(&buf, []statementPlan{single}, settings, tool); err != nil {
+				return err
+			}
+			script := fmt.Sprintf("%s_%d.sh", queryName(settings.Database, q), plan.Index)
+			if err := os.WriteFile(filepath.Join(dir, script), buf.Bytes(), 0755); err != nil {
+				return fmt.Errorf("error writing script: %v", err)
+			}
+
+			e := newManifestEntry(plan, tableDump{Table: q.Table}, positions)
+			e.Exact = false
+			e.Query = q.Query
+			e.Script = script
+			e.Command = redactSecrets(queryCommand(settings, q, queryFile(settings.Database, plan, q)))
+			m.Dumps = append(m.Dumps, e)
+		}
+	}
+
+	return writeManifest(dir, m)
+}
+
+// writeManifest stores m as manifest.json in dir
+func writeManifest(dir string, m dumpManifest) error {
+	m.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	return nil
+}
+
+// executeManifest describes the files written by --execute
+func executeManifest(database string, plans []statementPlan, results []dumpResult, positions []textPosition) dumpManifest {
+	m := dumpManifest{Database: database, Tool: "dbsqlx", Dumps: []manifestEntry{}}
+	for _, r := range results {
+		for _, plan := range plans {
+			if plan.Index != r.Statement {
+				continue
+			}
+			if r.Query != "" {
+				e := newManifestEntry(plan, tableDump{Table: r.Table}, positions)
+				e.Exact = false
+				e.Query = r.Query
+				e.File = filepath.Base(r.File)
+				rows := r.Rows
+				e.Rows = &rows
+				m.Dumps = append(m.Dumps, e)
+				continue
+			}
+			for _, dump := range plan.Dumps {
+				if !slices.Contains(dump.AllTables(), r.Table) {
+					continue
+				}
+				e := newManifestEntry(plan, tableDump{Table: r.Table, Filter: dump.Filter, IDQuery: dump.IDQuery, Key: dump.Key, Superset: dump.Superset}, positions)
+				e.File = filepath.Base(r.File)
+				rows := r.Rows
+				e.Rows = &rows
+				m.Dumps = append(m.Dumps, e)
+			}
+		}
+	}
+	return m
+}
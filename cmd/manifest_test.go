@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStatementPositions(t *testing.T) {
+	sql := "SELECT 1;\n\n  UPDATE t SET a = 1 WHERE id = 2;\nDELETE FROM x"
+	stmtNodes, err := ParseAll(sql)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	got := statementPositions(sql, stmtNodes)
+	want := []textPosition{{Line: 1, Column: 1}, {Line: 3, Column: 3}, {Line: 4, Column: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statementPositions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteOutDir(t *testing.T) {
+	sql := `DELETE FROM users WHERE id = 7;
+UPDATE orders o JOIN users u ON o.user_id = u.id SET o.status = 'x' WHERE u.name = 'ann' AND o.id > 3`
+	stmtNodes, err := ParseAll(sql)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backup")
+	settings := dumpSettings{ConnOpts: " -u root", Conn: &connOptions{User: "root"}, Database: "shop", Compression: "none"}
+	if err := writeOutDir(dir, buildDumpPlan(stmtNodes, testKeyCatalog(t, "orders=id")), settings, mysqldumpTool{}, "mysqldump", statementPositions(sql, stmtNodes)); err != nil {
+		t.Fatalf("writeOutDir() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		t.Fatalf("reading manifest: %v", err)
+	}
+	var m dumpManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v\n%s", err, data)
+	}
+	m.GeneratedAt = ""
+
+	want := dumpManifest{
+		Database: "shop",
+		Tool:     "mysqldump",
+		Dumps: []manifestEntry{
+			{
+				Statement: 1,
+				Line:      1,
+				Column:    1,
+				Action:    "DELETE",
+				SQL:       "DELETE FROM users WHERE id = 7;",
+				Table:     "users",
+				Filter:    "id=7",
+				Exact:     true,
+				Command:   `mysqldump -u root --single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF --where="id=7" shop users`,
+				Script:    "shop.users_1.sh",
+			},
+			{
+				Statement: 2,
+				Line:      2,
+				Column:    1,
+				Action:    "UPDATE",
+				SQL:       "UPDATE orders o JOIN users u ON o.user_id = u.id SET o.status = 'x' WHERE u.name = 'ann' AND o.id > 3",
+				Table:     "orders",
+				Filter:    "id>3",
+				Exact:     true,
+				IDQuery:   "SELECT DISTINCT `o`.`id` FROM `orders` AS `o` JOIN `users` AS `u` ON `o`.`user_id`=`u`.`id` WHERE `u`.`name`='ann' AND `o`.`id`>3",
+				Command:   `mysqldump -u root --single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF --where="id>3" shop orders`,
+				Script:    "shop.orders_2.sh",
+			},
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("manifest =\n%+v\nwant\n%+v", m, want)
+	}
+
+	for _, e := range m.Dumps {
+		info, err := os.Stat(filepath.Join(dir, e.Script))
+		if err != nil {
+			t.Fatalf("script %s not written: %v", e.Script, err)
+		}
+		if info.Mode().Perm()&0100 == 0 {
+			t.Errorf("script %s is not executable", e.Script)
+		}
+	}
+}
+
+func TestDumpIsExact(t *testing.T) {
+	tests := []struct {
+		name string
+		plan statementPlan
+		dump tableDump
+		want bool
+	}{
+		{
+			name: "Single table with its whole WHERE",
+			plan: statementPlan{Tables: []string{"users"}, Where: "id=1"},
+			dump: tableDump{Table: "users", Filter: "id=1"},
+			want: true,
+		},
+		{
+			name: "Joined table filtered to a superset",
+			plan: statementPlan{Tables: []string{"users", "orders"}, Where: "users.id=orders.user_id and users.active=1"},
+			dump: tableDump{Table: "users", Filter: "active=1"},
+			want: false,
+		},
+		{
+			name: "Exact ids captured",
+			plan: statementPlan{Tables: []string{"users", "orders"}, Where: "u.name='ann'"},
+			dump: tableDump{Table: "orders", IDQuery: "SELECT DISTINCT o.id FROM orders"},
+			want: true,
+		},
+		{
+			name: "LIMIT without captured ids",
+			plan: statementPlan{Tables: []string{"jobs"}, Where: "state='stuck'", Limit: "5"},
+			dump: tableDump{Table: "jobs", Filter: "state='stuck'", Superset: true},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dumpIsExact(tt.plan, tt.dump); got != tt.want {
+				t.Errorf("dumpIsExact() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// mergeGroup collects the dumps of one table that share a preset
+type mergeGroup struct {
+	table      string
+	preset     string
+	filters    []string
+	where      []string
+	full       bool
+	dumps      int
+	idQuery    string
+	key        []string
+	superset   bool
+	limited    bool
+	statements []int
+	actions    []string
+	reasons    []string
+}
+
+// mergePlans combines the dumps of all statements into as few commands as
+// possible. Dumps of the same table and preset are merged by ORing their
+// filters, falling back to the whole table when any statement needs it, and
+// tables left with identical filters are dumped by one command. Each
+// returned plan holds one merged dump and lists its source statements;
+// statements' saved queries follow in plans of their own.
+func mergePlans(plans []statementPlan) []statementPlan {
+	var groups []*mergeGroup
+	byKey := make(map[string]*mergeGroup)
+
+	for _, plan := range plans {
+		for _, dump := range plan.Dumps {
+			key := dump.Table + "\x00" + plan.Preset
+			g, ok := byKey[key]
+			if !ok {
+				g = &mergeGroup{table: dump.Table, preset: plan.Preset}
+				byKey[key] = g
+				groups = append(groups, g)
+			}
+			g.dumps++
+			g.idQuery, g.key = dump.IDQuery, dump.Key
+			g.superset = g.superset || dump.Superset
+			g.limited = g.limited || plan.Limit != ""
+			if dump.Filter == "" {
+				g.full = true
+			} else {
+				g.filters = append(g.filters, dump.Filter)
+			}
+			if plan.Where != "" {
+				g.where = append(g.where, plan.Where)
+			}
+			g.statements = appendUnique(g.statements, plan.Index)
+			g.actions = appendUnique(g.actions, plan.Action)
+			if plan.Reason != "" {
+				g.reasons = appendUnique(g.reasons, plan.Reason)
+			}
+		}
+	}
+
+	// Merge tables whose filters came out identical
+	var merged []*mergeGroup
+	var dumps []tableDump
+	for _, g := range groups {
+		dump := tableDump{Table: g.table}
+		if !g.full {
+			dump.Filter = mergeFilters(g.filters)
+		}
+		// Exact ids only stay exact for a single statement
+		if g.dumps == 1 {
+			dump.IDQuery, dump.Key = g.idQuery, g.key
+		}
+		// and so do LIMIT bounds
+		dump.Superset = g.superset || (g.dumps > 1 && g.limited)
+
+		combined := false
+		if dump.IDQuery == "" {
+			for i, other := range merged {
+				if other.preset == g.preset && dumps[i].IDQuery == "" && dumps[i].Filter == dump.Filter && dumps[i].Superset == dump.Superset {
+					dumps[i].Also = append(dumps[i].Also, g.table)
+					for _, s := range g.statements {
+						other.statements = appendUnique(other.statements, s)
+					}
+					for _, a := range g.actions {
+						other.actions = appendUnique(other.actions, a)
+					}
+					other.where = append(other.where, g.where...)
+					for _, r := range g.reasons {
+						other.reasons = appendUnique(other.reasons, r)
+					}
+					combined = true
+					break
+				}
+			}
+		}
+		if !combined {
+			merged = append(merged, g)
+			dumps = append(dumps, dump)
+		}
+	}
+
+	result := make([]statementPlan, 0, len(merged))
+	for i, g := range merged {
+		plan := statementPlan{
+			Index:      i + 1,
+			Text:       "Merged from statements " + joinInts(g.statements),
+			Tables:     dumps[i].AllTables(),
+			Where:      mergeFilters(g.where),
+			Preset:     g.preset,
+			Dumps:      []tableDump{dumps[i]},
+			Statements: g.statements,
+		}
+		if len(g.actions) == 1 {
+			plan.Action = g.actions[0]
+		}
+		if len(g.reasons) == 1 {
+			plan.Reason = g.reasons[0]
+		}
+		if g.dumps > 1 && g.limited {
+			plan.Reason = "superset dump: merged statements with LIMIT keep only their filters"
+		}
+		result = append(result, plan)
+	}
+
+	// Saved query results are kept as they are
+	for _, plan := range plans {
+		if len(plan.Queries) == 0 {
+			continue
+		}
+		result = append(result, statementPlan{
+			Index:      len(result) + 1,
+			Text:       "Merged from statements " + fmt.Sprint(plan.Index),
+			Action:     plan.Action,
+			Tables:     plan.Tables,
+			Preset:     plan.Preset,
+			Statements: []int{plan.Index},
+			Reason:     plan.Reason,
+			Queries:    plan.Queries,
+		})
+	}
+	return result
+}
+
+// mergeFilters ORs filters into one predicate. Duplicate filters and
+// conjuncts are dropped and conjuncts shared by every filter are factored
+// out, so "a=1 and b=2" and "a=1 and c=3" become "a=1 and (b=2 OR c=3)".
+func mergeFilters(filters []string) string {
+	var conjunctSets [][]string
+	seen := make(map[string]bool)
+	for _, filter := range filters {
+		filter = strings.TrimSpace(filter)
+		if filter == "" || seen[filter] {
+			continue
+		}
+		seen[filter] = true
+
+		var conjuncts []string
+		for _, c := range splitConjuncts(filter) {
+			conjuncts = appendUnique(conjuncts, c)
+		}
+		conjunctSets = append(conjunctSets, conjuncts)
+	}
+	if len(conjunctSets) == 0 {
+		return ""
+	}
+	if len(conjunctSets) == 1 {
+		return joinConjuncts(conjunctSets[0])
+	}
+
+	var common []string
+	for _, c := range conjunctSets[0] {
+		shared := true
+		for _, set := range conjunctSets[1:] {
+			if !slices.Contains(set, c) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			common = append(common, c)
+		}
+	}
+
+	var alternatives []string
+	for _, set := range conjunctSets {
+		var rest []string
+		for _, c := range set {
+			if !slices.Contains(common, c) {
+				rest = append(rest, c)
+			}
+		}
+		// One filter is just the shared part, which already covers the rest
+		if len(rest) == 0 {
+			return joinConjuncts(common)
+		}
+		alt := joinConjuncts(rest)
+		if len(rest) > 1 || hasTopLevelOr(alt) {
+			alt = "(" + alt + ")"
+		}
+		alternatives = appendUnique(alternatives, alt)
+	}
+
+	disjunction := strings.Join(alternatives, " OR ")
+	if len(common) == 0 {
+		return disjunction
+	}
+	return joinConjuncts(common) + " and (" + disjunction + ")"
+}
+
+// splitConjuncts splits a filter on its top-level " and "s, leaving quoted
+// strings, parenthesised groups and BETWEEN ... and ... intact
+func splitConjuncts(filter string) []string {
+	lower := strings.ToLower(filter)
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	between := false
+
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ':
+			if depth != 0 {
+				continue
+			}
+			if strings.HasPrefix(lower[i:], " between ") {
+				between = true
+			} else if strings.HasPrefix(lower[i:], " and ") {
+				if between {
+					between = false
+					continue
+				}
+				parts = append(parts, strings.TrimSpace(filter[start:i]))
+				start = i + len(" and ")
+				i = start - 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(filter[start:]))
+}
+
+// joinConjuncts ANDs conjuncts, parenthesising any that contain a
+// top-level OR
+func joinConjuncts(conjuncts []string) string {
+	if len(conjuncts) == 1 {
+		return conjuncts[0]
+	}
+	wrapped := make([]string, len(conjuncts))
+	for i, c := range conjuncts {
+		if hasTopLevelOr(c) {
+			c = "(" + c + ")"
+		}
+		wrapped[i] = c
+	}
+	return strings.Join(wrapped, " and ")
+}
+
+// hasTopLevelOr reports whether expr contains an OR outside parentheses
+// and quotes
+func hasTopLevelOr(expr string) bool {
+	lower := strings.ToLower(expr)
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ':
+			if depth == 0 && (strings.HasPrefix(lower[i:], " or ") || strings.HasPrefix(lower[i:], " || ")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendUnique appends v unless list already holds it
+func appendUnique[T comparable](list []T, v T) []T {
+	if slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+func joinInts(list []int) string {
+	parts := make([]string, len(list))
+	for i, n := range list {
+		parts[i] = fmt.Sprint(n)
+	}
+	return strings.Join(parts, ", ")
+}
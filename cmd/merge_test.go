@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitConjuncts(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"id=1", []string{"id=1"}},
+		{"status='open' and id>5", []string{"status='open'", "id>5"}},
+		{"(x=1 OR y=2) and z BETWEEN 1 and 5 and n IN (1,2)", []string{"(x=1 OR y=2)", "z BETWEEN 1 and 5", "n IN (1,2)"}},
+		{"s LIKE 'a and b' and t=1", []string{"s LIKE 'a and b'", "t=1"}},
+		{`s='it\'s and' and t=1`, []string{`s='it\'s and'`, "t=1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			if got := splitConjuncts(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitConjuncts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []string
+		want    string
+	}{
+		{
+			name:    "Identical filters",
+			filters: []string{"id=1", "id=1"},
+			want:    "id=1",
+		},
+		{
+			name:    "Disjoint filters",
+			filters: []string{"id=1", "id=2"},
+			want:    "id=1 OR id=2",
+		},
+		{
+			name:    "Shared conjuncts are factored out",
+			filters: []string{"status='open' and id=1", "status='open' and id=2 and amount>5"},
+			want:    "status='open' and (id=1 OR (id=2 and amount>5))",
+		},
+		{
+			name:    "A filter covering another wins",
+			filters: []string{"status='open' and id=1", "status='open'"},
+			want:    "status='open'",
+		},
+		{
+			name:    "Repeated conjuncts within one filter",
+			filters: []string{"a=1 and a=1"},
+			want:    "a=1",
+		},
+		{
+			name:    "OR filters stay grouped",
+			filters: []string{"a=1 OR b=2", "c=3"},
+			want:    "(a=1 OR b=2) OR c=3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeFilters(tt.filters); got != tt.want {
+				t.Errorf("mergeFilters() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePlans(t *testing.T) {
+	stmtNodes, err := ParseAll(`UPDATE orders SET status = 'closed' WHERE id = 1;
+UPDATE orders SET status = 'closed' WHERE id = 2;
+DELETE FROM users WHERE id = 7;
+DELETE FROM carts WHERE id = 7;
+UPDATE orders SET status = 'closed' WHERE id = 1;
+DELETE FROM sessions;
+DELETE FROM sessions WHERE user_id = 7;
+ALTER TABLE orders ADD COLUMN note TEXT`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	got := mergePlans(buildDumpPlan(stmtNodes, nil))
+	want := []statementPlan{
+		{
+			Index:      1,
+			Text:       "Merged from statements 1, 2, 5",
+			Action:     "UPDATE",
+			Tables:     []string{"orders"},
+			Where:      "id=1 OR id=2",
+			Preset:     "pre-dml-backup",
+			Dumps:      []tableDump{{Table: "orders", Filter: "id=1 OR id=2"}},
+			Statements: []int{1, 2, 5},
+		},
+		{
+			Index:      2,
+			Text:       "Merged from statements 3, 4",
+			Action:     "DELETE",
+			Tables:     []string{"users", "carts"},
+			Where:      "id=7",
+			Preset:     "pre-dml-backup",
+			Dumps:      []tableDump{{Table: "users", Filter: "id=7", Also: []string{"carts"}}},
+			Statements: []int{3, 4},
+		},
+		{
+			Index:      3,
+			Text:       "Merged from statements 6, 7",
+			Action:     "DELETE",
+			Tables:     []string{"sessions"},
+			Where:      "user_id=7",
+			Preset:     "pre-dml-backup",
+			Dumps:      []tableDump{{Table: "sessions"}},
+			Statements: []int{6, 7},
+		},
+		{
+			Index:      4,
+			Text:       "Merged from statements 8",
+			Action:     "ALTER",
+			Tables:     []string{"orders"},
+			Preset:     "schema-only",
+			Dumps:      []tableDump{{Table: "orders"}},
+			Statements: []int{8},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergePlans() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestMergePlansKeepsExactIDsOfSingleStatement(t *testing.T) {
+	stmtNodes, err := ParseAll(`UPDATE orders o JOIN users u ON o.user_id = u.id SET o.status = 'x' WHERE u.name = 'ann';
+SELECT * FROM users WHERE id = 3`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	got := mergePlans(buildDumpPlan(stmtNodes, testKeyCatalog(t, "orders=id")))
+	if len(got) != 2 {
+		t.Fatalf("mergePlans() returned %d plans, want 2: %+v", len(got), got)
+	}
+	if got[0].Dumps[0].IDQuery == "" {
+		t.Errorf("single-statement dump lost its exact id query: %+v", got[0].Dumps[0])
+	}
+}
+
+func TestMergePlansFlagsBoundedDumps(t *testing.T) {
+	stmtNodes, err := ParseAll(`DELETE FROM logs WHERE created < '2024-01-01' ORDER BY id LIMIT 1000;
+DELETE FROM logs WHERE id = 3`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	got := mergePlans(buildDumpPlan(stmtNodes, nil))
+	if len(got) != 1 {
+		t.Fatalf("mergePlans() returned %d plans, want 1: %+v", len(got), got)
+	}
+	want := tableDump{Table: "logs", Filter: "created<'2024-01-01' OR id=3", Superset: true}
+	if !reflect.DeepEqual(got[0].Dumps[0], want) {
+		t.Errorf("merged dump = %+v, want %+v", got[0].Dumps[0], want)
+	}
+	if dumpIsExact(got[0], got[0].Dumps[0]) {
+		t.Errorf("merged LIMIT dump reported as exact")
+	}
+}
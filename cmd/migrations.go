@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// Migration file names of the tools whose directories check --migrations
+// replays. Down and undo migrations are skipped.
+var (
+	// golang-migrate: 000001_create_users.up.sql
+	golangMigrateName = regexp.MustCompile(`^(\d+)_(.*)\.(up|down)\.sql$`)
+	// Flyway: V1_1__create_users.sql, U1_1__create_users.sql, R__views.sql
+	flywayName = regexp.MustCompile(`^(?:([VU])(\d+(?:[._]\d+)*)|R)__(.*)\.sql$`)
+	// goose: 20240101120000_create_users.sql, with -- +goose Up/Down sections
+	gooseName = regexp.MustCompile(`^(\d+)_(.*)\.sql$`)
+)
+
+// migration is a file of a migrations directory
+type migration struct {
+	Path string
+	// Version is "" for Flyway repeatable migrations, which run after the
+	// versioned ones
+	Version string
+	Tool    string
+	// key is the version's numeric parts without leading zeros, and
+	// without trailing zero parts so that Flyway's 1.0 and 1 are one version
+	key []string
+	// sql is the text replayed: the Up section of goose files
+	sql string
+}
+
+// parseMigrationName recognises the migration tool naming a file. skip is
+// set for down and undo migrations, ok is false for names no tool uses.
+func parseMigrationName(name string) (m migration, skip, ok bool) {
+	if g := golangMigrateName.FindStringSubmatch(name); g != nil {
+		return migration{Version: g[1], Tool: "golang-migrate"}.withKey(), g[3] == "down", true
+	}
+	if g := flywayName.FindStringSubmatch(name); g != nil {
+		version := strings.ReplaceAll(g[2], "_", ".")
+		return migration{Version: version, Tool: "flyway"}.withKey(), g[1] == "U", true
+	}
+	if g := gooseName.FindStringSubmatch(name); g != nil {
+		return migration{Version: g[1], Tool: "goose"}.withKey(), false, true
+	}
+	return migration{}, false, false
+}
+
+func (m migration) withKey() migration {
+	m.key = nil
+	if m.Version == "" {
+		return m
+	}
+	for _, part := range strings.FieldsFunc(m.Version, func(r rune) bool { return r == '.' || r == '_' }) {
+		if part = strings.TrimLeft(part, "0"); part == "" {
+			part = "0"
+		}
+		m.key = append(m.key, part)
+	}
+	for len(m.key) > 1 && m.key[len(m.key)-1] == "0" {
+		m.key = m.key[:len(m.key)-1]
+	}
+	return m
+}
+
+// compareVersions orders version keys numerically, part by part. Parts are
+// compared as digit strings so timestamps of any length don't overflow.
+func compareVersions(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := cmp.Compare(len(a[i]), len(b[i])); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// gooseUp blanks out everything of a goose file but its -- +goose Up
+// section, keeping line numbers. StatementBegin/StatementEnd blocks, such
+// as procedure or trigger bodies, become DELIMITER blocks so they split as
+// one statement. Files without annotations are replayed whole.
+func gooseUp(sql string) string {
+	if !strings.Contains(sql, "-- +goose Up") {
+		return sql
+	}
+	lines := strings.SplitAfter(sql, "\n")
+	up := false
+	for i, line := range lines {
+		eol := line[len(strings.TrimRight(line, "\r\n")):]
+		switch annotation := strings.TrimSpace(line); {
+		case strings.HasPrefix(annotation, "-- +goose Up"):
+			up = true
+			continue
+		case strings.HasPrefix(annotation, "-- +goose Down"):
+			up = false
+		case up && strings.HasPrefix(annotation, "-- +goose StatementBegin"):
+			lines[i] = "DELIMITER ;;" + eol
+			continue
+		case up && strings.HasPrefix(annotation, "-- +goose StatementEnd"):
+			lines[i] = ";; DELIMITER ;" + eol
+			continue
+		}
+		if !up {
+			lines[i] = eol
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// loadMigrations reads the migrations of dir and its subdirectories in the
+// order the tools apply them: versioned migrations by version, then Flyway
+// repeatable migrations by name. Files no tool would run are reported.
+func loadMigrations(dir string) ([]migration, []Finding, error) {
+	var migrations []migration
+	var findings []Finding
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".sql") {
+			return nil
+		}
+		m, skip, ok := parseMigrationName(d.Name())
+		if !ok {
+			findings = append(findings, fileFinding(path, "migration-name",
+				fmt.Sprintf("%s doesn't follow golang-migrate, goose or Flyway naming, so no tool runs it", d.Name())))
+			return nil
+		}
+		if skip {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading migration: %v", err)
+		}
+		m.Path, m.sql = path, string(content)
+		if m.Tool == "goose" {
+			m.sql = gooseUp(m.sql)
+		}
+		migrations = append(migrations, m)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	slices.SortStableFunc(migrations, func(a, b migration) int {
+		switch {
+		case a.Version == "" && b.Version == "":
+			return strings.Compare(filepath.Base(a.Path), filepath.Base(b.Path))
+		case a.Version == "" || b.Version == "":
+			// Repeatable migrations run last
+			return cmp.Compare(b.Version, a.Version)
+		}
+		if c := compareVersions(a.key, b.key); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	for i := 1; i < len(migrations); i++ {
+		prev, m := migrations[i-1], migrations[i]
+		if m.Version != "" && compareVersions(prev.key, m.key) == 0 {
+			findings = append(findings, fileFinding(m.Path, "duplicate-version",
+				fmt.Sprintf("version %s is also the version of %s; the tools refuse to run both", m.Version, prev.Path)))
+		}
+	}
+	return migrations, findings, nil
+}
+
+// fileFinding is a finding about a migration file as a whole
+func fileFinding(path, rule, message string) Finding {
+	return Finding{Rule: rule, Severity: SeverityError, File: path, Line: 1, Column: 1, Message: message}
+}
+
+// migrationPosition is a statement of a migration
+type migrationPosition struct {
+	migration, statement int
+	// at is the file and line, e.g. 003_orders.up.sql:4
+	at string
+}
+
+func (p migrationPosition) after(q migrationPosition) bool {
+	return p.migration > q.migration || (p.migration == q.migration && p.statement > q.statement)
+}
+
+// migrationCreations records where migrations create each table and column
+type migrationCreations struct {
+	tables map[string][]migrationPosition
+	// columns are keyed by table, then column
+	columns map[string]map[string][]migrationPosition
+}
+
+func (c *migrationCreations) addColumn(table, column string, at migrationPosition) {
+	table, column = strings.ToLower(table), strings.ToLower(column)
+	if c.columns[table] == nil {
+		c.columns[table] = make(map[string][]migrationPosition)
+	}
+	c.columns[table][column] = append(c.columns[table][column], at)
+}
+
+func (c *migrationCreations) record(node ast.StmtNode, at migrationPosition) {
+	switch stmt := node.(type) {
+	case *ast.CreateTableStmt:
+		c.tables[stmt.Table.Name.L] = append(c.tables[stmt.Table.Name.L], at)
+		for _, col := range stmt.Cols {
+			c.addColumn(stmt.Table.Name.O, col.Name.Name.O, at)
+		}
+	case *ast.CreateViewStmt:
+		c.tables[stmt.ViewName.Name.L] = append(c.tables[stmt.ViewName.Name.L], at)
+	case *ast.RenameTableStmt:
+		for _, tt := range stmt.TableToTables {
+			c.tables[tt.NewTable.Name.L] = append(c.tables[tt.NewTable.Name.L], at)
+		}
+	case *ast.AlterTableStmt:
+		for _, spec := range stmt.Specs {
+			switch spec.Tp {
+			case ast.AlterTableAddColumns, ast.AlterTableChangeColumn:
+				for _, col := range spec.NewColumns {
+					c.addColumn(stmt.Table.Name.O, col.Name.Name.O, at)
+				}
+			case ast.AlterTableRenameColumn:
+				c.addColumn(stmt.Table.Name.O, spec.NewColumnName.Name.O, at)
+			case ast.AlterTableRenameTable:
+				c.tables[spec.NewTable.Name.L] = append(c.tables[spec.NewTable.Name.L], at)
+			}
+		}
+	}
+}
+
+// later returns where a migration after at creates what issue misses
+func (c *migrationCreations) later(issue schemaIssue, at migrationPosition) (migrationPosition, bool) {
+	var candidates []migrationPosition
+	switch {
+	case issue.Check == "unknown-table":
+		candidates = c.tables[strings.ToLower(issue.Table)]
+	case issue.Check == "unknown-column" && issue.Table != "":
+		candidates = c.columns[strings.ToLower(issue.Table)][strings.ToLower(issue.Column)]
+	case issue.Check == "unknown-column":
+		for _, columns := range c.columns {
+			candidates = append(candidates, columns[strings.ToLower(issue.Column)]...)
+		}
+	}
+	var first migrationPosition
+	found := false
+	for _, p := range candidates {
+		if p.after(at) && (!found || first.after(p)) {
+			first, found = p, true
+		}
+	}
+	return first, found
+}
+
+// droppedTable is a DROP TABLE with the later statements that still
+// reference the table
+type droppedTable struct {
+	finding Finding
+	refs    []string
+}
+
+// CheckMigrations replays the migrations of dir in version order into
+// catalog, checking each statement against the tables the migrations
+// before it leave. On top of the syntax and schema errors of check
+// --schema it reports duplicate versions, references to tables and columns
+// a later migration creates, CREATE of a table that exists, and DROP of a
+// table later migrations still use.
+func CheckMigrations(dir string, catalog *schemaCatalog) (checkReport, error) {
+	migrations, findings, err := loadMigrations(dir)
+	if err != nil {
+		return checkReport{}, err
+	}
+	report := checkReport{Errors: []*SyntaxError{}, Migrations: []string{}, schema: true}
+
+	parsed := make([][]parsedStatement, len(migrations))
+	creations := &migrationCreations{tables: map[string][]migrationPosition{}, columns: map[string]map[string][]migrationPosition{}}
+	position := func(i int, stmt parsedStatement) migrationPosition {
+		line, _ := lineColumn(migrations[i].sql, stmt.Offset+leadingComments(stmt.Text))
+		return migrationPosition{migration: i, statement: stmt.Index, at: fmt.Sprintf("%s:%d", migrations[i].Path, line)}
+	}
+	for i, m := range migrations {
+		stmts, errs := parseStatements(m.sql, m.Path)
+		parsed[i] = stmts
+		report.Migrations = append(report.Migrations, m.Path)
+		report.Statements += len(stmts) + len(errs)
+		report.Failed += len(errs)
+		report.Errors = append(report.Errors, errs...)
+		for _, stmt := range stmts {
+			for _, node := range stmt.Nodes {
+				creations.record(node, position(i, stmt))
+			}
+		}
+	}
+
+	// created tells where the tables of the catalog come from; renamed
+	// tables are left out
+	created := map[string]string{}
+	for name := range catalog.tables {
+		created[name] = "the --schema files define it"
+	}
+	dropped := map[string]*droppedTable{}
+	var drops []*droppedTable
+	for i, m := range migrations {
+		for _, stmt := range parsed[i] {
+			at := position(i, stmt)
+			for _, node := range stmt.Nodes {
+				r := &resolver{catalog: catalog, text: stmt.Text}
+				r.statement(node)
+				slices.SortStableFunc(r.issues, func(a, b schemaIssue) int { return cmp.Compare(a.Offset, b.Offset) })
+
+				var seen []schemaIssue
+				for _, issue := range r.issues {
+					if slices.Contains(seen, issue) {
+						continue
+					}
+					seen = append(seen, issue)
+					if d := dropped[strings.ToLower(issue.Table)]; d != nil && issue.Check == "unknown-table" {
+						d.refs = appendUnique(d.refs, at.at)
+						continue
+					}
+					rule := issue.Check
+					if later, ok := creations.later(issue, at); ok {
+						rule, issue.Message = "created-later", fmt.Sprintf("%s; it's created later, at %s", issue.Message, later.at)
+					}
+					findings = append(findings, newFinding(m.sql, m.Path, stmt.sqlStatement, funcRule{id: rule, severity: SeverityError}, issue.Violation))
+				}
+
+				if name, guarded, ok := createdTable(node); ok {
+					key := strings.ToLower(name)
+					if t := catalog.tables[key]; t != nil && !guarded {
+						message := fmt.Sprintf("table %s already exists", name)
+						if origin, ok := created[key]; ok {
+							message += ": " + origin
+						}
+						findings = append(findings, newFinding(m.sql, m.Path, stmt.sqlStatement, funcRule{id: "table-exists", severity: SeverityError},
+							Violation{Offset: identOffset(stmt.Text, name), Message: message}))
+					}
+					if catalog.tables[key] == nil || !guarded {
+						created[key] = "created at " + at.at
+						delete(dropped, key)
+					}
+				}
+				if drop, ok := node.(*ast.DropTableStmt); ok {
+					for _, tn := range drop.Tables {
+						if catalog.tables[tn.Name.L] == nil {
+							continue
+						}
+						d := &droppedTable{finding: newFinding(m.sql, m.Path, stmt.sqlStatement, funcRule{id: "dropped-table-referenced", severity: SeverityError},
+							Violation{Offset: identOffset(stmt.Text, tn.Name.O), Message: tn.Name.O})}
+						dropped[tn.Name.L], drops = d, append(drops, d)
+						delete(created, tn.Name.L)
+					}
+				}
+				catalog.apply(node)
+			}
+		}
+	}
+
+	for _, d := range drops {
+		if len(d.refs) > 0 {
+			d.finding.Message = fmt.Sprintf("table %s is dropped but later migrations still use it: %s", d.finding.Message, strings.Join(d.refs, ", "))
+			findings = append(findings, d.finding)
+		}
+	}
+	order := map[string]int{}
+	for i, m := range migrations {
+		order[m.Path] = i + 1
+	}
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(cmp.Compare(order[a.File], order[b.File]), strings.Compare(a.File, b.File), cmp.Compare(a.Offset, b.Offset))
+	})
+	report.SchemaErrors = findings
+	report.Valid = report.Failed == 0 && len(findings) == 0
+	return report, nil
+}
+
+// createdTable returns the table or view a statement creates. guarded is
+// set for CREATE ... IF NOT EXISTS and CREATE OR REPLACE VIEW, which
+// don't fail when it exists.
+func createdTable(node ast.StmtNode) (name string, guarded, ok bool) {
+	switch stmt := node.(type) {
+	case *ast.CreateTableStmt:
+		return stmt.Table.Name.O, stmt.IfNotExists, true
+	case *ast.CreateViewStmt:
+		return stmt.ViewName.Name.O, stmt.OrReplace, true
+	}
+	return "", false, false
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeMigrations creates a migrations directory with the given files
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseMigrationName(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		tool    string
+		skip    bool
+		ok      bool
+	}{
+		{"000001_create_users.up.sql", "000001", "golang-migrate", false, true},
+		{"000001_create_users.down.sql", "000001", "golang-migrate", true, true},
+		{"20240101120000_create_users.sql", "20240101120000", "goose", false, true},
+		{"V1_1__create_users.sql", "1.1", "flyway", false, true},
+		{"V2.0.1__orders.sql", "2.0.1", "flyway", false, true},
+		{"U1_1__create_users.sql", "1.1", "flyway", true, true},
+		{"R__views.sql", "", "flyway", false, true},
+		{"create_users.sql", "", "", false, false},
+		{"V1_create_users.sql", "", "", false, false},
+	}
+	for _, tt := range tests {
+		m, skip, ok := parseMigrationName(tt.name)
+		if m.Version != tt.version || m.Tool != tt.tool || skip != tt.skip || ok != tt.ok {
+			t.Errorf("parseMigrationName(%q) = %q, %q, skip %v, ok %v; want %q, %q, skip %v, ok %v",
+				tt.name, m.Version, m.Tool, skip, ok, tt.version, tt.tool, tt.skip, tt.ok)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1},
+		{"0002", "2", 0},
+		{"1.0", "1", 0},
+		{"1.1", "1.0.5", 1},
+		{"1.9", "1.10", -1},
+		{"20240101120000", "20231231235959", 1},
+	}
+	for _, tt := range tests {
+		a, b := migration{Version: tt.a}.withKey(), migration{Version: tt.b}.withKey()
+		if got := compareVersions(a.key, b.key); got != tt.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGooseUp(t *testing.T) {
+	sql := "-- +goose Up\nCREATE TABLE users (id INT);\n-- +goose Down\nDROP TABLE users;\n"
+	want := "-- +goose Up\nCREATE TABLE users (id INT);\n\n\n"
+	if got := gooseUp(sql); got != want {
+		t.Errorf("gooseUp() = %q, want %q", got, want)
+	}
+	if got := gooseUp("CREATE TABLE users (id INT);"); got != "CREATE TABLE users (id INT);" {
+		t.Errorf("gooseUp() without annotations = %q", got)
+	}
+}
+
+func TestGooseUpStatementBlocks(t *testing.T) {
+	sql := "-- +goose Up\n" +
+		"CREATE TABLE audit (id INT);\n" +
+		"-- +goose StatementBegin\n" +
+		"CREATE PROCEDURE bump_audit()\n" +
+		"BEGIN\n" +
+		"  INSERT INTO audit VALUES (1);\n" +
+		"  UPDATE audit SET id = id + 1;\n" +
+		"END;\n" +
+		"-- +goose StatementEnd\n" +
+		"DROP TABLE legacy;\n" +
+		"-- +goose Down\n" +
+		"-- +goose StatementBegin\n" +
+		"DROP PROCEDURE bump_audit;\n" +
+		"-- +goose StatementEnd\n"
+	up := gooseUp(sql)
+	if got, want := strings.Count(up, "\n"), strings.Count(sql, "\n"); got != want {
+		t.Errorf("gooseUp() has %d lines, want %d", got, want)
+	}
+
+	var got []string
+	for _, stmt := range splitStatements(up) {
+		got = append(got, strings.Fields(stmt.Text[leadingComments(stmt.Text):])[1])
+	}
+	if want := []string{"TABLE", "PROCEDURE", "TABLE"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statements = %v, want %v", got, want)
+	}
+	if stmts, errs := parseStatements(up, "m.sql"); len(errs) != 0 || len(stmts) != 3 {
+		t.Errorf("parseStatements() = %d statements, errors %v; want 3, none", len(stmts), errs)
+	}
+}
+
+func TestLoadMigrationsOrder(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"V10__c.sql":     "SELECT 1",
+		"V2__b.sql":      "SELECT 1",
+		"V1_1__a.sql":    "SELECT 1",
+		"R__views.sql":   "SELECT 1",
+		"U2__b.sql":      "SELECT 1",
+		"sub/V3__d.sql":  "SELECT 1",
+		"README.md":      "not a migration",
+		"V1_1_0__x.sql":  "SELECT 1",
+		"seed/dev.sql":   "SELECT 1",
+		"R__aaa_fix.sql": "SELECT 1",
+	})
+
+	migrations, findings, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	var got []string
+	for _, m := range migrations {
+		got = append(got, strings.TrimPrefix(filepath.ToSlash(m.Path), filepath.ToSlash(dir)+"/"))
+	}
+	want := []string{"V1_1_0__x.sql", "V1_1__a.sql", "V2__b.sql", "sub/V3__d.sql", "V10__c.sql", "R__aaa_fix.sql", "R__views.sql"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule+" "+filepath.Base(f.File))
+	}
+	wantRules := []string{"migration-name dev.sql", "duplicate-version V1_1__a.sql"}
+	if !reflect.DeepEqual(rules, wantRules) {
+		t.Errorf("findings = %v, want %v", rules, wantRules)
+	}
+}
+
+func TestCheckMigrations(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"000001_users.up.sql":   "CREATE TABLE users (\n  id INT PRIMARY KEY,\n  email VARCHAR(255)\n);",
+		"000001_users.down.sql": "DROP TABLE users;",
+		"000002_orders.up.sql": "CREATE TABLE orders (id BIGINT PRIMARY KEY, user_id INT);\n" +
+			"INSERT INTO orders (id, user_id, status) VALUES (1, 1, 'new');\n" +
+			"SELECT * FROM invoices;\n" +
+			"SELECT nickname FROM users",
+		"000003_status.up.sql":   "ALTER TABLE orders ADD COLUMN status VARCHAR(16);\nCREATE TABLE users (id INT);\nCREATE TABLE IF NOT EXISTS orders (id INT)",
+		"000004_invoices.up.sql": "CREATE TABLE invoices (id INT);\nDROP TABLE users",
+		"000005_cleanup.up.sql":  "UPDATE users SET email = NULL;\nSELECT * FROM users u JOIN orders o ON o.user_id = u.id",
+		"000006_recreate.up.sql": "CREATE TABLE users (id INT);\nSELECT id FROM users",
+	})
+
+	report, err := CheckMigrations(dir, newSchemaCatalog())
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	var got []string
+	for _, f := range report.SchemaErrors {
+		got = append(got, strings.ReplaceAll(f.String(), dir+string(filepath.Separator), ""))
+	}
+	want := []string{
+		"000002_orders.up.sql:2:34: error: unknown column status in orders; it's created later, at 000003_status.up.sql:1 [created-later] (statement 2)",
+		"000002_orders.up.sql:3:15: error: unknown table invoices; it's created later, at 000004_invoices.up.sql:1 [created-later] (statement 3)",
+		"000002_orders.up.sql:4:8: error: unknown column nickname in users [unknown-column] (statement 4)",
+		"000003_status.up.sql:2:14: error: table users already exists: created at 000001_users.up.sql:1 [table-exists] (statement 2)",
+		"000004_invoices.up.sql:2:12: error: table users is dropped but later migrations still use it: 000005_cleanup.up.sql:1, 000005_cleanup.up.sql:2 [dropped-table-referenced] (statement 2)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SchemaErrors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if report.Valid || report.Statements != 14 || len(report.Migrations) != 6 {
+		t.Errorf("report = valid %v, %d statements, %d migrations; want invalid, 14, 6", report.Valid, report.Statements, len(report.Migrations))
+	}
+	if got, want := checkSummary(report), "5 schema errors in 3 migrations"; got != want {
+		t.Errorf("checkSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckMigrationsWithSchema(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"20240101000000_orders.sql": "-- +goose Up\nCREATE TABLE orders (id INT, user_id INT);\n" +
+			"SELECT o.id FROM orders o JOIN users u ON u.id = o.user_id;\n" +
+			"-- +goose Down\nDROP TABLE orders;\n",
+		"20240102000000_audit.sql": "-- +goose Up\nCREATE TABLE audit (id INT);\n",
+	})
+
+	report, err := CheckMigrations(dir, testCatalog(t, "CREATE TABLE users (id INT); CREATE TABLE audit (id INT);"))
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	if len(report.SchemaErrors) != 1 || report.SchemaErrors[0].Message != "table audit already exists: the --schema files define it" {
+		t.Fatalf("SchemaErrors = %+v, want audit already existing", report.SchemaErrors)
+	}
+
+	report, err = CheckMigrations(dir, testCatalog(t, "CREATE TABLE users (id INT);"))
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeCheckReport(&buf, report, "text"); err != nil {
+		t.Fatalf("writeCheckReport() error = %v", err)
+	}
+	if want := "✓ 2 migrations replay with valid SQL syntax and schema references\n"; buf.String() != want {
+		t.Errorf("writeCheckReport() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ddlSchema = `
+CREATE TABLE orders (
+  id BIGINT PRIMARY KEY,
+  user_id INT NOT NULL,
+  status VARCHAR(16),
+  code VARCHAR(60) CHARACTER SET latin1,
+  kind ENUM('a', 'b'),
+  total DECIMAL(10, 2),
+  doubled DECIMAL(10, 2) AS (total * 2) STORED,
+  KEY idx_user (user_id)
+) DEFAULT CHARSET = utf8mb4;
+`
+
+func TestAnalyseDDL(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		// want is the statement's algorithm, lock and rebuild flag
+		want    string
+		wantErr string
+	}{
+		{"add column", "ALTER TABLE orders ADD COLUMN note TEXT", "INSTANT NONE false", ""},
+		{"add column next to an index", "ALTER TABLE orders ADD COLUMN note TEXT, ADD INDEX idx_status (status)", "INPLACE NONE true", ""},
+		{"add index", "ALTER TABLE orders ADD INDEX idx_status (status)", "INPLACE NONE false", ""},
+		{"create and drop index", "CREATE INDEX idx_status ON orders (status); DROP INDEX idx_status ON orders", "INPLACE NONE false", ""},
+		{"add stored generated column", "ALTER TABLE orders ADD COLUMN t2 INT AS (id * 2) STORED", "COPY SHARED true", ""},
+		{"add virtual generated column", "ALTER TABLE orders ADD COLUMN t2 INT AS (id * 2) VIRTUAL", "INSTANT NONE false", ""},
+		{"add auto increment column", "ALTER TABLE orders ADD COLUMN seq INT AUTO_INCREMENT UNIQUE", "INPLACE SHARED true", ""},
+		{"drop column", "ALTER TABLE orders DROP COLUMN status", "INSTANT NONE false", ""},
+		{"drop stored generated column", "ALTER TABLE orders DROP COLUMN doubled", "INPLACE NONE true", ""},
+		{"rename and defaults", "ALTER TABLE orders RENAME COLUMN status TO state, ALTER COLUMN total SET DEFAULT 0, RENAME INDEX idx_user TO idx_u", "INSTANT NONE false", ""},
+		{"widen varchar", "ALTER TABLE orders MODIFY status VARCHAR(63)", "INPLACE NONE false", ""},
+		{"widen varchar past 255 bytes", "ALTER TABLE orders MODIFY status VARCHAR(64)", "COPY SHARED true", ""},
+		{"widen latin1 varchar", "ALTER TABLE orders MODIFY code VARCHAR(255) CHARACTER SET latin1", "INPLACE NONE false", ""},
+		{"shorten varchar", "ALTER TABLE orders MODIFY status VARCHAR(8)", "COPY SHARED true", ""},
+		{"change collation", "ALTER TABLE orders MODIFY status VARCHAR(16) COLLATE utf8mb4_bin", "COPY SHARED true", ""},
+		{"rename with change", "ALTER TABLE orders CHANGE status state VARCHAR(16)", "INSTANT NONE false", ""},
+		{"make not null", "ALTER TABLE orders MODIFY status VARCHAR(16) NOT NULL", "INPLACE NONE true", ""},
+		{"reorder", "ALTER TABLE orders MODIFY status VARCHAR(16) FIRST", "INPLACE NONE true", ""},
+		{"change type", "ALTER TABLE orders MODIFY user_id BIGINT NOT NULL", "COPY SHARED true", ""},
+		{"integer display width", "ALTER TABLE orders MODIFY user_id INT(11) NOT NULL", "INSTANT NONE false", ""},
+		{"append enum member", "ALTER TABLE orders MODIFY kind ENUM('a', 'b', 'c')", "INSTANT NONE false", ""},
+		{"reorder enum members", "ALTER TABLE orders MODIFY kind ENUM('b', 'a')", "COPY SHARED true", ""},
+		{"unknown column", "ALTER TABLE audit MODIFY note TEXT", "COPY SHARED true", ""},
+		{"add primary key", "ALTER TABLE orders DROP PRIMARY KEY, ADD PRIMARY KEY (id, user_id)", "INPLACE NONE true", ""},
+		{"drop primary key", "ALTER TABLE orders DROP PRIMARY KEY", "COPY SHARED true", ""},
+		{"foreign key", "ALTER TABLE orders ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id)", "COPY SHARED true", ""},
+		{"enforced check", "ALTER TABLE orders ADD CONSTRAINT chk CHECK (total >= 0)", "COPY SHARED true", ""},
+		{"check not enforced", "ALTER TABLE orders ADD CONSTRAINT chk CHECK (total >= 0) NOT ENFORCED, ALTER CHECK chk NOT ENFORCED", "INPLACE NONE false", ""},
+		{"fulltext", "ALTER TABLE orders ADD FULLTEXT INDEX ft_status (status)", "INPLACE SHARED true", ""},
+		{"force", "ALTER TABLE orders FORCE", "INPLACE NONE true", ""},
+		{"engine", "ALTER TABLE orders ENGINE = MyISAM", "COPY SHARED true", ""},
+		{"table options", "ALTER TABLE orders AUTO_INCREMENT = 100, COMMENT = 'orders'", "INPLACE NONE false", ""},
+		{"convert charset", "ALTER TABLE orders CONVERT TO CHARACTER SET utf8mb4", "COPY SHARED true", ""},
+		{"instant requested", "ALTER TABLE orders ADD COLUMN note TEXT, ALGORITHM=INSTANT", "INSTANT NONE false", ""},
+		{"instant rejected", "ALTER TABLE orders ADD INDEX idx_status (status), ALGORITHM=INSTANT", "INPLACE NONE false",
+			"ALGORITHM=INSTANT fails: ADD INDEX idx_status(status) can't run INSTANT"},
+		{"inplace forces a rebuild", "ALTER TABLE orders ADD COLUMN note TEXT, ALGORITHM=INPLACE", "INPLACE NONE true", ""},
+		{"inplace rejected", "ALTER TABLE orders MODIFY user_id BIGINT, ALGORITHM=INPLACE", "COPY SHARED true",
+			"ALGORITHM=INPLACE fails: MODIFY COLUMN user_id BIGINT needs COPY"},
+		{"copy requested", "ALTER TABLE orders ADD INDEX idx_status (status), ALGORITHM=COPY", "COPY SHARED true", ""},
+		{"lock none rejected", "CREATE FULLTEXT INDEX ft_status ON orders (status) LOCK=NONE", "INPLACE SHARED true",
+			"LOCK=NONE fails: the statement needs LOCK=SHARED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := AnalyseDDL(tt.sql, "", testCatalog(t, ddlSchema))
+			if len(report.Analyses) == 0 {
+				t.Fatalf("AnalyseDDL(%q) found no DDL", tt.sql)
+			}
+			a := report.Analyses[len(report.Analyses)-1]
+			got := fmt.Sprintf("%s %s %v", a.Algorithm, a.Lock, a.Rebuild)
+			if got != tt.want || a.Error != tt.wantErr {
+				t.Errorf("AnalyseDDL(%q) = %s, error %q, want %s, error %q\noperations: %+v", tt.sql, got, a.Error, tt.want, tt.wantErr, a.Operations)
+			}
+		})
+	}
+}
+
+func TestAnalyseDDLAdvice(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"instant only", "ALTER TABLE orders ADD COLUMN note TEXT, DROP COLUMN status", nil},
+		{"mixed algorithms", "ALTER TABLE orders ADD COLUMN note TEXT, ADD INDEX idx_status (status)", []string{
+			"split ADD COLUMN note TEXT (INSTANT) into a separate ALTER TABLE: next to ADD INDEX idx_status(status) it runs INPLACE and rebuilds the table",
+			"rebuilding orders takes time and I/O in proportion to its size, and replicas lag while they apply it: on a large table use an online schema change tool such as gh-ost or pt-online-schema-change",
+		}},
+		{"in place without a rebuild", "ALTER TABLE orders RENAME COLUMN status TO state, ADD INDEX idx_status (status)", nil},
+		{"copy", "ALTER TABLE orders ADD INDEX idx_status (status), MODIFY user_id BIGINT", []string{
+			"split ADD INDEX idx_status(status) (INPLACE) into a separate ALTER TABLE: next to MODIFY COLUMN user_id BIGINT it runs COPY",
+			"COPY rebuilds orders and blocks writes until it's done: on a large table use an online schema change tool such as gh-ost or pt-online-schema-change",
+		}},
+		{"rebuilding operations stay together", "ALTER TABLE orders FORCE, MODIFY user_id BIGINT", []string{
+			"COPY rebuilds orders and blocks writes until it's done: on a large table use an online schema change tool such as gh-ost or pt-online-schema-change",
+		}},
+		{"needless copy", "ALTER TABLE orders RENAME COLUMN status TO state, ALGORITHM=COPY", []string{
+			"ALGORITHM=COPY copies the table although INSTANT would do; drop the clause",
+			"COPY rebuilds orders and blocks writes until it's done: on a large table use an online schema change tool such as gh-ost or pt-online-schema-change",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := AnalyseDDL(tt.sql, "", testCatalog(t, ddlSchema))
+			got := report.Analyses[0].Advice
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Advice =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestAnalyseDDLAppliesDDL(t *testing.T) {
+	sql := "CREATE TABLE audit (id INT PRIMARY KEY, note VARCHAR(10));\n" +
+		"ALTER TABLE audit MODIFY note VARCHAR(20);\n" +
+		"ALTER TABLE audit ADD COLUMN at DATETIME, ADD INDEX idx_at (at);\n" +
+		"ALTER TABLE audit MODIFY note VARCHAR(20) NOT NULL"
+
+	report := AnalyseDDL(sql, "m.sql", newSchemaCatalog())
+	if len(report.Analyses) != 3 {
+		t.Fatalf("Analyses = %+v, want 3 statements", report.Analyses)
+	}
+	if a := report.Analyses[0]; a.Statement != 2 || a.Line != 2 || a.Algorithm != "INPLACE" || a.Rebuild {
+		t.Errorf("statement 2 = %+v, want an in-place VARCHAR widening on line 2", a)
+	}
+	want := []string{"statements 3, 4 each rebuild audit; combine the operations that rebuild it into one ALTER TABLE so the table is rebuilt once"}
+	if !reflect.DeepEqual(report.Advice, want) {
+		t.Errorf("Advice = %q, want %q", report.Advice, want)
+	}
+}
+
+func TestWriteDDLReport(t *testing.T) {
+	sql := "SELECT 1;\n" +
+		"ALTER TABLE orders ADD COLUMN note TEXT, ADD INDEX idx_status (status), ALGORITHM=INSTANT"
+	report := AnalyseDDL(sql, "m.sql", testCatalog(t, ddlSchema))
+
+	var buf bytes.Buffer
+	if err := writeDDLReport(&buf, report, "text"); err != nil {
+		t.Fatalf("writeDDLReport() error = %v", err)
+	}
+	want := "m.sql:2: statement 2: orders runs ALGORITHM=INPLACE, LOCK=NONE, rebuilds the table\n" +
+		"  INSTANT  LOCK=NONE    ADD COLUMN note TEXT\n" +
+		"  INPLACE  LOCK=NONE    ADD INDEX idx_status(status)\n" +
+		"  error: ALGORITHM=INSTANT fails: ADD INDEX idx_status(status) can't run INSTANT\n" +
+		"  advice: split ADD COLUMN note TEXT (INSTANT) into a separate ALTER TABLE: next to ADD INDEX idx_status(status) it runs INPLACE and rebuilds the table\n" +
+		"  advice: rebuilding orders takes time and I/O in proportion to its size, and replicas lag while they apply it: on a large table use an online schema change tool such as gh-ost or pt-online-schema-change\n"
+	if buf.String() != want {
+		t.Errorf("writeDDLReport() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := writeDDLReport(&buf, report, "json"); err != nil {
+		t.Fatalf("writeDDLReport() error = %v", err)
+	}
+	for _, want := range []string{`"statements": 2`, `"algorithm": "INSTANT"`, `"rebuild": true`, `"error": "ALGORITHM=INSTANT fails`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("json output missing %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDDLSummary(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"ALTER TABLE orders ADD COLUMN note TEXT; CREATE INDEX idx_status ON orders (status)", "✓ 2 DDL statements run without rebuilding a table"},
+		{"SELECT 1", "✓ 0 DDL statements run without rebuilding a table"},
+		{"ALTER TABLE orders FORCE; DROP INDEX idx_user ON orders", "1 of 2 DDL statements rebuilds the table"},
+	}
+	for _, tt := range tests {
+		if got := ddlSummary(AnalyseDDL(tt.sql, "", testCatalog(t, ddlSchema))); got != tt.want {
+			t.Errorf("ddlSummary(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
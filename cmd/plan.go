@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// statementPlan is the set of per-table dumps derived from one statement
+type statementPlan struct {
+	Index  int
+	Text   string
+	Action string
+	Tables []string
+	Where  string
+	// OrderBy and Limit bound the rows an UPDATE, DELETE or SELECT touches
+	OrderBy string
+	Limit   string
+	Preset  string
+	Dumps   []tableDump
+	// Statements lists the source statements of a merged plan
+	Statements []int
+	// Reason explains why these dumps were chosen for the statement
+	Reason string
+	// Queries save extra results next to the dumps
+	Queries []planQuery
+}
+
+// planQuery is a query whose result is saved alongside a plan's dumps, such
+// as the values of a column about to be dropped
+type planQuery struct {
+	Table string
+	// Name tells the output files of one table apart
+	Name  string
+	Query string
+	// Definition marks SHOW CREATE queries, saved as the bare statement
+	Definition bool
+}
+
+// dumpSettings carries everything renderers need besides the plan itself
+type dumpSettings struct {
+	// ConnOpts are the mysql/mysqldump credential and connection options
+	ConnOpts string
+	Conn     *connOptions
+	Creds    *credentials
+	Database string
+	Notes    []string
+	// ExtraOpts are appended to every mysqldump after the preset options
+	ExtraOpts            []string
+	SkipColumnStatistics bool
+	Compression          string
+	// ChunkSize splits data dumps into key ranges of this many rows (0: off)
+	ChunkSize int
+	// Keys tells chunked dumps which key column to range over
+	Keys *keyCatalog
+	// RemoveDefaultsFile makes scripts delete the credentials' temporary
+	// defaults file when they exit
+	RemoveDefaultsFile bool
+}
+
+// MysqldumpOpts returns the mysqldump options for a statement's dumps
+func (s dumpSettings) MysqldumpOpts(plan statementPlan) string {
+	return mysqldumpOpts(plan.Preset, s.ExtraOpts, s.SkipColumnStatistics)
+}
+
+// tableDump is a single filtered dump of one or more tables
+type tableDump struct {
+	Table  string
+	Filter string
+	// IDQuery selects the exact affected ids when Filter can only match a
+	// superset of the rows touched by a multi-table UPDATE/DELETE
+	IDQuery string
+	// Key lists the key columns IDQuery selects
+	Key []string
+	// Also lists further tables dumped by the same command with the same
+	// filter
+	Also []string
+	// Superset is set when Filter matches more rows than the statement
+	// touches and no IDQuery can narrow them down
+	Superset bool
+}
+
+// AllTables returns every table the dump covers
+func (d tableDump) AllTables() []string {
+	return append([]string{d.Table}, d.Also...)
+}
+
+// Name identifies the dump in output file names
+func (d tableDump) Name() string {
+	return strings.Join(d.AllTables(), "+")
+}
+
+// exactFilter returns the condition matching the rows IDQuery selects
+func (d tableDump) exactFilter() string {
+	return fmt.Sprintf("%s IN (SELECT %s FROM (%s) AS exact_ids)", keyTuple(d.Key), quoteColumns(d.Key), d.IDQuery)
+}
+
+// idListQuery selects the keys IDQuery finds as SQL literals, one row each,
+// ready to be joined into the IN list of --where
+func (d tableDump) idListQuery() string {
+	columns := make([]string, len(d.Key))
+	for i, col := range d.Key {
+		columns[i] = quoteIdent(col)
+	}
+	return fmt.Sprintf("SELECT %s FROM (%s) AS exact_ids", keyLiteral(columns), d.IDQuery)
+}
+
+// keyLiteral returns an expression rendering key columns as one SQL
+// literal, a tuple for composite keys
+func keyLiteral(columns []string) string {
+	values := make([]string, len(columns))
+	for i, col := range columns {
+		values[i] = "QUOTE(" + col + ")"
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return "CONCAT('(', " + strings.Join(values, ", ',', ") + ", ')')"
+}
+
+// keyTuple returns the quoted key column, or a tuple of them for composite
+// keys
+func keyTuple(key []string) string {
+	if len(key) == 1 {
+		return quoteIdent(key[0])
+	}
+	return "(" + quoteColumns(key) + ")"
+}
+
+// quoteColumns backquotes and joins column names
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// buildDumpPlan computes the tables and per-table filters to dump for each
+// statement. Exact ids are captured by the keys catalog knows.
+func buildDumpPlan(stmtNodes []ast.StmtNode, catalog *keyCatalog) []statementPlan {
+	plans := make([]statementPlan, 0, len(stmtNodes))
+
+	for idx, stmtNode := range stmtNodes {
+		_, tableNames, action, whereFilter, primaryTable := Extract(&stmtNode)
+
+		plan := statementPlan{
+			Index:  idx + 1,
+			Text:   strings.TrimSpace(stmtNode.Text()),
+			Action: action,
+			Tables: tableNames,
+			Where:  whereFilter,
+			Preset: defaultPreset(action),
+		}
+
+		// For UPDATE/DELETE, only dump the primary table
+		tablesToDump := tableNames
+		if (action == "UPDATE" || action == "DELETE") && primaryTable != "" {
+			tablesToDump = []string{primaryTable}
+		}
+
+		for _, tableName := range tablesToDump {
+			dump := tableDump{
+				Table:  tableName,
+				Filter: FilterWhereForTable(whereFilter, tableName, tableNames),
+			}
+
+			// Cross-table conditions can't be expressed in --where, so
+			// capture the exact ids through the original joins instead
+			if (action == "UPDATE" || action == "DELETE") && tableName == primaryTable && len(tableNames) > 1 {
+				allConditionsFilter := whereFilter
+				for _, tbl := range tableNames {
+					allConditionsFilter = strings.ReplaceAll(allConditionsFilter, tbl+".", "")
+				}
+				if allConditionsFilter != dump.Filter {
+					if key := catalog.primaryKey(tableName); key == nil {
+						dump.Superset = true
+						plan.Reason = "superset dump: " + noKeyReason(tableName)
+					} else if dump.IDQuery = exactIDQuery(stmtNode, tableName, key); dump.IDQuery != "" {
+						dump.Key = key
+					}
+				}
+			}
+
+			plan.Dumps = append(plan.Dumps, dump)
+		}
+
+		plan.OrderBy, plan.Limit = ExtractBounds(stmtNode)
+		if plan.Limit != "" && len(plan.Dumps) > 0 {
+			boundDumps(&plan, stmtNode, catalog)
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans
+}
+
+// exactIDQuery builds a SELECT of the primary table's key using the
+// statement's own table references and WHERE clause
+func exactIDQuery(stmtNode ast.StmtNode, table string, key []string) string {
+	var refs *ast.TableRefsClause
+	var where ast.ExprNode
+
+	switch stmt := stmtNode.(type) {
+	case *ast.UpdateStmt:
+		refs, where = stmt.TableRefs, stmt.Where
+	case *ast.DeleteStmt:
+		refs, where = stmt.TableRefs, stmt.Where
+	}
+	if refs == nil || refs.TableRefs == nil {
+		return ""
+	}
+
+	qualifier := quoteTableName(table)
+	if alias := findTableAlias(refs.TableRefs, table); alias != "" {
+		qualifier = quoteIdent(alias)
+	}
+	columns := make([]string, len(key))
+	for i, col := range key {
+		columns[i] = qualifier + "." + quoteIdent(col)
+	}
+
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s", strings.Join(columns, ", "), restoreSQL(refs.TableRefs))
+	if where != nil {
+		query += " WHERE " + restoreSQL(where)
+	}
+	return query
+}
+
+// boundDumps narrows the dump of a statement with a LIMIT to the ids its
+// ORDER BY and LIMIT select, captured before the statement runs. When those
+// rows can't be captured the dumps are flagged as supersets instead.
+func boundDumps(plan *statementPlan, stmtNode ast.StmtNode, catalog *keyCatalog) {
+	reason := ""
+	switch {
+	case len(plan.Tables) != 1 || len(plan.Dumps) != 1:
+		reason = "ORDER BY/LIMIT over joined tables can't be captured per table"
+	case plan.OrderBy == "":
+		reason = "LIMIT without ORDER BY picks unspecified rows"
+	default:
+		if stmt, ok := stmtNode.(*ast.SelectStmt); ok {
+			if groupsRows(stmt) {
+				reason = "LIMIT applies to grouped rows"
+			} else if orderUsesSelectList(stmt) {
+				reason = "ORDER BY refers to the select list"
+			}
+		}
+	}
+
+	var key []string
+	if reason == "" {
+		if key = catalog.primaryKey(plan.Dumps[0].Table); key == nil {
+			reason = noKeyReason(plan.Dumps[0].Table)
+		}
+	}
+	idQuery := ""
+	if reason == "" {
+		if idQuery = boundedIDQuery(stmtNode, key); idQuery == "" {
+			reason = "no table to capture the ids from"
+		}
+	}
+	if reason != "" {
+		for i := range plan.Dumps {
+			plan.Dumps[i].Superset = true
+		}
+		plan.Reason = "superset dump: " + reason
+		return
+	}
+
+	plan.Dumps[0].IDQuery = idQuery
+	plan.Dumps[0].Key = key
+	plan.Reason = fmt.Sprintf("exact dump: the ids within ORDER BY %s LIMIT %s are captured first", plan.OrderBy, plan.Limit)
+}
+
+// boundedIDQuery builds a SELECT of the key a single-table statement's
+// WHERE, ORDER BY and LIMIT select
+func boundedIDQuery(stmtNode ast.StmtNode, key []string) string {
+	var refs *ast.TableRefsClause
+	var where ast.ExprNode
+	var order *ast.OrderByClause
+	var limit *ast.Limit
+
+	switch stmt := stmtNode.(type) {
+	case *ast.UpdateStmt:
+		refs, where, order, limit = stmt.TableRefs, stmt.Where, stmt.Order, stmt.Limit
+	case *ast.DeleteStmt:
+		refs, where, order, limit = stmt.TableRefs, stmt.Where, stmt.Order, stmt.Limit
+	case *ast.SelectStmt:
+		refs, where, order, limit = stmt.From, stmt.Where, stmt.OrderBy, stmt.Limit
+	}
+	if refs == nil || refs.TableRefs == nil || order == nil || limit == nil {
+		return ""
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", quoteColumns(key), restoreSQL(refs.TableRefs))
+	if where != nil {
+		query += " WHERE " + restoreSQL(where)
+	}
+	return query + " " + restoreSQL(order) + " " + restoreSQL(limit)
+}
+
+// groupsRows reports whether a SELECT's LIMIT counts grouped, distinct or
+// aggregated rows rather than table rows
+func groupsRows(stmt *ast.SelectStmt) bool {
+	if stmt.GroupBy != nil || stmt.Having != nil || stmt.Distinct {
+		return true
+	}
+	if stmt.Fields == nil {
+		return false
+	}
+	finder := &aggregateFinder{}
+	for _, field := range stmt.Fields.Fields {
+		if field.Expr != nil {
+			field.Expr.Accept(finder)
+		}
+	}
+	return finder.found
+}
+
+// aggregateFinder looks for aggregate and window functions
+type aggregateFinder struct {
+	found bool
+}
+
+func (f *aggregateFinder) Enter(in ast.Node) (ast.Node, bool) {
+	switch in.(type) {
+	case *ast.AggregateFuncExpr, *ast.WindowFuncExpr:
+		f.found = true
+		return in, true
+	case *ast.SubqueryExpr:
+		return in, true
+	}
+	return in, false
+}
+
+func (f *aggregateFinder) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// orderUsesSelectList reports whether a SELECT orders by a column position
+// or a select-list alias, which a capture query selecting only ids lacks
+func orderUsesSelectList(stmt *ast.SelectStmt) bool {
+	if stmt.OrderBy == nil {
+		return false
+	}
+	aliases := make(map[string]bool)
+	if stmt.Fields != nil {
+		for _, field := range stmt.Fields.Fields {
+			if field.AsName.L != "" {
+				aliases[field.AsName.L] = true
+			}
+		}
+	}
+	for _, item := range stmt.OrderBy.Items {
+		switch e := item.Expr.(type) {
+		case *ast.PositionExpr:
+			return true
+		case *ast.ColumnNameExpr:
+			if e.Name.Table.L == "" && aliases[e.Name.Name.L] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findTableAlias returns the alias given to table in a join tree, if any
+func findTableAlias(node ast.ResultSetNode, table string) string {
+	switch n := node.(type) {
+	case *ast.Join:
+		if alias := findTableAlias(n.Left, table); alias != "" {
+			return alias
+		}
+		if n.Right != nil {
+			return findTableAlias(n.Right, table)
+		}
+	case *ast.TableSource:
+		if tn, ok := n.Source.(*ast.TableName); ok && tn.Name.O == table {
+			return n.AsName.O
+		}
+	}
+	return ""
+}
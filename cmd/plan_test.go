@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testKeyCatalog returns a key catalog holding the given --pk mappings
+func testKeyCatalog(t *testing.T, mappings ...string) *keyCatalog {
+	t.Helper()
+	catalog, err := loadKeyCatalog("", mappings)
+	if err != nil {
+		t.Fatalf("loadKeyCatalog() error = %v", err)
+	}
+	return catalog
+}
+
+func TestBuildDumpPlan(t *testing.T) {
+	catalog := testKeyCatalog(t, "employees=employee_id", "users=id", "stock=warehouse_id,sku")
+	tests := []struct {
+		name       string
+		sql        string
+		wantDumps  [][]tableDump
+		wantReason string
+	}{
+		{
+			name: "SELECT with JOIN dumps every table",
+			sql:  "SELECT * FROM users u JOIN orders o ON u.id = o.user_id WHERE u.status = 'active'",
+			wantDumps: [][]tableDump{{
+				{Table: "users", Filter: "status='active'"},
+				{Table: "orders"},
+			}},
+		},
+		{
+			name: "UPDATE with JOIN captures exact ids",
+			sql:  "UPDATE Employees e JOIN Departments d ON e.DepartmentID = d.DepartmentID SET e.Salary = 1 WHERE d.DepartmentName = 'Sales' AND e.YearsOfService >= 5",
+			wantDumps: [][]tableDump{{
+				{
+					Table:   "Employees",
+					Filter:  "YearsOfService>=5",
+					IDQuery: "SELECT DISTINCT `e`.`employee_id` FROM `Employees` AS `e` JOIN `Departments` AS `d` ON `e`.`DepartmentID`=`d`.`DepartmentID` WHERE `d`.`DepartmentName`='Sales' AND `e`.`YearsOfService`>=5",
+					Key:     []string{"employee_id"},
+				},
+			}},
+		},
+		{
+			name: "DELETE with JOIN and no own conditions",
+			sql:  "DELETE users FROM users JOIN bans ON users.id = bans.user_id WHERE bans.active = 1",
+			wantDumps: [][]tableDump{{
+				{
+					Table:   "users",
+					IDQuery: "SELECT DISTINCT `users`.`id` FROM `users` JOIN `bans` ON `users`.`id`=`bans`.`user_id` WHERE `bans`.`active`=1",
+					Key:     []string{"id"},
+				},
+			}},
+		},
+		{
+			name: "Composite key",
+			sql:  "DELETE s FROM stock s JOIN warehouses w ON w.id = s.warehouse_id WHERE w.closed = 1",
+			wantDumps: [][]tableDump{{
+				{
+					Table:   "stock",
+					IDQuery: "SELECT DISTINCT `s`.`warehouse_id`, `s`.`sku` FROM `stock` AS `s` JOIN `warehouses` AS `w` ON `w`.`id`=`s`.`warehouse_id` WHERE `w`.`closed`=1",
+					Key:     []string{"warehouse_id", "sku"},
+				},
+			}},
+		},
+		{
+			name:       "No known key falls back to a superset",
+			sql:        "DELETE a FROM audit a JOIN users u ON u.id = a.user_id WHERE u.banned = 1",
+			wantDumps:  [][]tableDump{{{Table: "audit", Superset: true}}},
+			wantReason: "superset dump: no primary or unique key known for audit (use --pk or --schema)",
+		},
+		{
+			name:      "Multiple statements, one without tables",
+			sql:       "DELETE FROM logs WHERE id = 1; CREATE DATABASE x",
+			wantDumps: [][]tableDump{{{Table: "logs", Filter: "id=1"}}, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+
+			plans := buildDumpPlan(stmtNodes, catalog)
+			var got [][]tableDump
+			for i, plan := range plans {
+				if plan.Index != i+1 {
+					t.Errorf("plan[%d].Index = %d, want %d", i, plan.Index, i+1)
+				}
+				got = append(got, plan.Dumps)
+			}
+			if plans[0].Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", plans[0].Reason, tt.wantReason)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantDumps) {
+				t.Errorf("buildDumpPlan() dumps = %+v, want %+v", got, tt.wantDumps)
+			}
+		})
+	}
+}
+
+func TestBuildDumpPlanBounds(t *testing.T) {
+	catalog := testKeyCatalog(t, "logs=id", "products=sku", "order=key", "order_items=id")
+	tests := []struct {
+		name       string
+		sql        string
+		wantDump   tableDump
+		wantReason string
+	}{
+		{
+			name: "DELETE with ORDER BY and LIMIT captures the bounded ids",
+			sql:  "DELETE FROM logs WHERE created < '2024-01-01' ORDER BY id LIMIT 1000",
+			wantDump: tableDump{
+				Table:   "logs",
+				Filter:  "created<'2024-01-01'",
+				IDQuery: "SELECT `id` FROM `logs` WHERE `created`<'2024-01-01' ORDER BY `id` LIMIT 1000",
+				Key:     []string{"id"},
+			},
+			wantReason: "exact dump: the ids within ORDER BY id LIMIT 1000 are captured first",
+		},
+		{
+			name:       "SELECT with an offset",
+			sql:        "SELECT * FROM products p ORDER BY p.price DESC, p.id LIMIT 20, 10",
+			wantDump:   tableDump{Table: "products", IDQuery: "SELECT `sku` FROM `products` AS `p` ORDER BY `p`.`price` DESC,`p`.`id` LIMIT 20,10", Key: []string{"sku"}},
+			wantReason: "exact dump: the ids within ORDER BY p.price DESC, p.id LIMIT 20,10 are captured first",
+		},
+		{
+			name:       "Reserved names",
+			sql:        "DELETE FROM `order` WHERE state = 'x' ORDER BY `key` LIMIT 5",
+			wantDump:   tableDump{Table: "order", Filter: "state='x'", IDQuery: "SELECT `key` FROM `order` WHERE `state`='x' ORDER BY `key` LIMIT 5", Key: []string{"key"}},
+			wantReason: "exact dump: the ids within ORDER BY key LIMIT 5 are captured first",
+		},
+		{
+			name:       "No known key",
+			sql:        "DELETE FROM jobs WHERE state = 'stuck' ORDER BY id LIMIT 5",
+			wantDump:   tableDump{Table: "jobs", Filter: "state='stuck'", Superset: true},
+			wantReason: "superset dump: no primary or unique key known for jobs (use --pk or --schema)",
+		},
+		{
+			name:       "LIMIT without ORDER BY",
+			sql:        "UPDATE jobs SET state = 'done' WHERE state = 'stuck' LIMIT 5",
+			wantDump:   tableDump{Table: "jobs", Filter: "state='stuck'", Superset: true},
+			wantReason: "superset dump: LIMIT without ORDER BY picks unspecified rows",
+		},
+		{
+			name:       "Grouped SELECT",
+			sql:        "SELECT user_id, COUNT(*) FROM orders GROUP BY user_id ORDER BY user_id LIMIT 3",
+			wantDump:   tableDump{Table: "orders", Superset: true},
+			wantReason: "superset dump: LIMIT applies to grouped rows",
+		},
+		{
+			name:       "ORDER BY a select-list alias",
+			sql:        "SELECT id, price * qty AS total FROM order_items ORDER BY total DESC LIMIT 5",
+			wantDump:   tableDump{Table: "order_items", Superset: true},
+			wantReason: "superset dump: ORDER BY refers to the select list",
+		},
+		{
+			name:       "ORDER BY without LIMIT",
+			sql:        "DELETE FROM logs WHERE id < 10 ORDER BY id",
+			wantDump:   tableDump{Table: "logs", Filter: "id<10"},
+			wantReason: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+
+			plan := buildDumpPlan(stmtNodes, catalog)[0]
+			if len(plan.Dumps) != 1 || !reflect.DeepEqual(plan.Dumps[0], tt.wantDump) {
+				t.Errorf("Dumps = %+v, want [%+v]", plan.Dumps, tt.wantDump)
+			}
+			if plan.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", plan.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestBuildDumpPlanBoundsOverJoins(t *testing.T) {
+	stmtNodes, err := ParseAll("SELECT * FROM users u JOIN orders o ON u.id = o.user_id ORDER BY o.id LIMIT 10")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	plan := buildDumpPlan(stmtNodes, nil)[0]
+	for _, dump := range plan.Dumps {
+		if !dump.Superset || dump.IDQuery != "" {
+			t.Errorf("dump %+v should be a superset without id query", dump)
+		}
+	}
+	if want := "superset dump: ORDER BY/LIMIT over joined tables can't be captured per table"; plan.Reason != want {
+		t.Errorf("Reason = %q, want %q", plan.Reason, want)
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// preflightCheck holds the row-count queries for one planned dump
+type preflightCheck struct {
+	Statement int
+	Table     string
+	Filter    string
+	// CountQuery counts the rows the dump's --where selects
+	CountQuery string
+	// ExactQuery counts the rows a JOINed UPDATE/DELETE really touches,
+	// when that differs from the dump filter
+	ExactQuery string
+	// Widened is set when the statement had a WHERE clause but none of it
+	// applies to this table, so the dump reads the whole table
+	Widened bool
+}
+
+// preflightResult is a preflightCheck with its counts. Exact is -1 when
+// the check has no exact query.
+type preflightResult struct {
+	preflightCheck
+	Rows  int64
+	Exact int64
+}
+
+// preflightChecks builds the count queries for every planned dump
+func preflightChecks(plans []statementPlan) []preflightCheck {
+	var checks []preflightCheck
+	for _, plan := range plans {
+		for _, dump := range plan.Dumps {
+			for _, table := range dump.AllTables() {
+				check := preflightCheck{
+					Statement:  plan.Index,
+					Table:      table,
+					Filter:     dump.Filter,
+					CountQuery: "SELECT COUNT(*) FROM " + quoteTableName(table),
+					Widened:    dump.Filter == "" && plan.Where != "",
+				}
+				if dump.Filter != "" {
+					check.CountQuery += " WHERE " + dump.Filter
+				}
+				if dump.IDQuery != "" {
+					check.ExactQuery = fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS exact_ids", dump.IDQuery)
+				}
+				checks = append(checks, check)
+			}
+		}
+	}
+	return checks
+}
+
+// preflightCommands renders the checks as mysql client commands for running
+// by hand
+func preflightCommands(checks []preflightCheck, settings dumpSettings) []string {
+	var lines []string
+	for _, check := range checks {
+		lines = append(lines, fmt.Sprintf("# Statement %d: %s", check.Statement, check.Table))
+		if check.Widened {
+			lines = append(lines, fmt.Sprintf("# WARNING: no condition applies to %s, the dump reads the full table", check.Table))
+		}
+		lines = append(lines, fmt.Sprintf("mysql%s -N -e %s %s", settings.ConnOpts, shellQuote(check.CountQuery), settings.Database))
+		if check.ExactQuery != "" {
+			lines = append(lines, "# Exact rows touched by the statement:")
+			lines = append(lines, fmt.Sprintf("mysql%s -N -e %s %s", settings.ConnOpts, shellQuote(check.ExactQuery), settings.Database))
+		}
+	}
+	return lines
+}
+
+// runPreflight executes the count queries
+func runPreflight(ctx context.Context, db *sql.DB, checks []preflightCheck) ([]preflightResult, error) {
+	var results []preflightResult
+	for _, check := range checks {
+		r := preflightResult{preflightCheck: check, Exact: -1}
+		if err := db.QueryRowContext(ctx, check.CountQuery).Scan(&r.Rows); err != nil {
+			return results, fmt.Errorf("statement %d, table %s: %v", check.Statement, check.Table, err)
+		}
+		if check.ExactQuery != "" {
+			if err := db.QueryRowContext(ctx, check.ExactQuery).Scan(&r.Exact); err != nil {
+				return results, fmt.Errorf("statement %d, table %s: %v", check.Statement, check.Table, err)
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// writePreflightTable prints the estimated rows per dump followed by any
+// full-table-scan warnings
+func writePreflightTable(w io.Writer, results []preflightResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STMT\tTABLE\tROWS\tEXACT\tFILTER")
+	for _, r := range results {
+		exact := "-"
+		if r.Exact >= 0 {
+			exact = fmt.Sprint(r.Exact)
+		}
+		filter := r.Filter
+		if filter == "" {
+			filter = "(full table)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", r.Statement, r.Table, r.Rows, exact, filter)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Widened {
+			fmt.Fprintf(w, "WARNING: statement %d: no condition applies to %s, the dump reads all %d rows\n", r.Statement, r.Table, r.Rows)
+		}
+	}
+	return nil
+}
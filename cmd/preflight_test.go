@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPreflightChecks(t *testing.T) {
+	stmtNodes, err := ParseAll(`SELECT * FROM users u JOIN orders o ON u.id = o.user_id WHERE u.active = 1;
+UPDATE orders o JOIN users u ON o.user_id = u.id SET o.status = 'closed' WHERE u.name = 'ann';
+DELETE FROM ` + "`order`" + ` WHERE id > 5`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	got := preflightChecks(buildDumpPlan(stmtNodes, testKeyCatalog(t, "orders=id")))
+	want := []preflightCheck{
+		{Statement: 1, Table: "users", Filter: "active=1", CountQuery: "SELECT COUNT(*) FROM `users` WHERE active=1"},
+		{Statement: 1, Table: "orders", CountQuery: "SELECT COUNT(*) FROM `orders`", Widened: true},
+		{
+			Statement:  2,
+			Table:      "orders",
+			CountQuery: "SELECT COUNT(*) FROM `orders`",
+			ExactQuery: "SELECT COUNT(*) FROM (SELECT DISTINCT `o`.`id` FROM `orders` AS `o` JOIN `users` AS `u` ON `o`.`user_id`=`u`.`id` WHERE `u`.`name`='ann') AS exact_ids",
+			Widened:    true,
+		},
+		// Reserved names stay valid SQL
+		{Statement: 3, Table: "order", Filter: "id>5", CountQuery: "SELECT COUNT(*) FROM `order` WHERE id>5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("preflightChecks() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestPreflightCommands(t *testing.T) {
+	checks := []preflightCheck{
+		{Statement: 1, Table: "users", Filter: "id=1", CountQuery: "SELECT COUNT(*) FROM users WHERE id=1"},
+		{Statement: 2, Table: "orders", CountQuery: "SELECT COUNT(*) FROM orders", ExactQuery: "SELECT COUNT(*) FROM (SELECT 1) AS exact_ids", Widened: true},
+	}
+	got := preflightCommands(checks, dumpSettings{ConnOpts: " -u root", Database: "shop"})
+	want := []string{
+		"# Statement 1: users",
+		"mysql -u root -N -e 'SELECT COUNT(*) FROM users WHERE id=1' shop",
+		"# Statement 2: orders",
+		"# WARNING: no condition applies to orders, the dump reads the full table",
+		"mysql -u root -N -e 'SELECT COUNT(*) FROM orders' shop",
+		"# Exact rows touched by the statement:",
+		"mysql -u root -N -e 'SELECT COUNT(*) FROM (SELECT 1) AS exact_ids' shop",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("preflightCommands() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRunPreflight(t *testing.T) {
+	conn := startTestServer(t, "shop")
+	db, err := openDatabase(conn)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(50))",
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, status VARCHAR(20))",
+		"INSERT INTO users VALUES (1, 'ann'), (2, 'bob')",
+		"INSERT INTO orders VALUES (10, 1, 'open'), (11, 2, 'open'), (12, 1, 'done')",
+		"CREATE TABLE `order` (id INT PRIMARY KEY)",
+		"INSERT INTO `order` VALUES (1), (7)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("setup %q: %v", q, err)
+		}
+	}
+
+	stmtNodes, err := ParseAll(`DELETE FROM users WHERE id = 2;
+UPDATE orders o JOIN users u ON o.user_id = u.id SET o.status = 'closed' WHERE o.status = 'open' AND u.name = 'ann';
+DELETE FROM ` + "`order`" + ` WHERE id > 5`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	results, err := runPreflight(context.Background(), db, preflightChecks(buildDumpPlan(stmtNodes, testKeyCatalog(t, "orders=id"))))
+	if err != nil {
+		t.Fatalf("runPreflight() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writePreflightTable(&buf, results); err != nil {
+		t.Fatalf("writePreflightTable() error = %v", err)
+	}
+	want := `STMT  TABLE   ROWS  EXACT  FILTER
+1     users   1     -      id=2
+2     orders  2     1      status='open'
+3     order   1     -      id>5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("writePreflightTable() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWritePreflightTableWarnsOnFullScan(t *testing.T) {
+	results := []preflightResult{{
+		preflightCheck: preflightCheck{Statement: 3, Table: "orders", Widened: true},
+		Rows:           5000,
+		Exact:          12,
+	}}
+
+	var buf bytes.Buffer
+	if err := writePreflightTable(&buf, results); err != nil {
+		t.Fatalf("writePreflightTable() error = %v", err)
+	}
+	want := `STMT  TABLE   ROWS  EXACT  FILTER
+3     orders  5000  12     (full table)
+WARNING: statement 3: no condition applies to orders, the dump reads all 5000 rows
+`
+	if got := buf.String(); got != want {
+		t.Errorf("writePreflightTable() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mysqldumpPresets are named option sets for common backup situations
+var mysqldumpPresets = map[string][]string{
+	"none": nil,
+	// Data-only snapshot of the rows a DML statement is about to change
+	"pre-dml-backup": {"--single-transaction", "--no-create-info", "--skip-triggers", "--hex-blob", "--set-gtid-purged=OFF"},
+	// Table definition only, e.g. before an ALTER
+	"schema-only": {"--no-data", "--set-gtid-purged=OFF"},
+	// Schema and data from one consistent InnoDB snapshot
+	"consistent": {"--single-transaction", "--quick", "--hex-blob"},
+}
+
+// defaultPreset picks the preset used for an action when --preset is not set
+func defaultPreset(action string) string {
+	switch action {
+	case "UPDATE", "DELETE":
+		return "pre-dml-backup"
+	case "ALTER":
+		return "schema-only"
+	case "DROP", "TRUNCATE":
+		return "consistent"
+	}
+	return "none"
+}
+
+// validatePreset checks that name is a known preset
+func validatePreset(name string) error {
+	if _, ok := mysqldumpPresets[name]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(mysqldumpPresets))
+	for n := range mysqldumpPresets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown preset %q (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// validateMysqldumpOpts rejects passthrough values that aren't options
+func validateMysqldumpOpts(opts []string) error {
+	for _, opt := range opts {
+		if !strings.HasPrefix(opt, "-") {
+			return fmt.Errorf("invalid --mysqldump-opt %q: must start with -", opt)
+		}
+	}
+	return nil
+}
+
+// mysqldumpOpts returns the preset options followed by the passthrough
+// options, with a leading space when non-empty
+func mysqldumpOpts(preset string, extra []string, skipColumnStatistics bool) string {
+	var opts []string
+	opts = append(opts, mysqldumpPresets[preset]...)
+	if skipColumnStatistics {
+		opts = append(opts, "--column-statistics=0")
+	}
+	opts = append(opts, extra...)
+	if len(opts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(opts, " ")
+}
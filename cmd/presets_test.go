@@ -0,0 +1,78 @@
+package cmd
+
+import "testing"
+
+func TestDefaultPreset(t *testing.T) {
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{"UPDATE", "pre-dml-backup"},
+		{"DELETE", "pre-dml-backup"},
+		{"ALTER", "schema-only"},
+		{"DROP", "consistent"},
+		{"TRUNCATE", "consistent"},
+		{"SELECT", "none"},
+		{"INSERT", "none"},
+	}
+
+	for _, tt := range tests {
+		if got := defaultPreset(tt.action); got != tt.want {
+			t.Errorf("defaultPreset(%q) = %q, want %q", tt.action, got, tt.want)
+		}
+		if err := validatePreset(defaultPreset(tt.action)); err != nil {
+			t.Errorf("default preset for %q is invalid: %v", tt.action, err)
+		}
+	}
+}
+
+func TestMysqldumpOpts(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		extra    []string
+		skipStat bool
+		want     string
+	}{
+		{
+			name:   "No options",
+			preset: "none",
+			want:   "",
+		},
+		{
+			name:   "Pre-DML backup",
+			preset: "pre-dml-backup",
+			want:   " --single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF",
+		},
+		{
+			name:     "Schema only with column statistics and passthrough",
+			preset:   "schema-only",
+			extra:    []string{"--skip-lock-tables", "--max-allowed-packet=1G"},
+			skipStat: true,
+			want:     " --no-data --set-gtid-purged=OFF --column-statistics=0 --skip-lock-tables --max-allowed-packet=1G",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mysqldumpOpts(tt.preset, tt.extra, tt.skipStat); got != tt.want {
+				t.Errorf("mysqldumpOpts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetValidation(t *testing.T) {
+	if err := validatePreset("consistent"); err != nil {
+		t.Errorf("validatePreset(consistent) error = %v", err)
+	}
+	if err := validatePreset("fast"); err == nil {
+		t.Error("validatePreset(fast) expected error")
+	}
+	if err := validateMysqldumpOpts([]string{"--hex-blob"}); err != nil {
+		t.Errorf("validateMysqldumpOpts() error = %v", err)
+	}
+	if err := validateMysqldumpOpts([]string{"hex-blob"}); err == nil {
+		t.Error("validateMysqldumpOpts() expected error for value without dash")
+	}
+}
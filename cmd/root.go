@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
@@ -23,6 +24,14 @@ var (
 	host      string
 	ip        string
 	database  string
+	port      int
+	socket    string
+	sslMode   string
+	sslCA     string
+	sslCert   string
+	sslKey    string
+	compress  bool
+	dsn       string
 )
 
 // ResetGlobals resets all global variables (for testing)
@@ -33,14 +42,63 @@ func ResetGlobals() {
 	host = ""
 	ip = ""
 	database = "database_name"
+	port = 0
+	socket = ""
+	sslMode = ""
+	sslCA = ""
+	sslCert = ""
+	sslKey = ""
+	compress = false
+	dsn = ""
+	secrets = nil
+	passwordMode = ""
+	loginPath = ""
+	scriptPath = ""
+	scriptCompress = "none"
+	preset = ""
+	mysqldumpExtraOpts = nil
+	skipColumnStatistics = false
+	dumpToolName = "mysqldump"
+	dumpMode = "dump"
+	backupSuffix = ""
+	exportFormat = "tsv"
+	outfileDir = ""
+	executeDump = false
+	dumpOutDir = ""
+	preflight = false
+	mergeDumps = false
+	pkMappings = nil
+	schemaFile = ""
+	chunkSize = 0
+	checkFormat = "text"
+	checkSchemas = nil
+	checkMigrations = ""
+	lintFormat = "text"
+	lintConfigFile = ""
+	lintSchemas = nil
+	guardProtected = nil
+	guardApprovals = nil
+	guardConfigFile = ""
+	adviseSchemas = nil
+	adviseFormat = "text"
+	adviseCorpus = false
+	ddlSchemas = nil
+	ddlFormat = "text"
 
 	// Reset cobra command flags to prevent conflicts between test runs
-	rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
-		f.Value.Set(f.DefValue)
-	})
-	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
-		f.Value.Set(f.DefValue)
-	})
+	resetFlag := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	rootCmd.Flags().VisitAll(resetFlag)
+	rootCmd.PersistentFlags().VisitAll(resetFlag)
+	for _, sub := range rootCmd.Commands() {
+		sub.Flags().VisitAll(resetFlag)
+	}
 }
 
 // ColX represents the visitor for extracting SQL information
@@ -67,15 +125,32 @@ Examples:
 	Args:              cobra.MaximumNArgs(1),
 	RunE:              runParse,
 	DisableAutoGenTag: true,
+	SilenceErrors:     true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", redactSecrets(err.Error()))
+		code := 1
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		os.Exit(code)
 	}
 }
 
+// exitError is an error that ends the process with a specific exit code
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	return e.msg
+}
+
 func init() {
 	// Disable automatic help command to avoid conflict with -h for host
 	rootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
@@ -83,15 +158,35 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVarP(&fileInput, "file", "f", "", "Read SQL from file")
 	rootCmd.PersistentFlags().StringVarP(&user, "user", "u", "", "Database user")
-	rootCmd.PersistentFlags().StringVarP(&password, "password", "P", "", "Database password")
+	rootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "Database password")
 	rootCmd.PersistentFlags().StringVarP(&host, "host", "h", "", "Database host")
 	rootCmd.PersistentFlags().StringVar(&ip, "ip", "", "Database IP (overrides host)")
 	rootCmd.PersistentFlags().StringVarP(&database, "database", "d", "database_name", "Database name")
+	rootCmd.PersistentFlags().IntVarP(&port, "port", "P", 0, "Database port")
+	rootCmd.PersistentFlags().StringVar(&socket, "socket", "", "Unix socket file")
+	rootCmd.PersistentFlags().StringVar(&sslMode, "ssl-mode", "", "SSL mode: DISABLED, PREFERRED, REQUIRED, VERIFY_CA or VERIFY_IDENTITY")
+	rootCmd.PersistentFlags().StringVar(&sslCA, "ssl-ca", "", "SSL certificate authority file")
+	rootCmd.PersistentFlags().StringVar(&sslCert, "ssl-cert", "", "SSL client certificate file")
+	rootCmd.PersistentFlags().StringVar(&sslKey, "ssl-key", "", "SSL client key file")
+	rootCmd.PersistentFlags().BoolVar(&compress, "compress", false, "Compress client/server traffic")
+	rootCmd.PersistentFlags().StringVar(&dsn, "dsn", "", "Connection DSN, e.g. mysql://user@host:3307/db?tls=true")
+
+	// -P used to be --password; say so instead of echoing the value back
+	rootCmd.SetFlagErrorFunc(portFlagError)
 
 	// Add manual help flag with --help only (no short flag)
 	rootCmd.PersistentFlags().Bool("help", false, "Show help information")
 }
 
+// portFlagError replaces a bad -P/--port value error, which would print the
+// value, with a note that -P now sets the port as in mysqldump.
+func portFlagError(cmd *cobra.Command, err error) error {
+	if strings.Contains(err.Error(), `"-P, --port"`) {
+		return fmt.Errorf("invalid port: -P now sets the port as in mysqldump; pass the password with -p or --password")
+	}
+	return err
+}
+
 func runParse(cmd *cobra.Command, args []string) error {
 	sql, err := getSQLInput(args)
 	if err != nil {
@@ -104,6 +199,7 @@ func runParse(cmd *cobra.Command, args []string) error {
 	}
 
 	// Display parsed information
+	catalog := newSchemaCatalog()
 	for idx, stmtNode := range stmtNodes {
 		colNames, tableNames, action, whereFilter, _ := Extract(&stmtNode)
 
@@ -119,6 +215,21 @@ func runParse(cmd *cobra.Command, args []string) error {
 		if whereFilter != "" {
 			fmt.Printf("WHERE filter: %s\n", whereFilter)
 		}
+		orderBy, limit := ExtractBounds(stmtNode)
+		if orderBy != "" {
+			fmt.Printf("ORDER BY: %s\n", orderBy)
+		}
+		if limit != "" {
+			fmt.Printf("LIMIT: %s\n", limit)
+		}
+		if a, ok := analyseDDLStatement(stmtNode, catalog); ok {
+			rebuild := ""
+			if a.Rebuild {
+				rebuild = ", rebuilds the table"
+			}
+			fmt.Printf("Online DDL: ALGORITHM=%s, LOCK=%s%s (see dbsqlx ddl)\n", a.Algorithm, a.Lock, rebuild)
+		}
+		catalog.apply(stmtNode)
 	}
 
 	return nil
@@ -323,6 +434,28 @@ func (v *ColX) extractWhereFilterFromExpr(whereExpr ast.ExprNode) {
 	}
 }
 
+// restoreSQL renders an AST node back to SQL that runs as it reads:
+// identifiers are backquoted so reserved and odd names survive, and
+// charset introducers are left out. Commands embedding it quote it for
+// the shell with shellQuote.
+func restoreSQL(node ast.Node) string {
+	return restoreWith(node, format.RestoreStringSingleQuotes|format.RestoreKeyWordUppercase|format.RestoreStringWithoutCharset|format.RestoreNameBackQuotes)
+}
+
+// describeSQL renders an AST node for messages and labels, without
+// identifier quoting
+func describeSQL(node ast.Node) string {
+	return restoreWith(node, format.RestoreStringSingleQuotes|format.RestoreKeyWordUppercase|format.RestoreStringWithoutCharset)
+}
+
+func restoreWith(node ast.Node, flags format.RestoreFlags) string {
+	buf := new(bytes.Buffer)
+	if err := node.Restore(format.NewRestoreCtx(flags, buf)); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
 // Extract parses an AST node and extracts SQL information
 func Extract(rootNode *ast.StmtNode) (colNames, tableNames []string, action, whereFilter, primaryTable string) {
 	v := &ColX{
@@ -332,6 +465,34 @@ func Extract(rootNode *ast.StmtNode) (colNames, tableNames []string, action, whe
 	return v.ColNames, v.TableNames, v.Action, v.WhereFilter, v.PrimaryTable
 }
 
+// ExtractBounds returns the ORDER BY items and LIMIT of an UPDATE, DELETE
+// or SELECT, e.g. "created DESC, id" and "1000" or "20,10" (offset first)
+func ExtractBounds(stmtNode ast.StmtNode) (orderBy, limit string) {
+	var order *ast.OrderByClause
+	var lim *ast.Limit
+
+	switch stmt := stmtNode.(type) {
+	case *ast.UpdateStmt:
+		order, lim = stmt.Order, stmt.Limit
+	case *ast.DeleteStmt:
+		order, lim = stmt.Order, stmt.Limit
+	case *ast.SelectStmt:
+		order, lim = stmt.OrderBy, stmt.Limit
+	}
+
+	if order != nil {
+		items := make([]string, len(order.Items))
+		for i, item := range order.Items {
+			items[i] = describeSQL(item)
+		}
+		orderBy = strings.Join(items, ", ")
+	}
+	if lim != nil {
+		limit = strings.TrimPrefix(describeSQL(lim), "LIMIT ")
+	}
+	return orderBy, limit
+}
+
 // ParseAll parses SQL and returns all statement nodes
 func ParseAll(sql string) ([]ast.StmtNode, error) {
 	p := parser.New()
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/pingcap/tidb/pkg/parser"
@@ -434,6 +435,33 @@ func TestMultipleStatementsExtraction(t *testing.T) {
 	}
 }
 
+func TestExtractBounds(t *testing.T) {
+	tests := []struct {
+		sql         string
+		wantOrderBy string
+		wantLimit   string
+	}{
+		{"DELETE FROM logs WHERE created < '2024-01-01' ORDER BY id LIMIT 1000", "id", "1000"},
+		{"UPDATE jobs SET state = 'x' ORDER BY priority DESC, id LIMIT 5", "priority DESC, id", "5"},
+		{"SELECT * FROM users LIMIT 10 OFFSET 20", "", "20,10"},
+		{"SELECT * FROM users ORDER BY name", "name", ""},
+		{"INSERT INTO users (id) VALUES (1)", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			orderBy, limit := ExtractBounds(stmtNodes[0])
+			if orderBy != tt.wantOrderBy || limit != tt.wantLimit {
+				t.Errorf("ExtractBounds() = (%q, %q), want (%q, %q)", orderBy, limit, tt.wantOrderBy, tt.wantLimit)
+			}
+		})
+	}
+}
+
 func TestDDLStatementsWithCheckSyntax(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -550,3 +578,23 @@ func TestFilterWhereForTable(t *testing.T) {
 		})
 	}
 }
+
+func TestPortFlag(t *testing.T) {
+	defer ResetGlobals()
+
+	if err := rootCmd.ParseFlags([]string{"-P", "3307", "-p", "secret"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if port != 3307 || password != "secret" {
+		t.Errorf("port = %d, password = %q, want 3307 and secret", port, password)
+	}
+
+	err := rootCmd.ParseFlags([]string{"-P", "hunter2"})
+	if err == nil {
+		t.Fatal("ParseFlags() accepted a password for -P")
+	}
+	err = portFlagError(rootCmd, err)
+	if msg := err.Error(); strings.Contains(msg, "hunter2") || !strings.Contains(msg, "--password") {
+		t.Errorf("portFlagError() = %q, want a note without the value", msg)
+	}
+}
@@ -0,0 +1,480 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/opcode"
+)
+
+// Severity ranks lint findings
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Rule is a lint check run against every parsed statement
+type Rule interface {
+	// ID names the rule in findings
+	ID() string
+	// Severity is how serious the rule's findings are
+	Severity() Severity
+	// Check returns the rule's violations in one statement
+	Check(stmt ast.StmtNode) []Violation
+}
+
+// Violation is a rule match within a statement
+type Violation struct {
+	// Offset is the byte offset of the offending node in the statement's
+	// text, 0 when the node carries no position
+	Offset  int
+	Message string
+}
+
+// funcRule adapts a check function to the Rule interface
+type funcRule struct {
+	id       string
+	severity Severity
+	check    func(stmt ast.StmtNode) []Violation
+}
+
+func (r funcRule) ID() string                          { return r.id }
+func (r funcRule) Severity() Severity                  { return r.severity }
+func (r funcRule) Check(stmt ast.StmtNode) []Violation { return r.check(stmt) }
+
+const (
+	// defaultMaxInList is the largest IN list not reported by large-in-list
+	defaultMaxInList = 1000
+	// defaultMaxOffset is the largest LIMIT offset not reported by large-offset
+	defaultMaxOffset = 10000
+)
+
+// BuiltinRules returns the built-in rule set with default thresholds. The
+// typed rules among them only find something when lint has a schema.
+func BuiltinRules() []Rule {
+	return []Rule{
+		funcRule{"missing-where", SeverityError, checkMissingWhere},
+		funcRule{"null-comparison", SeverityError, checkNullComparison},
+		funcRule{"cartesian-join", SeverityWarning, checkCartesianJoin},
+		funcRule{"leading-wildcard-like", SeverityWarning, checkLeadingWildcard},
+		funcRule{"function-on-column", SeverityWarning, checkFunctionOnColumn},
+		funcRule{"order-by-rand", SeverityWarning, checkOrderByRand},
+		largeInListRule(defaultMaxInList),
+		largeOffsetRule(defaultMaxOffset),
+		typedFuncRule{"implicit-conversion", SeverityWarning, checkImplicitConversion},
+		typedFuncRule{"collation-mismatch", SeverityWarning, checkCollationMismatch},
+		typedFuncRule{"join-type-mismatch", SeverityWarning, checkJoinTypeMismatch},
+		funcRule{"select-star", SeverityInfo, checkSelectStar},
+	}
+}
+
+// nodeFinder calls visit for every node of a tree until visit asks to skip
+// a node's children
+type nodeFinder struct {
+	visit func(n ast.Node) (skipChildren bool)
+}
+
+func (f *nodeFinder) Enter(n ast.Node) (ast.Node, bool) {
+	return n, f.visit(n)
+}
+
+func (f *nodeFinder) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// walk visits every node under n
+func walk(n ast.Node, visit func(n ast.Node) bool) {
+	if n != nil {
+		n.Accept(&nodeFinder{visit: visit})
+	}
+}
+
+func checkMissingWhere(stmt ast.StmtNode) []Violation {
+	switch s := stmt.(type) {
+	case *ast.UpdateStmt:
+		if s.Where == nil {
+			return []Violation{{Message: "UPDATE without WHERE changes every row" + ofTables(s.TableRefs)}}
+		}
+	case *ast.DeleteStmt:
+		if s.Where == nil {
+			return []Violation{{Message: "DELETE without WHERE removes every row" + ofTables(s.TableRefs)}}
+		}
+	}
+	return nil
+}
+
+// ofTables renders " of t" for the tables of a FROM clause
+func ofTables(refs *ast.TableRefsClause) string {
+	if refs == nil || refs.TableRefs == nil {
+		return ""
+	}
+	if names := sourceNames(refs.TableRefs); len(names) > 0 {
+		return " of " + strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func checkNullComparison(stmt ast.StmtNode) []Violation {
+	var found []Violation
+	walk(stmt, func(n ast.Node) bool {
+		op, ok := n.(*ast.BinaryOperationExpr)
+		if !ok || (op.Op != opcode.EQ && op.Op != opcode.NE) {
+			return false
+		}
+		if isNullValue(op.L) || isNullValue(op.R) {
+			msg := "= NULL is never true; use IS NULL"
+			if op.Op == opcode.NE {
+				msg = "<> NULL is never true; use IS NOT NULL"
+			}
+			found = append(found, Violation{Offset: op.OriginTextPosition(), Message: msg})
+		}
+		return false
+	})
+	return found
+}
+
+func isNullValue(expr ast.ExprNode) bool {
+	v, ok := expr.(ast.ValueExpr)
+	return ok && v.GetValue() == nil
+}
+
+func checkCartesianJoin(stmt ast.StmtNode) []Violation {
+	var found []Violation
+	walk(stmt, func(n ast.Node) bool {
+		from, where := queryClauses(n)
+		if from == nil {
+			return false
+		}
+		conds := joinConditions(from, where)
+		walk(from, func(n ast.Node) bool {
+			join, ok := n.(*ast.Join)
+			if !ok {
+				// Derived tables are checked as queries of their own
+				_, sub := n.(*ast.SelectStmt)
+				return sub
+			}
+			if join.Right == nil || join.On != nil || len(join.Using) > 0 || join.NaturalJoin {
+				return false
+			}
+			left, right := sourceNames(join.Left), sourceNames(join.Right)
+			if !linksSides(conds, left, right) {
+				found = append(found, Violation{Message: fmt.Sprintf(
+					"join of %s and %s has no join condition, which yields a cartesian product",
+					strings.Join(left, ", "), strings.Join(right, ", "))})
+			}
+			return false
+		})
+		return false
+	})
+	return found
+}
+
+// queryClauses returns the FROM joins and WHERE of a query or DML statement
+func queryClauses(n ast.Node) (*ast.Join, ast.ExprNode) {
+	var refs *ast.TableRefsClause
+	var where ast.ExprNode
+	switch s := n.(type) {
+	case *ast.SelectStmt:
+		refs, where = s.From, s.Where
+	case *ast.UpdateStmt:
+		refs, where = s.TableRefs, s.Where
+	case *ast.DeleteStmt:
+		refs, where = s.TableRefs, s.Where
+	}
+	if refs == nil {
+		return nil, nil
+	}
+	return refs.TableRefs, where
+}
+
+// joinConditions collects the WHERE and every ON condition of a FROM clause
+func joinConditions(from *ast.Join, where ast.ExprNode) []ast.ExprNode {
+	var conds []ast.ExprNode
+	if where != nil {
+		conds = append(conds, where)
+	}
+	if from == nil {
+		return conds
+	}
+	walk(from, func(n ast.Node) bool {
+		if join, ok := n.(*ast.Join); ok && join.On != nil {
+			conds = append(conds, join.On.Expr)
+		}
+		_, sub := n.(*ast.SelectStmt)
+		return sub
+	})
+	return conds
+}
+
+// sourceNames lists the names a join's tables are referred to by: their
+// alias when they have one
+func sourceNames(node ast.ResultSetNode) []string {
+	var names []string
+	switch n := node.(type) {
+	case *ast.Join:
+		names = append(names, sourceNames(n.Left)...)
+		if n.Right != nil {
+			names = append(names, sourceNames(n.Right)...)
+		}
+	case *ast.TableSource:
+		if n.AsName.L != "" {
+			names = append(names, n.AsName.L)
+		} else if t, ok := n.Source.(*ast.TableName); ok {
+			names = append(names, t.Name.L)
+		}
+	}
+	return names
+}
+
+// linksSides reports whether a comparison in conds relates a column of the
+// left tables to one of the right. Unqualified columns could belong to
+// either side, so they count for both.
+func linksSides(conds []ast.ExprNode, left, right []string) bool {
+	linked := false
+	for _, cond := range conds {
+		walk(cond, func(n ast.Node) bool {
+			if _, sub := n.(*ast.SubqueryExpr); sub {
+				return true
+			}
+			op, ok := n.(*ast.BinaryOperationExpr)
+			if !ok || !isComparison(op.Op) {
+				return false
+			}
+			l, r := columnTables(op.L), columnTables(op.R)
+			if (touches(l, left) && touches(r, right)) || (touches(l, right) && touches(r, left)) {
+				linked = true
+			}
+			return false
+		})
+	}
+	return linked
+}
+
+func isComparison(op opcode.Op) bool {
+	switch op {
+	case opcode.EQ, opcode.NE, opcode.LT, opcode.LE, opcode.GT, opcode.GE, opcode.NullEQ:
+		return true
+	}
+	return false
+}
+
+// columnTables lists the table qualifiers of the columns in expr, with ""
+// for unqualified columns
+func columnTables(expr ast.ExprNode) []string {
+	var tables []string
+	walk(expr, func(n ast.Node) bool {
+		if col, ok := n.(*ast.ColumnNameExpr); ok {
+			tables = append(tables, col.Name.Table.L)
+		}
+		return false
+	})
+	return tables
+}
+
+func touches(tables, side []string) bool {
+	for _, t := range tables {
+		if t == "" || slices.Contains(side, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkLeadingWildcard(stmt ast.StmtNode) []Violation {
+	var found []Violation
+	walk(stmt, func(n ast.Node) bool {
+		like, ok := n.(*ast.PatternLikeOrIlikeExpr)
+		if !ok {
+			return false
+		}
+		v, ok := like.Pattern.(ast.ValueExpr)
+		if !ok {
+			return false
+		}
+		if pattern, ok := v.GetValue().(string); ok && (strings.HasPrefix(pattern, "%") || strings.HasPrefix(pattern, "_")) {
+			found = append(found, Violation{
+				Offset:  like.OriginTextPosition(),
+				Message: fmt.Sprintf("LIKE '%s' starts with a wildcard, so no index can narrow the scan", pattern),
+			})
+		}
+		return false
+	})
+	return found
+}
+
+// indexedSuffixes are column name endings that usually carry an index
+var indexedSuffixes = []string{"_id", "_uuid", "_key", "_at", "_date", "_time", "_code"}
+
+// looksIndexed guesses from its name whether a column is indexed
+func looksIndexed(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "id", "uuid", "email", "created", "updated":
+		return true
+	}
+	for _, suffix := range indexedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkFunctionOnColumn(stmt ast.StmtNode) []Violation {
+	var found []Violation
+	check := func(expr ast.ExprNode) {
+		name := ""
+		switch f := expr.(type) {
+		case *ast.FuncCallExpr:
+			name = strings.ToUpper(f.FnName.O)
+		case *ast.FuncCastExpr:
+			name = "CAST"
+		default:
+			return
+		}
+		for _, col := range columnNames(expr) {
+			if looksIndexed(col) {
+				found = append(found, Violation{
+					Offset:  expr.OriginTextPosition(),
+					Message: fmt.Sprintf("%s() around %s keeps an index on %s from being used; compare the bare column", name, col, col),
+				})
+				return
+			}
+		}
+	}
+
+	walk(stmt, func(n ast.Node) bool {
+		for _, cond := range joinConditions(queryClauses(n)) {
+			walk(cond, func(n ast.Node) bool {
+				switch e := n.(type) {
+				case *ast.SubqueryExpr:
+					return true
+				case *ast.BinaryOperationExpr:
+					if isComparison(e.Op) {
+						check(e.L)
+						check(e.R)
+					}
+				case *ast.PatternInExpr:
+					check(e.Expr)
+				case *ast.BetweenExpr:
+					check(e.Expr)
+				case *ast.PatternLikeOrIlikeExpr:
+					check(e.Expr)
+				}
+				return false
+			})
+		}
+		return false
+	})
+	return found
+}
+
+// columnNames lists the columns referenced in expr
+func columnNames(expr ast.ExprNode) []string {
+	var names []string
+	walk(expr, func(n ast.Node) bool {
+		if col, ok := n.(*ast.ColumnNameExpr); ok {
+			names = append(names, col.Name.Name.O)
+		}
+		return false
+	})
+	return names
+}
+
+func checkOrderByRand(stmt ast.StmtNode) []Violation {
+	var found []Violation
+	walk(stmt, func(n ast.Node) bool {
+		order, ok := n.(*ast.OrderByClause)
+		if !ok {
+			return false
+		}
+		for _, item := range order.Items {
+			if f, ok := item.Expr.(*ast.FuncCallExpr); ok && f.FnName.L == "rand" {
+				found = append(found, Violation{
+					Offset:  f.OriginTextPosition(),
+					Message: "ORDER BY RAND() sorts every matching row to pick a few",
+				})
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// largeInListRule reports IN lists with more than maxValues values
+func largeInListRule(maxValues int) Rule {
+	return funcRule{"large-in-list", SeverityWarning, func(stmt ast.StmtNode) []Violation {
+		var found []Violation
+		walk(stmt, func(n ast.Node) bool {
+			in, ok := n.(*ast.PatternInExpr)
+			if ok && len(in.List) > maxValues {
+				found = append(found, Violation{
+					Offset:  in.OriginTextPosition(),
+					Message: fmt.Sprintf("IN list has %d values (over %d); batch them or join a temporary table", len(in.List), maxValues),
+				})
+			}
+			return false
+		})
+		return found
+	}}
+}
+
+// largeOffsetRule reports LIMIT offsets above maxOffset
+func largeOffsetRule(maxOffset int) Rule {
+	return funcRule{"large-offset", SeverityWarning, func(stmt ast.StmtNode) []Violation {
+		var found []Violation
+		walk(stmt, func(n ast.Node) bool {
+			limit, ok := n.(*ast.Limit)
+			if !ok || limit.Offset == nil {
+				return false
+			}
+			v, ok := limit.Offset.(ast.ValueExpr)
+			if !ok {
+				return false
+			}
+			var offset uint64
+			switch n := v.GetValue().(type) {
+			case int64:
+				offset = uint64(max(n, 0))
+			case uint64:
+				offset = n
+			}
+			if offset > uint64(maxOffset) {
+				found = append(found, Violation{
+					Message: fmt.Sprintf("LIMIT with OFFSET %d reads and discards %d rows; page by key instead", offset, offset),
+				})
+			}
+			return false
+		})
+		return found
+	}}
+}
+
+func checkSelectStar(stmt ast.StmtNode) []Violation {
+	var found []Violation
+	walk(stmt, func(n ast.Node) bool {
+		// EXISTS (SELECT * ...) reads no columns
+		if _, ok := n.(*ast.ExistsSubqueryExpr); ok {
+			return true
+		}
+		field, ok := n.(*ast.SelectField)
+		if !ok || field.WildCard == nil {
+			return false
+		}
+		star := "*"
+		if field.WildCard.Table.L != "" {
+			star = field.WildCard.Table.O + ".*"
+		}
+		found = append(found, Violation{
+			Offset:  field.Offset,
+			Message: fmt.Sprintf("SELECT %s fetches every column; list the columns you need", star),
+		})
+		return false
+	})
+	return found
+}
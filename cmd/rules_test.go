@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ruleByID finds a built-in rule
+func ruleByID(t *testing.T, id string) Rule {
+	t.Helper()
+	for _, r := range BuiltinRules() {
+		if r.ID() == id {
+			return r
+		}
+	}
+	t.Fatalf("no built-in rule %q", id)
+	return nil
+}
+
+func TestBuiltinRules(t *testing.T) {
+	inList := "(" + strings.Repeat("1, ", 1000) + "1)"
+
+	tests := []struct {
+		rule string
+		sql  string
+		// want lists the messages found, each prefixed with its offset
+		want []string
+	}{
+		{"missing-where", "DELETE FROM logs", []string{"0 DELETE without WHERE removes every row of logs"}},
+		{"missing-where", "UPDATE users u SET u.x = 1 LIMIT 10", []string{"0 UPDATE without WHERE changes every row of u"}},
+		{"missing-where", "DELETE FROM logs WHERE id = 1", nil},
+		{"missing-where", "SELECT * FROM logs", nil},
+
+		{"null-comparison", "SELECT * FROM t WHERE a = NULL", []string{"22 = NULL is never true; use IS NULL"}},
+		{"null-comparison", "UPDATE t SET x = 1 WHERE NULL <> b", []string{"25 <> NULL is never true; use IS NOT NULL"}},
+		{"null-comparison", "SELECT * FROM t WHERE a IS NULL AND b <=> NULL", nil},
+
+		{"cartesian-join", "SELECT * FROM a, b", []string{"0 join of a and b has no join condition, which yields a cartesian product"}},
+		{"cartesian-join", "SELECT * FROM a x, b y, c WHERE x.id = y.a_id", []string{"0 join of x, y and c has no join condition, which yields a cartesian product"}},
+		{"cartesian-join", "SELECT * FROM a, b WHERE a.id = b.a_id", nil},
+		{"cartesian-join", "SELECT * FROM a, b WHERE a_id = id", nil},
+		{"cartesian-join", "SELECT * FROM a JOIN b ON a.id = b.a_id", nil},
+		{"cartesian-join", "SELECT * FROM a JOIN b USING (id)", nil},
+		{"cartesian-join", "SELECT * FROM a NATURAL JOIN b", nil},
+		{"cartesian-join", "DELETE a FROM a, b WHERE a.x = 1", []string{"0 join of a and b has no join condition, which yields a cartesian product"}},
+		{"cartesian-join", "SELECT * FROM (SELECT * FROM a, b) d WHERE d.x = 1", []string{"0 join of a and b has no join condition, which yields a cartesian product"}},
+
+		{"leading-wildcard-like", "SELECT id FROM t WHERE name LIKE '%son'", []string{"23 LIKE '%son' starts with a wildcard, so no index can narrow the scan"}},
+		{"leading-wildcard-like", "SELECT id FROM t WHERE name NOT LIKE '_x%'", []string{"23 LIKE '_x%' starts with a wildcard, so no index can narrow the scan"}},
+		{"leading-wildcard-like", "SELECT id FROM t WHERE name LIKE 'jo%'", nil},
+
+		{"function-on-column", "SELECT id FROM t WHERE DATE(created_at) = '2024-01-01'", []string{"23 DATE() around created_at keeps an index on created_at from being used; compare the bare column"}},
+		{"function-on-column", "SELECT id FROM t WHERE LOWER(email) IN ('a', 'b')", []string{"23 LOWER() around email keeps an index on email from being used; compare the bare column"}},
+		{"function-on-column", "SELECT * FROM a JOIN b ON CAST(a.user_id AS CHAR) = b.ref", []string{"26 CAST() around user_id keeps an index on user_id from being used; compare the bare column"}},
+		{"function-on-column", "SELECT id FROM t WHERE UPPER(name) = 'X'", nil},
+		{"function-on-column", "SELECT DATE(created_at) FROM t WHERE created_at > NOW()", nil},
+
+		{"order-by-rand", "SELECT id FROM t ORDER BY RAND() LIMIT 1", []string{"26 ORDER BY RAND() sorts every matching row to pick a few"}},
+		{"order-by-rand", "DELETE FROM t WHERE a = 1 ORDER BY rand() LIMIT 5", []string{"35 ORDER BY RAND() sorts every matching row to pick a few"}},
+		{"order-by-rand", "SELECT RAND() FROM t ORDER BY id", nil},
+
+		{"large-in-list", "SELECT id FROM t WHERE id IN " + inList, []string{"23 IN list has 1001 values (over 1000); batch them or join a temporary table"}},
+		{"large-in-list", "SELECT id FROM t WHERE id IN (1, 2, 3)", nil},
+
+		{"large-offset", "SELECT id FROM t ORDER BY id LIMIT 10 OFFSET 50000", []string{"0 LIMIT with OFFSET 50000 reads and discards 50000 rows; page by key instead"}},
+		{"large-offset", "SELECT id FROM t ORDER BY id LIMIT 20000, 10", []string{"0 LIMIT with OFFSET 20000 reads and discards 20000 rows; page by key instead"}},
+		{"large-offset", "SELECT id FROM t ORDER BY id LIMIT 10 OFFSET 10000", nil},
+		{"large-offset", "SELECT id FROM t LIMIT 50000", nil},
+
+		{"select-star", "SELECT * FROM t", []string{"7 SELECT * fetches every column; list the columns you need"}},
+		{"select-star", "SELECT id, o.* FROM t JOIN o ON t.id = o.t_id", []string{"11 SELECT o.* fetches every column; list the columns you need"}},
+		{"select-star", "SELECT id FROM t WHERE EXISTS (SELECT * FROM o WHERE o.t_id = t.id)", nil},
+		{"select-star", "SELECT COUNT(*) FROM t", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.sql[:min(len(tt.sql), 40)], func(t *testing.T) {
+			stmtNodes, err := ParseAll(tt.sql)
+			if err != nil {
+				t.Fatalf("ParseAll() error = %v", err)
+			}
+			var got []string
+			for _, v := range ruleByID(t, tt.rule).Check(stmtNodes[0]) {
+				got = append(got, fmt.Sprintf("%d %s", v.Offset, v.Message))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLooksIndexed(t *testing.T) {
+	tests := map[string]bool{
+		"id":         true,
+		"user_id":    true,
+		"Created_At": true,
+		"email":      true,
+		"order_date": true,
+		"name":       false,
+		"status":     false,
+		"idle":       false,
+	}
+	for name, want := range tests {
+		if got := looksIndexed(name); got != want {
+			t.Errorf("looksIndexed(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
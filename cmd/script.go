@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output compression for dump scripts
+var scriptCompressors = map[string]struct {
+	pipe string
+	ext  string
+}{
+	"none": {"", ".sql"},
+	"gzip": {" | gzip", ".sql.gz"},
+	"zstd": {" | zstd -q", ".sql.zst"},
+}
+
+// shellQuote wraps s in single quotes so bash takes it literally
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// escapeBackquotes escapes the backquotes of quoted identifiers for a
+// double-quoted bash string
+func escapeBackquotes(s string) string {
+	return strings.ReplaceAll(s, "`", "\\`")
+}
+
+// writeDumpScript renders the dump plan as a self-contained bash script.
+// Every dump goes to its own timestamped file (or directory, for tools that
+// don't stream) and a manifest of files, tables, filters and row counts is
+// written at the end.
+func writeDumpScript(w io.Writer, plans []statementPlan, settings dumpSettings, tool dumpTool) error {
+	compressor, ok := scriptCompressors[settings.Compression]
+	if !ok {
+		return fmt.Errorf("unknown script compression %q (valid: none, gzip, zstd)", settings.Compression)
+	}
+	connOpts, database := settings.ConnOpts, settings.Database
+
+	total := 0
+	for _, plan := range plans {
+		total += len(plan.Dumps) + len(plan.Queries)
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\n")
+	b.WriteString("# Generated by dbsqlx dump --script\n")
+	b.WriteString("# Usage: <script> [output-dir]\n")
+	for _, note := range settings.Notes {
+		b.WriteString(note + "\n")
+	}
+	b.WriteString("set -euo pipefail\n\n")
+	b.WriteString("out_dir=\"${1:-.}\"\n")
+	if settings.ChunkSize > 0 {
+		// Rerunning with the same timestamp resumes the chunked dumps
+		b.WriteString("ts=\"${DBSQLX_TS:-$(date +%Y%m%d_%H%M%S)}\"\n")
+		b.WriteString("echo \"Run $ts, resume with DBSQLX_TS=$ts\" >&2\n")
+	} else {
+		b.WriteString("ts=\"$(date +%Y%m%d_%H%M%S)\"\n")
+	}
+	b.WriteString("work_dir=\"$(mktemp -d)\"\n")
+	if settings.RemoveDefaultsFile && settings.Creds != nil && settings.Creds.Mode == credModeDefaultsFile {
+		fmt.Fprintf(&b, "defaults_file=%s\n", shellQuote(settings.Creds.DefaultsFile))
+		b.WriteString("trap 'rm -rf \"$work_dir\" \"$defaults_file\"' EXIT\n")
+	} else {
+		b.WriteString("trap 'rm -rf \"$work_dir\"' EXIT\n")
+	}
+	b.WriteString("mkdir -p \"$out_dir\"\n")
+	b.WriteString("manifest=\"$out_dir/manifest_${ts}.tsv\"\n")
+	if settings.ChunkSize > 0 {
+		b.WriteString("[ -e \"$manifest\" ] || printf 'file\\ttable\\tfilter\\trows\\n' > \"$manifest\"\n")
+	} else {
+		b.WriteString("printf 'file\\ttable\\tfilter\\trows\\n' > \"$manifest\"\n")
+	}
+
+	step := 0
+	for _, plan := range plans {
+		b.WriteString("\n")
+		if plan.Action != "" {
+			fmt.Fprintf(&b, "# Statement %d (%s):\n", plan.Index, plan.Action)
+		} else {
+			fmt.Fprintf(&b, "# Statement %d:\n", plan.Index)
+		}
+		for _, line := range strings.Split(plan.Text, "\n") {
+			b.WriteString("#   " + line + "\n")
+		}
+		if plan.Reason != "" {
+			b.WriteString("# Plan: " + plan.Reason + "\n")
+		}
+		if len(plan.Dumps) == 0 && len(plan.Queries) == 0 {
+			b.WriteString("# No tables found in SQL statement\n")
+			continue
+		}
+
+		for _, dump := range plan.Dumps {
+			step++
+			target := database + "." + dump.Name()
+
+			// Streaming tools are redirected into a file, the others are
+			// given a directory to write into
+			ext, empty := compressor.ext, fmt.Sprintf("echo '-- no matching rows'%s > \"$file\"", compressor.pipe)
+			dumpCmd := func(where string) string {
+				return tool.Command(settings, plan, dump.AllTables(), where, "") + compressor.pipe + " > \"$file\""
+			}
+			if !tool.Streams() {
+				ext, empty = "", "mkdir -p \"$file\""
+				dumpCmd = func(where string) string {
+					return tool.Command(settings, plan, dump.AllTables(), where, "\"$file\"")
+				}
+			}
+
+			if settings.ChunkSize > 0 && chunkable(plan, dump) {
+				fmt.Fprintf(&b, "echo %s >&2\n", shellQuote(fmt.Sprintf("[%d/%d] Dumping %s in chunks of %d rows", step, total, target, settings.ChunkSize)))
+				for _, table := range dump.AllTables() {
+					if err := writeChunkWalk(&b, settings, tool, plan, table, dump.Filter, ext, compressor.pipe); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			fmt.Fprintf(&b, "echo %s >&2\n", shellQuote(fmt.Sprintf("[%d/%d] Dumping %s", step, total, target)))
+			fmt.Fprintf(&b, "file=\"$out_dir/%s_%d_${ts}%s\"\n", target, plan.Index, ext)
+
+			if dump.IDQuery != "" {
+				// Capture the exact ids first, then dump only those rows
+				fmt.Fprintf(&b, "ids_file=\"$(mktemp \"$work_dir/%s_ids.XXXXXX\")\"\n", dump.Table)
+				fmt.Fprintf(&b, "mysql%s -N --raw -e %s %s > \"$ids_file\"\n", connOpts, shellQuote(dump.idListQuery()), database)
+				b.WriteString("rows=\"$(wc -l < \"$ids_file\" | tr -d ' ')\"\n")
+				b.WriteString("if [ \"$rows\" -gt 0 ]; then\n")
+				fmt.Fprintf(&b, "  filter=%s\"$(paste -sd, \"$ids_file\"))\"\n", shellQuote(keyTuple(dump.Key)+" IN ("))
+				b.WriteString("  " + dumpCmd("\"$filter\"") + "\n")
+				b.WriteString("else\n")
+				b.WriteString("  filter=\"\"\n")
+				b.WriteString("  " + empty + "\n")
+				b.WriteString("fi\n")
+			} else {
+				where, cond := "", ""
+				if dump.Filter != "" {
+					fmt.Fprintf(&b, "filter=%s\n", shellQuote(dump.Filter))
+					where, cond = "\"$filter\"", " WHERE $filter"
+				} else {
+					b.WriteString("filter=\"\"\n")
+				}
+				// The names go into a double-quoted -e argument
+				var counts []string
+				for _, table := range dump.AllTables() {
+					counts = append(counts, "(SELECT COUNT(*) FROM "+escapeBackquotes(quoteTableName(table))+cond+")")
+				}
+				countQuery := "SELECT " + strings.Join(counts, " + ")
+				if len(counts) == 1 {
+					countQuery = "SELECT COUNT(*) FROM " + escapeBackquotes(quoteTableName(dump.Table)) + cond
+				}
+				fmt.Fprintf(&b, "rows=\"$(mysql%s -N -e \"%s\" %s)\"\n", connOpts, countQuery, database)
+				b.WriteString(dumpCmd(where) + "\n")
+			}
+
+			fmt.Fprintf(&b, "printf '%%s\\t%%s\\t%%s\\t%%s\\n' \"$file\" %s \"$filter\" \"$rows\" >> \"$manifest\"\n", shellQuote(target))
+		}
+
+		// Query results are small and saved uncompressed
+		for _, q := range plan.Queries {
+			step++
+			target := database + "." + q.Table
+			name := queryName(database, q)
+			fmt.Fprintf(&b, "echo %s >&2\n", shellQuote(fmt.Sprintf("[%d/%d] Saving %s", step, total, name)))
+			fmt.Fprintf(&b, "file=\"$out_dir/%s_%d_${ts}%s\"\n", name, plan.Index, q.Ext())
+			b.WriteString(queryCommand(settings, q, "\"$file\"") + "\n")
+			b.WriteString("rows=\"$(wc -l < \"$file\" | tr -d ' ')\"\n")
+			fmt.Fprintf(&b, "printf '%%s\\t%%s\\t%%s\\t%%s\\n' \"$file\" %s %s \"$rows\" >> \"$manifest\"\n", shellQuote(target), shellQuote(q.Query))
+		}
+	}
+
+	b.WriteString("\necho \"Manifest: $manifest\" >&2\n")
+	b.WriteString("cat \"$manifest\"\n")
+
+	_, err := io.WriteString(w, redactSecrets(b.String()))
+	return err
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"id=1", "'id=1'"},
+		{"status='active'", `'status='\''active'\'''`},
+		{"$HOME", "'$HOME'"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteDumpScript(t *testing.T) {
+	stmtNodes, err := ParseAll(`UPDATE Employees e JOIN Departments d ON e.DepartmentID = d.DepartmentID SET e.Salary = 1 WHERE d.DepartmentName = 'Sales';
+DELETE FROM logs WHERE level = 'debug'`)
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = writeDumpScript(&buf, buildDumpPlan(stmtNodes, testKeyCatalog(t, "employees=id")), dumpSettings{ConnOpts: " -h localhost -u root", Database: "prod", Compression: "gzip"}, mysqldumpTool{})
+	if err != nil {
+		t.Fatalf("writeDumpScript() error = %v", err)
+	}
+	script := buf.String()
+
+	wantParts := []string{
+		"#!/usr/bin/env bash\n",
+		"set -euo pipefail\n",
+		`ids_file="$(mktemp "$work_dir/Employees_ids.XXXXXX")"`,
+		`mysqldump -h localhost -u root --single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF --where="$filter" prod Employees | gzip > "$file"`,
+		`filter='level='\''debug'\'''`,
+		"rows=\"$(mysql -h localhost -u root -N -e \"SELECT COUNT(*) FROM \\`logs\\` WHERE $filter\" prod)\"",
+		`file="$out_dir/prod.logs_2_${ts}.sql.gz"`,
+		"echo '[2/2] Dumping prod.logs' >&2",
+		`>> "$manifest"`,
+	}
+	for _, part := range wantParts {
+		if !strings.Contains(script, part) {
+			t.Errorf("script missing %q\nGot:\n%s", part, script)
+		}
+	}
+	if strings.Contains(script, "/tmp/") {
+		t.Errorf("script uses fixed /tmp paths:\n%s", script)
+	}
+
+	if _, err := exec.LookPath("bash"); err == nil {
+		path := filepath.Join(t.TempDir(), "dump.sh")
+		if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command("bash", "-n", path).CombinedOutput(); err != nil {
+			t.Errorf("bash -n failed: %v\n%s", err, out)
+		}
+	}
+}
+
+func TestWriteDumpScriptReservedNames(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	stmtNodes, err := ParseAll("DELETE FROM `order` WHERE id = 1")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeDumpScript(&buf, buildDumpPlan(stmtNodes, nil), dumpSettings{Database: "prod", Compression: "none"}, mysqldumpTool{}); err != nil {
+		t.Fatalf("writeDumpScript() error = %v", err)
+	}
+
+	// Run the row count with a mysql that prints its query
+	var count []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "filter=") || strings.HasPrefix(line, "rows=") {
+			count = append(count, line)
+		}
+	}
+	script := "mysql() { printf '%s' \"$3\"; }\n" + strings.Join(count, "\n") + "\nprintf '%s' \"$rows\"\n"
+	out, err := exec.Command("bash", "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+	if want := "SELECT COUNT(*) FROM `order` WHERE id=1"; string(out) != want {
+		t.Errorf("row count query = %q, want %q", out, want)
+	}
+}
+
+func TestWriteDumpScriptUnknownCompression(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeDumpScript(&buf, nil, dumpSettings{Database: "prod", Compression: "bzip2"}, mysqldumpTool{}); err == nil {
+		t.Error("writeDumpScript() expected error for unknown compression")
+	}
+}
+
+func TestWriteDumpScriptRemovesDefaultsFile(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	creds, err := resolveCredentials(credModeDefaultsFile, "secret", "")
+	if err != nil {
+		t.Fatalf("resolveCredentials() error = %v", err)
+	}
+	defer os.Remove(creds.DefaultsFile)
+
+	var buf bytes.Buffer
+	settings := dumpSettings{ConnOpts: creds.Opts(), Creds: creds, Database: "prod", Compression: "none", RemoveDefaultsFile: true}
+	if err := writeDumpScript(&buf, nil, settings, mysqldumpTool{}); err != nil {
+		t.Fatalf("writeDumpScript() error = %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dump.sh")
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("bash", path, dir).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(creds.DefaultsFile); !os.IsNotExist(err) {
+		t.Errorf("defaults file %s left behind after the script exited", creds.DefaultsFile)
+	}
+}
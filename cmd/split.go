@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sqlStatement is one statement of a script as the mysql client sends it
+type sqlStatement struct {
+	// Index is the 1-based position among the script's statements
+	Index int
+	// Offset is the byte offset of Text in the script
+	Offset int
+	// Text excludes the delimiter
+	Text string
+}
+
+// splitStatements splits a script into statements the way the mysql client
+// does: on the current delimiter outside quotes and comments, with
+// DELIMITER lines changing the delimiter. Statements holding nothing but
+// whitespace and comments are dropped.
+func splitStatements(sql string) []sqlStatement {
+	var stmts []sqlStatement
+	delimiter := ";"
+	start := 0
+	// content is set once the current statement holds more than
+	// whitespace and comments
+	content := false
+
+	flush := func(end int) {
+		if content {
+			text := sql[start:end]
+			lead := len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+			stmts = append(stmts, sqlStatement{
+				Index:  len(stmts) + 1,
+				Offset: start + lead,
+				Text:   strings.TrimRightFunc(text[lead:], unicode.IsSpace),
+			})
+		}
+		content = false
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case !content && isDelimiterCommand(sql[i:]):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			if fields := strings.Fields(sql[i+len("delimiter") : i+end]); len(fields) > 0 {
+				delimiter = fields[0]
+			}
+			i += end
+			start = i + 1
+		case strings.HasPrefix(sql[i:], delimiter):
+			flush(i)
+			i += len(delimiter) - 1
+			start = i + 1
+		case c == '\'' || c == '"' || c == '`':
+			content = true
+			i = skipQuoted(sql, i)
+		case c == '#' || (c == '-' && isDashComment(sql[i:])):
+			if nl := strings.IndexByte(sql[i:], '\n'); nl >= 0 {
+				i += nl
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			// Executable /*! ... */ comments are SQL to the server
+			if strings.HasPrefix(sql[i:], "/*!") {
+				content = true
+			}
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+		case !unicode.IsSpace(rune(c)):
+			content = true
+		}
+	}
+	flush(len(sql))
+	return stmts
+}
+
+// isDelimiterCommand reports whether s starts with a mysql client
+// DELIMITER command
+func isDelimiterCommand(s string) bool {
+	const cmd = "delimiter"
+	if len(s) <= len(cmd) || !strings.EqualFold(s[:len(cmd)], cmd) {
+		return false
+	}
+	return s[len(cmd)] == ' ' || s[len(cmd)] == '\t'
+}
+
+// isDashComment reports whether s starts with a "-- " comment, which needs
+// whitespace (or the end of the line) after the dashes
+func isDashComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) == 2 || s[2] == ' ' || s[2] == '\t' || s[2] == '\n' || s[2] == '\r'
+}
+
+// leadingComments returns the length of the whitespace and comments a
+// statement starts with
+func leadingComments(text string) int {
+	i := 0
+	for i < len(text) {
+		switch {
+		case unicode.IsSpace(rune(text[i])):
+			i++
+		case text[i] == '#' || isDashComment(text[i:]):
+			nl := strings.IndexByte(text[i:], '\n')
+			if nl < 0 {
+				return len(text)
+			}
+			i += nl + 1
+		case strings.HasPrefix(text[i:], "/*") && !strings.HasPrefix(text[i:], "/*!"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return len(text)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// statementIndexAt returns the 1-based index of the statement containing
+// offset, or of the last statement before it
+func statementIndexAt(sql string, offset int) int {
+	index := 1
+	for _, stmt := range splitStatements(sql) {
+		if stmt.Offset > offset {
+			break
+		}
+		index = stmt.Index
+	}
+	return index
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []sqlStatement
+	}{
+		{
+			name: "Single statement without delimiter",
+			sql:  "SELECT 1",
+			want: []sqlStatement{{1, 0, "SELECT 1"}},
+		},
+		{
+			name: "Offsets skip leading whitespace",
+			sql:  "SELECT 1;\n  SELECT 2 ;\n",
+			want: []sqlStatement{{1, 0, "SELECT 1"}, {2, 12, "SELECT 2"}},
+		},
+		{
+			name: "Delimiters in quotes",
+			sql:  `SELECT 'a;b', "c;d", ` + "`e;f`" + `; SELECT 'it''s;'; SELECT 'x\';'`,
+			want: []sqlStatement{
+				{1, 0, `SELECT 'a;b', "c;d", ` + "`e;f`"},
+				{2, 28, `SELECT 'it''s;'`},
+				{3, 45, `SELECT 'x\';'`},
+			},
+		},
+		{
+			// Leading comments stay with the statement, as the client sends them
+			name: "Delimiters in comments",
+			sql:  "-- a; b\nSELECT 1 /* ; */ FROM t; # c; d\nSELECT 2",
+			want: []sqlStatement{{1, 0, "-- a; b\nSELECT 1 /* ; */ FROM t"}, {2, 33, "# c; d\nSELECT 2"}},
+		},
+		{
+			name: "Double dash without space is an operator",
+			sql:  "SELECT 1--1; SELECT 2",
+			want: []sqlStatement{{1, 0, "SELECT 1--1"}, {2, 13, "SELECT 2"}},
+		},
+		{
+			name: "Empty and comment-only statements are dropped",
+			sql:  ";; -- only a comment\n; SELECT 1;;",
+			want: []sqlStatement{{1, 23, "SELECT 1"}},
+		},
+		{
+			name: "Executable comments are statements",
+			sql:  "/*!40101 SET NAMES utf8 */; /* note */;",
+			want: []sqlStatement{{1, 0, "/*!40101 SET NAMES utf8 */"}},
+		},
+		{
+			name: "DELIMITER switches the delimiter",
+			sql:  "DELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; END//\ndelimiter ;\nSELECT 2;",
+			want: []sqlStatement{
+				{1, 13, "CREATE PROCEDURE p() BEGIN SELECT 1; END"},
+				{2, 68, "SELECT 2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.sql)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %+v, want %+v", got, tt.want)
+			}
+			for _, stmt := range got {
+				if tt.sql[stmt.Offset:stmt.Offset+len(stmt.Text)] != stmt.Text {
+					t.Errorf("statement %d: Offset %d doesn't point at %q", stmt.Index, stmt.Offset, stmt.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestLeadingComments(t *testing.T) {
+	tests := map[string]int{
+		"SELECT 1":                   0,
+		"  SELECT 1":                 2,
+		"-- a\n# b\nSELECT 1":        9,
+		"/* a */ /* b */\nSELECT 1":  16,
+		"/*!40101 SET NAMES utf8 */": 0,
+		"-- only a comment":          17,
+	}
+	for text, want := range tests {
+		if got := leadingComments(text); got != want {
+			t.Errorf("leadingComments(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
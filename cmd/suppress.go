@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ignoreDirective starts an inline suppression comment
+const ignoreDirective = "dbsqlx:ignore"
+
+// Suppression is a `-- dbsqlx:ignore rule reason="..."` comment, which
+// hides findings of the statement that follows it
+type Suppression struct {
+	File      string `json:"file,omitempty"`
+	Statement int    `json:"statement"`
+	Line      int    `json:"line"`
+	// Rules is empty when the comment ignores every rule
+	Rules  []string `json:"rules"`
+	Reason string   `json:"reason"`
+	// UnknownRules are named in the comment but don't exist
+	UnknownRules []string `json:"unknown_rules,omitempty"`
+	// Suppressed holds the findings the comment hid
+	Suppressed []Finding `json:"suppressed"`
+}
+
+// String renders the suppression for the audit listing
+func (s *Suppression) String() string {
+	loc := fmt.Sprintf("line %d", s.Line)
+	if s.File != "" {
+		loc = fmt.Sprintf("%s:%d", s.File, s.Line)
+	}
+	return loc + ": " + s.describe()
+}
+
+// describe says what the suppression ignores, why, and what it hid
+func (s *Suppression) describe() string {
+	rules := "all rules"
+	if len(s.Rules) > 0 {
+		rules = strings.Join(s.Rules, ", ")
+	}
+	reason := "no reason"
+	if s.Reason != "" {
+		reason = fmt.Sprintf("reason %q", s.Reason)
+	}
+	status := "unused"
+	if len(s.Suppressed) > 0 {
+		status = counted(len(s.Suppressed), "finding", "findings") + " suppressed"
+	}
+	if len(s.UnknownRules) > 0 {
+		status = fmt.Sprintf("unknown rule %s, %s", strings.Join(s.UnknownRules, ", "), status)
+	}
+	return fmt.Sprintf("ignore %s (statement %d), %s: %s", rules, s.Statement, reason, status)
+}
+
+// covers reports whether the suppression hides findings of rule
+func (s *Suppression) covers(rule string) bool {
+	return len(s.Rules) == 0 || slices.Contains(s.Rules, rule)
+}
+
+// directive is a dbsqlx:<name> comment ahead of a statement
+type directive struct {
+	// Offset is the byte offset of the directive in the whole input
+	Offset int
+	// Args is the rest of the directive's line or comment
+	Args string
+}
+
+// statementDirectives finds the directives called name in the comments stmt
+// starts with. Only those comments count, so a directive applies to the
+// next statement. A directive starts its comment (or a line of a block
+// comment) and its name ends at whitespace or the end of the comment, so
+// prose such as `-- see dbsqlx:ignore-all docs` is not one.
+func statementDirectives(stmt sqlStatement, name string) []directive {
+	var found []directive
+	lead := stmt.Text[:leadingComments(stmt.Text)]
+	for pos := 0; ; {
+		i := strings.Index(lead[pos:], name)
+		if i < 0 {
+			return found
+		}
+		start := pos + i
+		pos = start + len(name)
+		if !startsComment(lead[:start]) || !endsName(lead[pos:]) {
+			continue
+		}
+
+		// The directive runs to the end of its line or comment
+		args := lead[pos:]
+		if nl := strings.IndexByte(args, '\n'); nl >= 0 {
+			args = args[:nl]
+		}
+		if end := strings.Index(args, "*/"); end >= 0 {
+			args = args[:end]
+		}
+		found = append(found, directive{Offset: stmt.Offset + start, Args: args})
+	}
+}
+
+// startsComment reports whether only blanks follow the comment opener or
+// line break that before ends with
+func startsComment(before string) bool {
+	before = strings.TrimRight(before, " \t")
+	return before == "" || strings.HasSuffix(before, "--") || strings.HasSuffix(before, "#") ||
+		strings.HasSuffix(before, "/*") || strings.HasSuffix(before, "\n")
+}
+
+// endsName reports whether a directive name followed by after ends there
+func endsName(after string) bool {
+	return after == "" || strings.HasPrefix(after, "*/") || strings.ContainsRune(" \t\r\n", rune(after[0]))
+}
+
+// statementSuppressions finds the ignore comments ahead of stmt
+func statementSuppressions(src, file string, stmt sqlStatement, known []string) []*Suppression {
+	var found []*Suppression
+	for _, d := range statementDirectives(stmt, ignoreDirective) {
+		s := &Suppression{File: file, Statement: stmt.Index, Suppressed: []Finding{}}
+		s.Line, _ = lineColumn(src, d.Offset)
+		s.Rules, s.Reason = parseIgnoreArgs(d.Args)
+		for _, rule := range s.Rules {
+			if !slices.Contains(known, rule) {
+				s.UnknownRules = append(s.UnknownRules, rule)
+			}
+		}
+		found = append(found, s)
+	}
+	return found
+}
+
+// parseIgnoreArgs splits `name[,name...] reason="..."` into the names and
+// the reason
+func parseIgnoreArgs(args string) ([]string, string) {
+	reason := ""
+	if i := strings.Index(args, "reason="); i >= 0 {
+		reason = strings.TrimSpace(args[i+len("reason="):])
+		args = args[:i]
+		if q := reason[:min(len(reason), 1)]; q == `"` || q == "'" {
+			if end := strings.Index(reason[1:], q); end >= 0 {
+				reason = reason[1 : end+1]
+			} else {
+				reason = reason[1:]
+			}
+		}
+	}
+	rules := strings.FieldsFunc(args, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r'
+	})
+	return rules, reason
+}
+
+// suppress moves findings covered by a suppression into its audit entry and
+// returns the rest
+func suppress(findings []Finding, sups []*Suppression) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		i := slices.IndexFunc(sups, func(s *Suppression) bool { return s.covers(f.Rule) })
+		if i < 0 {
+			kept = append(kept, f)
+			continue
+		}
+		sups[i].Suppressed = append(sups[i].Suppressed, f)
+	}
+	return kept
+}
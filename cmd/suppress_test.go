@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreArgs(t *testing.T) {
+	tests := []struct {
+		args       string
+		wantRules  []string
+		wantReason string
+	}{
+		{` missing-where reason="full purge approved"`, []string{"missing-where"}, "full purge approved"},
+		{` select-star, large-offset reason='report query'`, []string{"select-star", "large-offset"}, "report query"},
+		{` select-star,large-offset reason=ticket-42`, []string{"select-star", "large-offset"}, "ticket-42"},
+		{` reason="anything goes"`, []string{}, "anything goes"},
+		{``, []string{}, ""},
+		{` missing-where reason="unterminated`, []string{"missing-where"}, "unterminated"},
+	}
+	for _, tt := range tests {
+		rules, reason := parseIgnoreArgs(tt.args)
+		if !reflect.DeepEqual(rules, tt.wantRules) || reason != tt.wantReason {
+			t.Errorf("parseIgnoreArgs(%q) = %q, %q; want %q, %q", tt.args, rules, reason, tt.wantRules, tt.wantReason)
+		}
+	}
+}
+
+func TestStatementDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"line comment", "-- dbsqlx:ignore select-star\nSELECT 1", []string{" select-star"}},
+		{"no space after the opener", "#dbsqlx:ignore\nSELECT 1", []string{""}},
+		{"block comment", "/* dbsqlx:ignore select-star */ SELECT 1", []string{" select-star "}},
+		{"line of a block comment", "/*\n  dbsqlx:ignore select-star\n*/ SELECT 1", []string{" select-star"}},
+		{"name runs on", "-- dbsqlx:ignore-all docs\nSELECT 1", nil},
+		{"mid-comment mention", "-- see dbsqlx:ignore docs\nSELECT 1", nil},
+		{"mention then directive", "-- see dbsqlx:ignore docs\n-- dbsqlx:ignore\nSELECT 1", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range statementDirectives(sqlStatement{Index: 1, Text: tt.text}, ignoreDirective) {
+				got = append(got, d.Args)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statementDirectives() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintSQLSuppressions(t *testing.T) {
+	sql := "-- dbsqlx:ignore missing-where reason=\"full purge approved\"\n" +
+		"DELETE FROM sessions;\n" +
+		"DELETE FROM logs; -- dbsqlx:ignore\n" +
+		"SELECT * FROM t WHERE a = NULL;\n" +
+		"/* dbsqlx:ignore no-where-delete */ DELETE FROM jobs;\n" +
+		"SELECT id FROM t WHERE id = 1 -- dbsqlx:ignore select-star\n"
+
+	report := LintSQL(sql, "m.sql", BuiltinRules(), nil)
+
+	var findings []string
+	for _, f := range report.Findings {
+		findings = append(findings, f.Rule)
+	}
+	if want := []string{"missing-where", "missing-where"}; !reflect.DeepEqual(findings, want) {
+		t.Errorf("Findings = %v, want %v", findings, want)
+	}
+
+	var audit []string
+	for _, s := range report.Suppressions {
+		audit = append(audit, s.String())
+	}
+	want := []string{
+		`m.sql:1: ignore missing-where (statement 1), reason "full purge approved": 1 finding suppressed`,
+		"m.sql:3: ignore all rules (statement 3), no reason: 2 findings suppressed",
+		"m.sql:5: ignore no-where-delete (statement 4), no reason: unknown rule no-where-delete, unused",
+	}
+	if !reflect.DeepEqual(audit, want) {
+		t.Errorf("Suppressions =\n%s\nwant\n%s", strings.Join(audit, "\n"), strings.Join(want, "\n"))
+	}
+
+	if got, want := lintSummary(report), "2 findings: 2 errors (3 suppressed)"; got != want {
+		t.Errorf("lintSummary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// SyntaxError is a parse error located in the source: which statement it
+// is in, where the offending token starts and what it is
+type SyntaxError struct {
+	File string `json:"file,omitempty"`
+	// Statement is the 1-based index of the statement holding the error
+	Statement int `json:"statement"`
+	// Line and Column are 1-based and count from the start of the input;
+	// Column counts characters, Offset bytes
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+	Token  string `json:"token"`
+	// Near is the parser's view of the input from the token on
+	Near    string `json:"near"`
+	Message string `json:"message"`
+	// Excerpt is the source line with a caret under the token
+	Excerpt string `json:"excerpt"`
+}
+
+// Error renders the location the way compilers do, so editors can jump to it
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s (statement %d)", sourceLocation(e.File, e.Line, e.Column), e.Message, e.Statement)
+}
+
+// sourceLocation renders file:line:column, or "line L, column C" without a file
+func sourceLocation(file string, line, column int) string {
+	if file == "" {
+		return fmt.Sprintf("line %d, column %d", line, column)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, column)
+}
+
+// tidbSyntaxError matches the parser's `line L column C near "..."` errors
+var tidbSyntaxError = regexp.MustCompile(`(?s)^line (\d+) column (\d+) near "(.*)"\s*$`)
+
+// newSyntaxError locates a parser error in sql. It returns nil when err is
+// not a syntax error with a position.
+func newSyntaxError(sql, file string, err error) *SyntaxError {
+	offset, near, ok := errorOffset(sql, err)
+	if !ok {
+		return nil
+	}
+	return buildSyntaxError(sql, file, statementIndexAt(sql, offset), offset, near)
+}
+
+// errorOffset returns the byte offset in sql of the token a parser error
+// points at, and the parser's text from there on
+func errorOffset(sql string, err error) (int, string, bool) {
+	m := tidbSyntaxError.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, "", false
+	}
+	line, _ := strconv.Atoi(m[1])
+	col, _ := strconv.Atoi(m[2])
+	near := m[3]
+
+	if near == "" {
+		return len(sql), near, true
+	}
+	// The parser reports where the token ends and near starts at it
+	end := min(lineOffset(sql, line)+col, len(sql)-1)
+	for p := end; p >= 0; p-- {
+		if strings.HasPrefix(sql[p:], near) {
+			return p, near, true
+		}
+	}
+	return max(end, 0), near, true
+}
+
+// buildSyntaxError describes the error at offset of the statement with the
+// given index, positioned within the whole input src
+func buildSyntaxError(src, file string, statement, offset int, near string) *SyntaxError {
+	e := &SyntaxError{
+		File:      file,
+		Statement: statement,
+		Offset:    offset,
+		Token:     firstToken(near),
+		Near:      near,
+	}
+	e.Line, e.Column = lineColumn(src, offset)
+	if e.Token == "" {
+		e.Message = "unexpected end of input"
+	} else {
+		e.Message = fmt.Sprintf("unexpected %q", e.Token)
+	}
+	e.Excerpt = excerpt(src, offset, e.Line, utf8.RuneCountInString(e.Token))
+	return e
+}
+
+// CheckAllSyntax parses every statement of sql on its own and returns the
+// number of statements and an error for each one that fails to parse
+func CheckAllSyntax(sql, file string) (int, []*SyntaxError) {
+	stmts, errs := parseStatements(sql, file)
+	return len(stmts) + len(errs), errs
+}
+
+// parsedStatement is a statement of a script with its syntax tree
+type parsedStatement struct {
+	sqlStatement
+	// Nodes usually holds one statement; more when the delimiter in use
+	// let several through together
+	Nodes []ast.StmtNode
+}
+
+// parseStatements splits sql and parses each statement on its own. It
+// returns the statements that parse and an error for each one that doesn't.
+func parseStatements(sql, file string) ([]parsedStatement, []*SyntaxError) {
+	var parsed []parsedStatement
+	errs := []*SyntaxError{}
+	for _, stmt := range splitStatements(sql) {
+		// A parser reuses its buffers, so trees from one parse don't survive
+		// the next
+		nodes, _, err := parser.New().ParseSQL(stmt.Text)
+		if err == nil {
+			parsed = append(parsed, parsedStatement{sqlStatement: stmt, Nodes: nodes})
+			continue
+		}
+		offset, near, ok := errorOffset(stmt.Text, err)
+		if !ok {
+			errs = append(errs, &SyntaxError{File: file, Statement: stmt.Index, Message: err.Error()})
+			continue
+		}
+		errs = append(errs, buildSyntaxError(sql, file, stmt.Index, stmt.Offset+offset, near))
+	}
+	return parsed, errs
+}
+
+// lineOffset returns the byte offset where the 1-based line starts
+func lineOffset(sql string, line int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(sql[offset:], '\n')
+		if next < 0 {
+			return len(sql)
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// lineColumn converts a byte offset into a 1-based line and character column
+func lineColumn(sql string, offset int) (int, int) {
+	before := sql[:offset]
+	line := strings.Count(before, "\n") + 1
+	start := strings.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCountInString(before[start:]) + 1
+}
+
+// skipQuoted returns the offset of the quote closing the string or
+// identifier opened at i, honouring doubled quotes and backslash escapes
+func skipQuoted(sql string, i int) int {
+	quote := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(sql)
+}
+
+// firstToken returns the first lexical token of s: a quoted string or
+// identifier, a word, a multi-character operator or a single character
+func firstToken(s string) string {
+	if s == "" {
+		return ""
+	}
+	switch c := s[0]; {
+	case c == '\'' || c == '"' || c == '`':
+		return s[:min(skipQuoted(s, 0)+1, len(s))]
+	case isWordByte(c):
+		end := 1
+		for end < len(s) && isWordByte(s[end]) {
+			end++
+		}
+		return s[:end]
+	}
+	for _, op := range []string{"<=>", "<=", ">=", "<>", "!=", ":=", "||", "&&", "<<", ">>"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c >= 0x80 ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// excerpt renders the source line holding offset with a caret under the
+// token, prefixed with its line number
+func excerpt(sql string, offset, line, width int) string {
+	start := strings.LastIndexByte(sql[:offset], '\n') + 1
+	end := len(sql)
+	if nl := strings.IndexByte(sql[offset:], '\n'); nl >= 0 {
+		end = offset + nl
+	}
+	text := strings.TrimRight(sql[start:end], "\r")
+	width = min(width, utf8.RuneCountInString(sql[offset:end]))
+
+	// Keep tabs so the caret lines up with the source
+	var pad strings.Builder
+	for _, r := range sql[start:offset] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+
+	gutter := strconv.Itoa(line)
+	return fmt.Sprintf("%s | %s\n%s | %s%s", gutter, text, strings.Repeat(" ", len(gutter)), pad.String(), strings.Repeat("^", max(width, 1)))
+}
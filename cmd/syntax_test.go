@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewSyntaxError(t *testing.T) {
+	tests := []struct {
+		name          string
+		sql           string
+		wantStatement int
+		wantLine      int
+		wantColumn    int
+		wantToken     string
+		wantExcerpt   string
+	}{
+		{
+			name:          "Missing table",
+			sql:           "SELECT * FROM WHERE id = 1",
+			wantStatement: 1,
+			wantLine:      1,
+			wantColumn:    15,
+			wantToken:     "WHERE",
+			wantExcerpt:   "1 | SELECT * FROM WHERE id = 1\n  |               ^^^^^",
+		},
+		{
+			name:          "Later statement on a later line",
+			sql:           "SELECT 1;\nSELECT * FROM users\n  WHERE id = = 3;\nSELECT 2",
+			wantStatement: 2,
+			wantLine:      3,
+			wantColumn:    14,
+			wantToken:     "=",
+			wantExcerpt:   "3 |   WHERE id = = 3;\n  |              ^",
+		},
+		{
+			name:          "Semicolons in strings and comments",
+			sql:           "SELECT 'a;b'; -- not; a statement\n/* ; */ SELECT \"x;\" FROM t WHERE x = = 1",
+			wantStatement: 2,
+			wantLine:      2,
+			wantColumn:    38,
+			wantToken:     "=",
+		},
+		{
+			name:          "Columns count characters",
+			sql:           "SELECT 'héllo' FROM t WHERE x = = 1",
+			wantStatement: 1,
+			wantLine:      1,
+			wantColumn:    33,
+			wantToken:     "=",
+		},
+		{
+			name:          "Misspelled keyword",
+			sql:           "SELCT * FROM users",
+			wantStatement: 1,
+			wantLine:      1,
+			wantColumn:    1,
+			wantToken:     "SELCT",
+		},
+		{
+			name:          "Unexpected end of input",
+			sql:           "SELECT 1;\nSELECT * FROM users WHERE id =",
+			wantStatement: 2,
+			wantLine:      2,
+			wantColumn:    31,
+			wantToken:     "",
+			wantExcerpt:   "2 | SELECT * FROM users WHERE id =\n  |                               ^",
+		},
+		{
+			name:          "Tabs keep the caret aligned",
+			sql:           "SELECT *\n\tFROM t WHERE a IN (1,, 2)",
+			wantStatement: 1,
+			wantLine:      2,
+			wantColumn:    23,
+			wantToken:     ",",
+			wantExcerpt:   "2 | \tFROM t WHERE a IN (1,, 2)\n  | \t                     ^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSQLSyntax(tt.sql)
+			if err == nil {
+				t.Fatalf("CheckSQLSyntax() expected an error")
+			}
+			got := newSyntaxError(tt.sql, "q.sql", err)
+			if got == nil {
+				t.Fatalf("newSyntaxError(%q) = nil", err)
+			}
+			if got.Statement != tt.wantStatement || got.Line != tt.wantLine || got.Column != tt.wantColumn || got.Token != tt.wantToken {
+				t.Errorf("newSyntaxError() = statement %d, %d:%d, token %q; want statement %d, %d:%d, token %q",
+					got.Statement, got.Line, got.Column, got.Token, tt.wantStatement, tt.wantLine, tt.wantColumn, tt.wantToken)
+			}
+			if tt.wantExcerpt != "" && got.Excerpt != tt.wantExcerpt {
+				t.Errorf("Excerpt =\n%s\nwant\n%s", got.Excerpt, tt.wantExcerpt)
+			}
+			if got.File != "q.sql" {
+				t.Errorf("File = %q, want q.sql", got.File)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorError(t *testing.T) {
+	e := &SyntaxError{File: "m.sql", Statement: 3, Line: 7, Column: 12, Message: `unexpected "="`}
+	if got, want := e.Error(), `m.sql:7:12: unexpected "=" (statement 3)`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	e.File = ""
+	if got, want := e.Error(), `line 7, column 12: unexpected "=" (statement 3)`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestFirstToken(t *testing.T) {
+	tests := map[string]string{
+		"WHERE id = 1":  "WHERE",
+		"= 3;":          "=",
+		">= 2":          ">=",
+		"'it''s' x":     "'it''s'",
+		"`my col` = 1":  "`my col`",
+		"@var := 1":     "@var",
+		"héllo world":   "héllo",
+		"(1, 2)":        "(",
+		"":              "",
+		"'unterminated": "'unterminated",
+	}
+	for in, want := range tests {
+		if got := firstToken(in); got != want {
+			t.Errorf("firstToken(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCheckAllSyntax(t *testing.T) {
+	sql := "-- backfill; run after deploy\n" +
+		"UPDATE users SET note = 'a;b' WHERE id = 1;\n" +
+		"DELETE FROM users\n  WHERE id = = 3;\n" +
+		"SELECT name FROM users WHERE;\n" +
+		"DELIMITER //\nSELECT 1 FROM FROM t//\n"
+
+	total, errs := CheckAllSyntax(sql, "m.sql")
+	if total != 4 {
+		t.Errorf("CheckAllSyntax() statements = %d, want 4", total)
+	}
+
+	type pos struct {
+		statement, line, column int
+		token                   string
+	}
+	var got []pos
+	for _, e := range errs {
+		got = append(got, pos{e.Statement, e.Line, e.Column, e.Token})
+		if e.File != "m.sql" || e.Excerpt == "" {
+			t.Errorf("error %v: File = %q, Excerpt = %q", e, e.File, e.Excerpt)
+		}
+	}
+	want := []pos{{2, 4, 14, "="}, {3, 5, 29, ""}, {4, 7, 15, "FROM"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckAllSyntax() errors = %+v, want %+v", got, want)
+	}
+
+	if total, errs := CheckAllSyntax("SELECT 1; SELECT 'x;y'", ""); total != 2 || len(errs) != 0 {
+		t.Errorf("CheckAllSyntax() = %d, %v; want 2 statements and no errors", total, errs)
+	}
+}
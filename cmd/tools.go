@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dumpTool renders the per-table dumps of a plan in one backup tool's syntax
+type dumpTool interface {
+	// Command renders one dump of tables. where is the shell word holding
+	// the row filter applied to every table ("" for whole tables) and output
+	// the shell word naming the output directory; it is ignored by tools
+	// that stream to stdout.
+	Command(s dumpSettings, plan statementPlan, tables []string, where, output string) string
+	// Streams reports whether the dump is written to stdout rather than a
+	// directory
+	Streams() bool
+	// Check reports settings the tool cannot honour
+	Check(s dumpSettings) error
+}
+
+var dumpTools = map[string]dumpTool{
+	"mysqldump": mysqldumpTool{},
+	"mydumper":  mydumperTool{},
+	"dumpling":  dumplingTool{},
+	"mysqlsh":   mysqlshTool{},
+}
+
+// lookupDumpTool returns the named tool, validated against the settings
+func lookupDumpTool(name string, s dumpSettings) (dumpTool, error) {
+	tool, ok := dumpTools[name]
+	if !ok {
+		names := make([]string, 0, len(dumpTools))
+		for n := range dumpTools {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown dump tool %q (valid: %s)", name, strings.Join(names, ", "))
+	}
+	if err := tool.Check(s); err != nil {
+		return nil, err
+	}
+	return tool, nil
+}
+
+// checkMysqldumpOnly rejects mysqldump-specific settings for other tools
+func checkMysqldumpOnly(name string, s dumpSettings) error {
+	if len(s.ExtraOpts) > 0 {
+		return fmt.Errorf("--mysqldump-opt is not supported with --tool %s", name)
+	}
+	if s.SkipColumnStatistics {
+		return fmt.Errorf("--skip-column-statistics is not supported with --tool %s", name)
+	}
+	return nil
+}
+
+type mysqldumpTool struct{}
+
+func (mysqldumpTool) Streams() bool { return true }
+
+func (mysqldumpTool) Check(s dumpSettings) error { return nil }
+
+func (mysqldumpTool) Command(s dumpSettings, plan statementPlan, tables []string, where, output string) string {
+	cmd := "mysqldump" + s.ConnOpts + s.MysqldumpOpts(plan)
+	if where != "" {
+		cmd += " --where=" + where
+	}
+	return fmt.Sprintf("%s %s %s", cmd, s.Database, strings.Join(tables, " "))
+}
+
+type mydumperTool struct{}
+
+var mydumperPresets = map[string][]string{
+	"pre-dml-backup": {"--no-schemas", "--trx-consistency-only"},
+	"schema-only":    {"--no-data"},
+	"consistent":     {"--trx-consistency-only"},
+}
+
+func (mydumperTool) Streams() bool { return false }
+
+func (mydumperTool) Check(s dumpSettings) error {
+	if s.Creds != nil && s.Creds.Mode == credModeLoginPath {
+		return fmt.Errorf("mydumper does not support --login-path; use another --password-mode")
+	}
+	return checkMysqldumpOnly("mydumper", s)
+}
+
+func (mydumperTool) Command(s dumpSettings, plan statementPlan, tables []string, where, output string) string {
+	cmd := "mydumper"
+	if s.Creds != nil {
+		switch s.Creds.Mode {
+		case credModeDefaultsFile:
+			cmd += " --defaults-extra-file=" + s.Creds.DefaultsFile
+		case credModePrompt:
+			cmd += " --ask-password"
+		}
+	}
+	if c := s.Conn; c != nil {
+		if c.Host != "" {
+			cmd += " -h " + c.Host
+		}
+		if c.Port != 0 {
+			cmd += fmt.Sprintf(" -P %d", c.Port)
+		}
+		if c.Socket != "" {
+			cmd += " -S " + c.Socket
+		}
+		if c.User != "" {
+			cmd += " -u " + c.User
+		}
+		if c.SSLMode != "" {
+			cmd += " --ssl-mode=" + c.SSLMode
+		}
+		if c.SSLCA != "" {
+			cmd += " --ca=" + c.SSLCA
+		}
+		if c.SSLCert != "" {
+			cmd += " --cert=" + c.SSLCert
+		}
+		if c.SSLKey != "" {
+			cmd += " --key=" + c.SSLKey
+		}
+		if c.Compress {
+			cmd += " --compress-protocol"
+		}
+	}
+	for _, opt := range mydumperPresets[plan.Preset] {
+		cmd += " " + opt
+	}
+	switch s.Compression {
+	case "gzip":
+		cmd += " --compress=GZIP"
+	case "zstd":
+		cmd += " --compress=ZSTD"
+	}
+	cmd += " -T " + strings.Join(qualifyTables(s.Database, tables), ",")
+	if where != "" {
+		cmd += " --where " + where
+	}
+	return cmd + " -o " + output
+}
+
+type dumplingTool struct{}
+
+var dumplingPresets = map[string][]string{
+	"pre-dml-backup": {"--no-schemas"},
+	"schema-only":    {"--no-data"},
+	"consistent":     {"--consistency=snapshot"},
+}
+
+func (dumplingTool) Streams() bool { return false }
+
+func (dumplingTool) Check(s dumpSettings) error {
+	if s.Creds != nil && s.Creds.Mode != credModeEnv {
+		return fmt.Errorf("dumpling reads no option files and cannot prompt; use --password-mode=%s", credModeEnv)
+	}
+	if s.Conn != nil && s.Conn.Socket != "" {
+		return fmt.Errorf("dumpling does not support --socket")
+	}
+	return checkMysqldumpOnly("dumpling", s)
+}
+
+func (dumplingTool) Command(s dumpSettings, plan statementPlan, tables []string, where, output string) string {
+	cmd := "dumpling"
+	if c := s.Conn; c != nil {
+		if c.Host != "" {
+			cmd += " -h " + c.Host
+		}
+		if c.Port != 0 {
+			cmd += fmt.Sprintf(" -P %d", c.Port)
+		}
+		if c.User != "" {
+			cmd += " -u " + c.User
+		}
+		if c.SSLCA != "" {
+			cmd += " --ca=" + c.SSLCA
+		}
+		if c.SSLCert != "" {
+			cmd += " --cert=" + c.SSLCert
+		}
+		if c.SSLKey != "" {
+			cmd += " --key=" + c.SSLKey
+		}
+	}
+	// dumpling has no MYSQL_PWD support, so hand it the variable explicitly
+	if s.Creds != nil && s.Creds.Mode == credModeEnv {
+		cmd += ` -p "$MYSQL_PWD"`
+	}
+	for _, opt := range dumplingPresets[plan.Preset] {
+		cmd += " " + opt
+	}
+	switch s.Compression {
+	case "gzip":
+		cmd += " --compress=gzip"
+	case "zstd":
+		cmd += " --compress=zstd"
+	}
+	for _, t := range qualifyTables(s.Database, tables) {
+		cmd += " --filter " + t
+	}
+	if where != "" {
+		cmd += " --where " + where
+	}
+	return cmd + " -o " + output
+}
+
+// mysqlshTool renders util.dumpTables calls. The filter and output location
+// are passed through the environment so they need no JavaScript escaping.
+type mysqlshTool struct{}
+
+var mysqlshPresets = map[string][]string{
+	"pre-dml-backup": {"dataOnly: true"},
+	"schema-only":    {"ddlOnly: true"},
+	"consistent":     {"consistent: true"},
+}
+
+func (mysqlshTool) Streams() bool { return false }
+
+func (mysqlshTool) Check(s dumpSettings) error {
+	if s.Creds != nil && (s.Creds.Mode == credModeLoginPath || s.Creds.Mode == credModeDefaultsFile) {
+		return fmt.Errorf("mysqlsh reads no option files; use --password-mode=%s or %s", credModeEnv, credModePrompt)
+	}
+	return checkMysqldumpOnly("mysqlsh", s)
+}
+
+func (mysqlshTool) Command(s dumpSettings, plan statementPlan, tables []string, where, output string) string {
+	cmd := ""
+	if where != "" {
+		cmd += "DBSQLX_WHERE=" + where + " "
+	}
+	cmd += "DBSQLX_OUTPUT=" + output + " mysqlsh"
+	if s.Conn != nil {
+		cmd += s.Conn.ClientOpts()
+	}
+	if s.Creds != nil {
+		switch s.Creds.Mode {
+		case credModeEnv:
+			cmd += ` --password="$MYSQL_PWD"`
+		case credModePrompt:
+			cmd += " -p"
+		}
+	}
+
+	var opts []string
+	if where != "" {
+		var filters []string
+		for _, t := range qualifyTables(s.Database, tables) {
+			filters = append(filters, fmt.Sprintf("'%s': os.getenv('DBSQLX_WHERE')", t))
+		}
+		opts = append(opts, fmt.Sprintf("where: {%s}", strings.Join(filters, ", ")))
+	}
+	opts = append(opts, mysqlshPresets[plan.Preset]...)
+	switch s.Compression {
+	case "none":
+		opts = append(opts, "compression: 'none'")
+	case "gzip", "zstd":
+		opts = append(opts, fmt.Sprintf("compression: '%s'", s.Compression))
+	}
+
+	return fmt.Sprintf("%s --js -e \"util.dumpTables('%s', ['%s'], os.getenv('DBSQLX_OUTPUT'), {%s})\"",
+		cmd, s.Database, strings.Join(tables, "', '"), strings.Join(opts, ", "))
+}
+
+// qualifyTables prefixes each table with the database
+func qualifyTables(database string, tables []string) []string {
+	qualified := make([]string, len(tables))
+	for i, t := range tables {
+		qualified[i] = database + "." + t
+	}
+	return qualified
+}
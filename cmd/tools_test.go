@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpToolCommands(t *testing.T) {
+	settings := dumpSettings{
+		ConnOpts: " -h db -P 4000 -u root",
+		Conn:     &connOptions{Host: "db", Port: 4000, User: "root"},
+		Creds:    &credentials{Mode: credModeEnv},
+		Database: "shop",
+	}
+	plan := statementPlan{Index: 2, Action: "DELETE", Preset: "pre-dml-backup"}
+
+	tests := []struct {
+		tool   string
+		where  string
+		output string
+		want   string
+	}{
+		{
+			tool:  "mysqldump",
+			where: `"id=1"`,
+			want:  `mysqldump -h db -P 4000 -u root --single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF --where="id=1" shop orders`,
+		},
+		{
+			tool:   "mydumper",
+			where:  `"id=1"`,
+			output: "out",
+			want:   `mydumper -h db -P 4000 -u root --no-schemas --trx-consistency-only -T shop.orders --where "id=1" -o out`,
+		},
+		{
+			tool:   "dumpling",
+			where:  `"id=1"`,
+			output: "out",
+			want:   `dumpling -h db -P 4000 -u root -p "$MYSQL_PWD" --no-schemas --filter shop.orders --where "id=1" -o out`,
+		},
+		{
+			tool:   "mysqlsh",
+			where:  `"id=1"`,
+			output: "out",
+			want:   `DBSQLX_WHERE="id=1" DBSQLX_OUTPUT=out mysqlsh -h db -P 4000 -u root --password="$MYSQL_PWD" --js -e "util.dumpTables('shop', ['orders'], os.getenv('DBSQLX_OUTPUT'), {where: {'shop.orders': os.getenv('DBSQLX_WHERE')}, dataOnly: true})"`,
+		},
+		{
+			tool:   "mysqlsh",
+			output: "out",
+			want:   `DBSQLX_OUTPUT=out mysqlsh -h db -P 4000 -u root --password="$MYSQL_PWD" --js -e "util.dumpTables('shop', ['orders'], os.getenv('DBSQLX_OUTPUT'), {dataOnly: true})"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			tool, err := lookupDumpTool(tt.tool, settings)
+			if err != nil {
+				t.Fatalf("lookupDumpTool() error = %v", err)
+			}
+			if got := tool.Command(settings, plan, []string{"orders"}, tt.where, tt.output); got != tt.want {
+				t.Errorf("Command() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpToolCommandsMultipleTables(t *testing.T) {
+	settings := dumpSettings{
+		Conn:     &connOptions{User: "root"},
+		Creds:    &credentials{Mode: credModeEnv},
+		Database: "shop",
+	}
+	plan := statementPlan{Index: 1, Preset: "none"}
+	tables := []string{"users", "carts"}
+
+	tests := map[string]string{
+		"mysqldump": `mysqldump --where="id=7" shop users carts`,
+		"mydumper":  `mydumper -u root -T shop.users,shop.carts --where "id=7" -o out`,
+		"dumpling":  `dumpling -u root -p "$MYSQL_PWD" --filter shop.users --filter shop.carts --where "id=7" -o out`,
+		"mysqlsh":   `DBSQLX_WHERE="id=7" DBSQLX_OUTPUT=out mysqlsh -u root --password="$MYSQL_PWD" --js -e "util.dumpTables('shop', ['users', 'carts'], os.getenv('DBSQLX_OUTPUT'), {where: {'shop.users': os.getenv('DBSQLX_WHERE'), 'shop.carts': os.getenv('DBSQLX_WHERE')}})"`,
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := dumpTools[name].Command(settings, plan, tables, `"id=7"`, "out"); got != want {
+				t.Errorf("Command() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestLookupDumpToolRejectsUnsupportedSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     string
+		settings dumpSettings
+	}{
+		{
+			name: "Unknown tool",
+			tool: "pg_dump",
+		},
+		{
+			name:     "dumpling with defaults file",
+			tool:     "dumpling",
+			settings: dumpSettings{Creds: &credentials{Mode: credModeDefaultsFile}},
+		},
+		{
+			name:     "dumpling with socket",
+			tool:     "dumpling",
+			settings: dumpSettings{Conn: &connOptions{Socket: "/tmp/mysql.sock"}},
+		},
+		{
+			name:     "mysqlsh with login path",
+			tool:     "mysqlsh",
+			settings: dumpSettings{Creds: &credentials{Mode: credModeLoginPath}},
+		},
+		{
+			name:     "mydumper with mysqldump passthrough",
+			tool:     "mydumper",
+			settings: dumpSettings{ExtraOpts: []string{"--hex-blob"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := lookupDumpTool(tt.tool, tt.settings); err == nil {
+				t.Errorf("lookupDumpTool(%q) expected error", tt.tool)
+			}
+		})
+	}
+}
+
+func TestWriteDumpScriptDirectoryTool(t *testing.T) {
+	stmtNodes, err := ParseAll("DELETE FROM logs WHERE level = 'debug'")
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	settings := dumpSettings{
+		ConnOpts:    " -u root",
+		Conn:        &connOptions{User: "root"},
+		Database:    "prod",
+		Compression: "zstd",
+	}
+	var buf bytes.Buffer
+	if err := writeDumpScript(&buf, buildDumpPlan(stmtNodes, nil), settings, mydumperTool{}); err != nil {
+		t.Fatalf("writeDumpScript() error = %v", err)
+	}
+	script := buf.String()
+
+	for _, part := range []string{
+		`file="$out_dir/prod.logs_1_${ts}"`,
+		`mydumper -u root --no-schemas --trx-consistency-only --compress=ZSTD -T prod.logs --where "$filter" -o "$file"`,
+	} {
+		if !strings.Contains(script, part) {
+			t.Errorf("script missing %q\nGot:\n%s", part, script)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"dbsqlx/cmd"
 	"io"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -243,8 +244,104 @@ func TestDumpWithAllConnectionFlags(t *testing.T) {
 
 	output := string(captured)
 
-	// Expect mysqldump with all connection flags and custom database
-	expected := "mysqldump -h 192.168.1.100 -u admin --password=secret123 --where=\"level='error'\" production_db logs\n"
+	// Expect the password to be moved into a defaults file, never printed
+	if strings.Contains(output, "secret123") {
+		t.Errorf("password leaked into output:\n%s", output)
+	}
+
+	re := regexp.MustCompile(`mysqldump --defaults-extra-file=(\S+) -h 192\.168\.1\.100 -u admin --where="level='error'" production_db logs\n`)
+	m := re.FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("unexpected output.\nGot:  %q", output)
+	}
+	defer os.Remove(m[1])
+
+	content, err := os.ReadFile(m[1])
+	if err != nil {
+		t.Fatalf("failed to read defaults file: %v", err)
+	}
+	if !strings.Contains(string(content), `password="secret123"`) {
+		t.Errorf("defaults file missing password, got %q", content)
+	}
+}
+
+func TestDumpWithDSN(t *testing.T) {
+	// Save original args and stdout
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	defer cmd.ResetGlobals()
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = origStdout }()
+
+	// DSN supplies host, port, user, database and TLS; --port overrides it
+	os.Args = []string{
+		"dbsqlx",
+		"dump",
+		"SELECT * FROM users WHERE id = 42",
+		"--dsn", "mysql://ops@db.internal:3307/shop?tls=true",
+		"--port", "3308",
+	}
+
+	// Run main()
+	main()
+
+	// Close writer and read captured output
+	_ = w.Close()
+	captured, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read captured stdout: %v", err)
+	}
+
+	output := string(captured)
+
+	expected := "mysqldump -h db.internal -P 3308 -u ops --ssl-mode=VERIFY_IDENTITY --where=\"id=42\" shop users\n"
+	if output != expected {
+		t.Errorf("unexpected output.\nGot:  %q\nWant: %q", output, expected)
+	}
+}
+
+func TestDumpWithMerge(t *testing.T) {
+	// Save original args and stdout
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	defer cmd.ResetGlobals()
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = origStdout }()
+
+	os.Args = []string{
+		"dbsqlx",
+		"dump",
+		"DELETE FROM orders WHERE id = 1; DELETE FROM orders WHERE id = 2; DELETE FROM orders WHERE id = 1",
+		"-d", "shop",
+		"--merge",
+	}
+
+	// Run main()
+	main()
+
+	// Close writer and read captured output
+	_ = w.Close()
+	captured, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read captured stdout: %v", err)
+	}
+
+	output := string(captured)
+
+	expected := "# Merged from statements 1, 2, 3\n" +
+		"mysqldump --single-transaction --no-create-info --skip-triggers --hex-blob --set-gtid-purged=OFF --where=\"id=1 OR id=2\" shop orders\n"
 	if output != expected {
 		t.Errorf("unexpected output.\nGot:  %q\nWant: %q", output, expected)
 	}